@@ -4,10 +4,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"subtitle-translator/config"
+	"subtitle-translator/internal/repository"
 	"subtitle-translator/internal/routes"
+	"subtitle-translator/internal/service"
 	"subtitle-translator/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -26,9 +29,34 @@ func main() {
 	// Initialize database
 	config.InitDB()
 
+	// Initialize subtitle content storage
+	config.InitStorage()
+
+	// Load URL normalization rules for cache keys
+	config.InitURLNormalizer()
+
+	// Load cache expiry and storage quota
+	config.InitCache()
+
+	// Move subtitles cached before options fingerprints to their new keys
+	migrateSubtitleKeys()
+
+	// Give subtitles stored before a TTL was configured an expiry
+	applyCacheTTL()
+
+	// Report (or repair) files and rows that disagree after a crash
+	go reconcileStorage(os.Getenv("STORAGE_RECONCILE"))
+
+	// Request body limit (MKV uploads can be large)
+	bodyLimitMB, err := strconv.Atoi(os.Getenv("BODY_LIMIT_MB"))
+	if err != nil || bodyLimitMB <= 0 {
+		bodyLimitMB = 512
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: customErrorHandler,
+		BodyLimit:    bodyLimitMB * 1024 * 1024,
 	})
 
 	// Middleware
@@ -50,7 +78,6 @@ func main() {
 	go func() {
 		log.Printf("Server starting on port %s", port)
 		log.Printf("Database metadata stored in MySQL")
-		log.Printf("Subtitle files stored in: storage/subtitles/")
 		errCh <- app.Listen(":" + port)
 	}()
 
@@ -74,6 +101,63 @@ func main() {
 	log.Println("Server stopped.")
 }
 
+// reconcileStorage runs the startup reconciliation: "report" (default) logs problems, "repair" also fixes them
+// and "off" skips the check.
+func reconcileStorage(mode string) {
+	if mode == "off" {
+		return
+	}
+
+	report, err := repository.Reconcile(config.DB, config.Store, mode == "repair")
+	if err != nil {
+		log.Printf("Storage reconciliation failed: %v", err)
+		return
+	}
+	if report.Empty() {
+		log.Println("Storage reconciliation: content and database agree")
+		return
+	}
+
+	for _, key := range report.OrphanedFiles {
+		log.Printf("Storage reconciliation: orphaned file %s", key)
+	}
+	for _, missing := range report.MissingContent {
+		log.Printf("Storage reconciliation: subtitle %d (%s) has no content at %s", missing.ID, missing.SubtitleID, missing.FilePath)
+	}
+	if report.Repaired {
+		log.Printf("Storage reconciliation: removed %d orphaned files and %d rows without content", len(report.OrphanedFiles), len(report.MissingContent))
+	} else {
+		log.Println("Storage reconciliation: set STORAGE_RECONCILE=repair to fix these")
+	}
+}
+
+// migrateSubtitleKeys runs the startup migration of subtitle IDs to options fingerprints before requests are served,
+// so no variant is translated again under its new key while its legacy row is still being moved.
+func migrateSubtitleKeys() {
+	repo := repository.NewSubtitleRepository(config.DB, config.Store)
+	migrated, skipped, err := service.MigrateSubtitleKeys(repo, config.URLNormalizer)
+	if err != nil {
+		log.Printf("Subtitle key migration failed: %v", err)
+		return
+	}
+	if migrated > 0 || skipped > 0 {
+		log.Printf("Subtitle key migration: moved %d subtitles, %d keep their legacy key until next requested", migrated, skipped)
+	}
+}
+
+// applyCacheTTL sets an expiry on subtitles stored without one, counting CACHE_TTL from their last update.
+func applyCacheTTL() {
+	repo := repository.NewSubtitleRepository(config.DB, config.Store)
+	updated, err := service.ApplyCacheTTL(repo, config.CacheTTL)
+	if err != nil {
+		log.Printf("Applying the cache TTL failed: %v", err)
+		return
+	}
+	if updated > 0 {
+		log.Printf("Cache TTL: set the expiry of %d subtitles", updated)
+	}
+}
+
 func customErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 	if e, ok := err.(*fiber.Error); ok {
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// CacheTTL is how long stored subtitles stay fresh before they are refreshed on access; zero never expires them
+var CacheTTL time.Duration
+
+// StorageQuota caps the bytes of stored subtitle, revision and source content; zero leaves storage unbounded
+var StorageQuota int64
+
+// InitCache loads CACHE_TTL (a duration such as "720h") and STORAGE_QUOTA_MB
+func InitCache() {
+	if ttl := os.Getenv("CACHE_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid CACHE_TTL %q: expected a duration such as 720h", ttl)
+		}
+		CacheTTL = parsed
+	}
+
+	if quota := os.Getenv("STORAGE_QUOTA_MB"); quota != "" {
+		megabytes, err := strconv.ParseInt(quota, 10, 64)
+		if err != nil || megabytes < 0 {
+			log.Fatalf("Invalid STORAGE_QUOTA_MB %q: expected a number of megabytes", quota)
+		}
+		StorageQuota = megabytes * 1024 * 1024
+	}
+
+	log.Printf("Cache policy: TTL %s, storage quota %d MB (0 means none)", CacheTTL, StorageQuota/1024/1024)
+}
@@ -36,7 +36,7 @@ func InitDB() {
 	log.Println("Database connected successfully")
 
 	// Auto migrate models
-	if err := DB.AutoMigrate(&models.Subtitle{}); err != nil {
+	if err := DB.AutoMigrate(&models.Subtitle{}, &models.SubtitleRevision{}, &models.SubtitleSource{}); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+	"subtitle-translator/internal/storage"
+)
+
+// Store holds subtitle content; metadata stays in DB
+var Store storage.ContentStore
+
+// InitStorage initializes the content store selected by STORAGE_BACKEND (local or s3)
+func InitStorage() {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+
+	switch backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "storage"
+		}
+
+		store, err := storage.NewLocalStore(dir)
+		if err != nil {
+			log.Fatal("Failed to initialize local storage:", err)
+		}
+		Store = store
+		log.Printf("Subtitle files stored in: %s/", dir)
+	case "s3":
+		store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		})
+		if err != nil {
+			log.Fatal("Failed to initialize S3 storage:", err)
+		}
+		Store = store
+		log.Printf("Subtitle files stored in S3 bucket: %s", os.Getenv("S3_BUCKET"))
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q (use local or s3)", backend)
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"log"
+	"os"
+	"subtitle-translator/internal/urlnorm"
+)
+
+// URLNormalizer turns subtitle URLs into stable cache identities
+var URLNormalizer *urlnorm.Normalizer
+
+// InitURLNormalizer loads the built-in rules, unless URL_NORMALIZE_DEFAULTS=false, and the per-host rules of
+// URL_NORMALIZE_RULES ("host=param,param;host=param")
+func InitURLNormalizer() {
+	var rules []urlnorm.Rule
+	if os.Getenv("URL_NORMALIZE_DEFAULTS") != "false" {
+		rules = append(rules, urlnorm.DefaultRules...)
+	}
+
+	custom, err := urlnorm.ParseRules(os.Getenv("URL_NORMALIZE_RULES"))
+	if err != nil {
+		log.Fatal("Failed to parse URL_NORMALIZE_RULES:", err)
+	}
+	rules = append(rules, custom...)
+
+	URLNormalizer = urlnorm.New(rules)
+	log.Printf("URL normalization rules loaded: %d", len(rules))
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"subtitle-translator/internal/repository"
+	"subtitle-translator/internal/storage"
+	"subtitle-translator/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const vttContentType = "text/vtt; charset=utf-8"
+
+type StorageHandler struct {
+	store storage.ContentStore
+}
+
+func NewStorageHandler(store storage.ContentStore) *StorageHandler {
+	return &StorageHandler{store: store}
+}
+
+// ServeSubtitleFile serves stored subtitle content, whichever backend holds it
+func (h *StorageHandler) ServeSubtitleFile(c *fiber.Ctx) error {
+	content, err := h.store.Get(repository.ContentPrefix + "/" + c.Params("*"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "File not found",
+				Message: "No stored subtitle file at this path",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Failed to load file",
+			Message: err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, vttContentType)
+	return c.Send(content)
+}
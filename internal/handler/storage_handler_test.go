@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"subtitle-translator/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestServeSubtitleFile_ServesStoredContent(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore returned error: %v", err)
+	}
+	if err := store.Put("subtitles/abc.vtt", []byte("WEBVTT\n")); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/storage/subtitles/*", NewStorageHandler(store).ServeSubtitleFile)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/storage/subtitles/abc.vtt", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || string(body) != "WEBVTT\n" || resp.Header.Get(fiber.HeaderContentType) != vttContentType {
+		t.Fatalf("unexpected response: %d %q %q", resp.StatusCode, body, resp.Header.Get(fiber.HeaderContentType))
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/storage/subtitles/missing.vtt", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("unexpected status code for a missing file: got %d want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+}
@@ -2,14 +2,21 @@ package handler
 
 import (
 	"errors"
+	"net/url"
 	"strconv"
 	"strings"
+	"subtitle-translator/internal/models"
 	"subtitle-translator/internal/service"
+	"subtitle-translator/pkg/translator"
 	"subtitle-translator/pkg/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
+const hlsContentType = "application/vnd.apple.mpegurl"
+
 type SubtitleHandler struct {
 	service service.SubtitleService
 }
@@ -26,10 +33,83 @@ type TranslateRequest struct {
 	Referer    string `json:"referer"`
 	IsRefresh  bool   `json:"is_refresh"`
 	IsLock     bool   `json:"is_lock"`
+
+	// Output options
+	Output         string  `json:"output"`
+	BilingualOrder string  `json:"bilingual_order"`
+	OriginalClass  string  `json:"original_class"`
+	RomanizedUnder string  `json:"romanized_under"`
+	LongCues       string  `json:"long_cues"`
+	MaxCPS         float64 `json:"max_cps"`
+	MaxExtensionMS int     `json:"max_extension_ms"`
+	SDH            string  `json:"sdh"`
+	Lyrics         string  `json:"lyrics"`
+}
+
+type TranslateHLSRequest struct {
+	MasterURL   string `json:"master_url"`
+	SubtitleURL string `json:"subtitle_url"`
+	Format      string `json:"format"`
+	TargetLang  string `json:"target_lang"`
+	SourceLang  string `json:"source_lang"`
+	Referer     string `json:"referer"`
+}
+
+type MKVRequest struct {
+	URL        string `json:"url" form:"url"`
+	Referer    string `json:"referer" form:"referer"`
+	Track      uint64 `json:"track" form:"track"`
+	TargetLang string `json:"target_lang" form:"target_lang"`
+	SourceLang string `json:"source_lang" form:"source_lang"`
+	IsRefresh  bool   `json:"is_refresh" form:"is_refresh"`
+	IsLock     bool   `json:"is_lock" form:"is_lock"`
+}
+
+type SyncPointRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type TimingRequest struct {
+	Operation string             `json:"operation"`
+	OffsetMS  int64              `json:"offset_ms"`
+	Sync      []SyncPointRequest `json:"sync"`
+	FromFPS   float64            `json:"from_fps"`
+	ToFPS     float64            `json:"to_fps"`
+}
+
+type AlignRequest struct {
+	ReferenceURL string `json:"reference_url"`
+	Format       string `json:"format"`
+	Referer      string `json:"referer"`
+}
+
+type ValidateRequest struct {
+	Content       string  `json:"content"`
+	Format        string  `json:"format"`
+	Fix           bool    `json:"fix"`
+	MaxLineLength int     `json:"max_line_length"`
+	MaxCPS        float64 `json:"max_cps"`
 }
 
 type UpdateSubtitleRequest struct {
 	Content string `json:"content" validate:"required"`
+	Author  string `json:"author"`
+}
+
+type RollbackRequest struct {
+	Author string `json:"author"`
+}
+
+// CueRequest sets the fields of a cue; unset fields are left as they are. Timestamps are WebVTT timestamps.
+type CueRequest struct {
+	ID       *string `json:"id"`
+	Start    *string `json:"start"`
+	End      *string `json:"end"`
+	Settings *string `json:"settings"`
+	Text     *string `json:"text"`
+	Revision string  `json:"revision"`
+	Author   string  `json:"author"`
 }
 
 type TranslateBatchContentItem struct {
@@ -38,6 +118,11 @@ type TranslateBatchContentItem struct {
 	Src  interface{} `json:"src"`
 }
 
+// TTLRequest sets the TTL of one subtitle: seconds, 0 for the configured TTL or -1 to never expire
+type TTLRequest struct {
+	TTLSeconds *int64 `json:"ttl_seconds"`
+}
+
 type TranslateBatchContentData struct {
 	Title   string                      `json:"title"`
 	Content []TranslateBatchContentItem `json:"content"`
@@ -80,6 +165,27 @@ func (h *SubtitleHandler) TranslateSubtitle(c *fiber.Ctx) error {
 	if c.Query("is_lock") == "true" {
 		req.IsLock = true
 	}
+	if req.Output == "" {
+		req.Output = c.Query("output", translator.OutputTranslated)
+	}
+	if req.RomanizedUnder == "" {
+		req.RomanizedUnder = c.Query("romanized_under")
+	}
+	if req.LongCues == "" {
+		req.LongCues = c.Query("long_cues", translator.LongCuesDrop)
+	}
+	if req.SDH == "" {
+		req.SDH = c.Query("sdh", translator.SDHTranslate)
+	}
+	if req.Lyrics == "" {
+		req.Lyrics = c.Query("lyrics", translator.LyricsTranslate)
+	}
+	if req.MaxCPS == 0 {
+		req.MaxCPS = c.QueryFloat("max_cps")
+	}
+	if req.MaxExtensionMS == 0 {
+		req.MaxExtensionMS = c.QueryInt("max_extension_ms")
+	}
 
 	// Validate
 	if req.URL == "" {
@@ -98,6 +204,74 @@ func (h *SubtitleHandler) TranslateSubtitle(c *fiber.Ctx) error {
 		})
 	}
 
+	opts := translator.Options{
+		Output:         req.Output,
+		BilingualOrder: req.BilingualOrder,
+		OriginalClass:  req.OriginalClass,
+		RomanizedUnder: req.RomanizedUnder,
+		LongCues:       req.LongCues,
+		MaxCPS:         req.MaxCPS,
+		MaxExtensionMS: req.MaxExtensionMS,
+		SDH:            req.SDH,
+		Lyrics:         req.Lyrics,
+	}.Normalized()
+
+	if opts.Output != translator.OutputTranslated && opts.Output != translator.OutputBilingual && opts.Output != translator.OutputRomanized {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid output",
+			Message: "Output must be 'translated', 'bilingual' or 'romanized'",
+		})
+	}
+
+	if opts.Output == translator.OutputRomanized && opts.RomanizedUnder != translator.RomanizedUnderTranslation && opts.RomanizedUnder != translator.RomanizedUnderOriginal {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid romanized_under",
+			Message: "Romanized line must go under 'translation' or 'original'",
+		})
+	}
+
+	if opts.Output == translator.OutputBilingual && opts.BilingualOrder != translator.BilingualOriginalFirst && opts.BilingualOrder != translator.BilingualTranslationFirst {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid bilingual order",
+			Message: "Bilingual order must be 'original_first' or 'translation_first'",
+		})
+	}
+
+	if opts.LongCues != translator.LongCuesDrop && opts.LongCues != translator.LongCuesSplit {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid long_cues",
+			Message: "Long cues must be 'drop' or 'split'",
+		})
+	}
+
+	if opts.SDH != translator.SDHTranslate && opts.SDH != translator.SDHKeep && opts.SDH != translator.SDHStrip {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid sdh",
+			Message: "SDH must be 'translate', 'keep' or 'strip'",
+		})
+	}
+
+	if opts.Lyrics != translator.LyricsTranslate && opts.Lyrics != translator.LyricsOriginal && opts.Lyrics != translator.LyricsRomanized {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid lyrics",
+			Message: "Lyrics must be 'translate', 'original' or 'romanized'",
+		})
+	}
+
+	if req.MaxCPS < 0 || req.MaxExtensionMS < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid reading speed",
+			Message: "max_cps and max_extension_ms must not be negative",
+		})
+	}
+
 	// Translate or get existing
 	subtitle, err := h.service.TranslateSubtitle(
 		req.URL,
@@ -107,6 +281,7 @@ func (h *SubtitleHandler) TranslateSubtitle(c *fiber.Ctx) error {
 		req.Referer,
 		req.IsRefresh,
 		req.IsLock,
+		opts,
 	)
 
 	if err != nil {
@@ -263,6 +438,241 @@ func (h *SubtitleHandler) TranslateBatchContent(c *fiber.Ctx) error {
 	})
 }
 
+// TranslateHLS handles translating the subtitle track of an HLS master playlist
+func (h *SubtitleHandler) TranslateHLS(c *fiber.Ctx) error {
+	var req TranslateHLSRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	if req.TargetLang == "" {
+		req.TargetLang = c.Query("target_lang", "id")
+	}
+	if req.SourceLang == "" {
+		req.SourceLang = c.Query("source_lang", "auto")
+	}
+	if req.Format == "" {
+		req.Format = "vtt"
+	}
+
+	if req.MasterURL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Master URL is required",
+			Message: "Please provide a master playlist URL",
+		})
+	}
+
+	if req.Format != "vtt" && req.Format != "ass" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid format",
+			Message: "Format must be 'vtt' or 'ass'",
+		})
+	}
+
+	playlist, subtitle, err := h.service.TranslateHLSMaster(
+		req.MasterURL,
+		req.SubtitleURL,
+		req.Format,
+		req.TargetLang,
+		req.SourceLang,
+		req.Referer,
+		c.BaseURL(),
+	)
+	if err != nil {
+		if errors.Is(err, service.ErrNoSubtitleRendition) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "No subtitle rendition",
+				Message: "Provide subtitle_url when the master playlist has no subtitle track",
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Translation failed",
+			Message: err.Error(),
+		})
+	}
+
+	c.Set("X-Subtitle-ID", strconv.FormatUint(uint64(subtitle.ID), 10))
+	c.Set(fiber.HeaderContentType, hlsContentType)
+	return c.SendString(playlist)
+}
+
+// GetSubtitlePlaylist handles serving a stored subtitle as an HLS media playlist
+func (h *SubtitleHandler) GetSubtitlePlaylist(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	playlist, err := h.service.GetSubtitlePlaylist(uint(id), c.BaseURL())
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Subtitle not found",
+			Message: err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, hlsContentType)
+	return c.SendString(playlist)
+}
+
+// ListMKVTracks handles listing subtitle tracks of an uploaded or remote MKV file
+func (h *SubtitleHandler) ListMKVTracks(c *fiber.Ctx) error {
+	var req MKVRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	upload, closeUpload, err := mkvUpload(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid upload",
+			Message: err.Error(),
+		})
+	}
+	defer closeUpload()
+
+	if upload == nil && req.URL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "URL or file is required",
+			Message: "Please provide an MKV URL or upload the file as 'file'",
+		})
+	}
+
+	tracks, err := h.service.ListMKVSubtitleTracks(req.URL, req.Referer, upload)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Failed to read MKV file",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   tracks,
+	})
+}
+
+// TranslateMKV handles translating a subtitle track muxed inside an MKV file
+func (h *SubtitleHandler) TranslateMKV(c *fiber.Ctx) error {
+	var req MKVRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	if req.TargetLang == "" {
+		req.TargetLang = c.Query("target_lang", "id")
+	}
+	if req.SourceLang == "" {
+		req.SourceLang = c.Query("source_lang", "auto")
+	}
+	if c.Query("is_refresh") == "true" {
+		req.IsRefresh = true
+	}
+	if c.Query("is_lock") == "true" {
+		req.IsLock = true
+	}
+
+	upload, closeUpload, err := mkvUpload(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid upload",
+			Message: err.Error(),
+		})
+	}
+	defer closeUpload()
+
+	if upload == nil && req.URL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "URL or file is required",
+			Message: "Please provide an MKV URL or upload the file as 'file'",
+		})
+	}
+
+	if req.Track == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Track is required",
+			Message: "Please provide the subtitle track number from /subtitles/mkv/tracks",
+		})
+	}
+
+	subtitle, err := h.service.TranslateMKVSubtitle(
+		req.URL,
+		req.Referer,
+		upload,
+		req.Track,
+		req.TargetLang,
+		req.SourceLang,
+		req.IsRefresh,
+		req.IsLock,
+	)
+	if err != nil {
+		if errors.Is(err, service.ErrSubtitleLocked) {
+			return c.Status(fiber.StatusLocked).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "Subtitle is locked",
+				Message: err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Translation failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   subtitle,
+	})
+}
+
+// mkvUpload opens the optional multipart "file" field; the returned func releases it.
+func mkvUpload(c *fiber.Ctx) (*translator.MKVSource, func(), error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, func() {}, nil
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	return &translator.MKVSource{ReaderAt: file, Size: header.Size}, func() { file.Close() }, nil
+}
+
 // GetAllSubtitles handles listing all subtitles with pagination
 func (h *SubtitleHandler) GetAllSubtitles(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
@@ -309,34 +719,34 @@ func (h *SubtitleHandler) GetAllSubtitles(c *fiber.Ctx) error {
 	})
 }
 
-// GetSubtitleByID handles fetching a single subtitle by ID
-func (h *SubtitleHandler) GetSubtitleByID(c *fiber.Ctx) error {
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
-	if err != nil {
+// ListVariants handles listing the stored translations of a URL, one per set of translation options
+func (h *SubtitleHandler) ListVariants(c *fiber.Ctx) error {
+	url := c.Query("url")
+	if url == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
 			Status:  false,
-			Error:   "Invalid ID",
-			Message: "ID must be a valid number",
+			Error:   "Missing URL",
+			Message: "The url query parameter is required",
 		})
 	}
 
-	subtitle, err := h.service.GetSubtitleByID(uint(id))
+	subtitles, err := h.service.ListVariants(url)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
 			Status:  false,
-			Error:   "Subtitle not found",
+			Error:   "Failed to fetch variants",
 			Message: err.Error(),
 		})
 	}
 
 	return c.JSON(utils.SuccessResponse{
 		Status: true,
-		Data:   subtitle,
+		Data:   subtitles,
 	})
 }
 
-// UpdateSubtitle handles updating subtitle content
-func (h *SubtitleHandler) UpdateSubtitle(c *fiber.Ctx) error {
+// GetSubtitleByID handles fetching a single subtitle by ID
+func (h *SubtitleHandler) GetSubtitleByID(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
@@ -346,7 +756,33 @@ func (h *SubtitleHandler) UpdateSubtitle(c *fiber.Ctx) error {
 		})
 	}
 
-	var req UpdateSubtitleRequest
+	subtitle, err := h.service.GetSubtitleByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Subtitle not found",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   subtitle,
+	})
+}
+
+// UpdateSubtitle handles updating subtitle content
+func (h *SubtitleHandler) UpdateSubtitle(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	var req UpdateSubtitleRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
 			Status:  false,
@@ -363,21 +799,640 @@ func (h *SubtitleHandler) UpdateSubtitle(c *fiber.Ctx) error {
 		})
 	}
 
-	subtitle, err := h.service.UpdateSubtitle(uint(id), req.Content)
+	subtitle, err := h.service.UpdateSubtitle(uint(id), req.Content, req.Author)
+	if err != nil {
+		return contentError(c, err, "Update failed")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   subtitle,
+	})
+}
+
+// AdjustTiming handles shifting, scaling or frame-rate converting a stored subtitle
+func (h *SubtitleHandler) AdjustTiming(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	var req TimingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	adj, err := req.adjustment()
+	if err == nil {
+		err = adj.Validate()
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid timing adjustment",
+			Message: err.Error(),
+		})
+	}
+
+	subtitle, err := h.service.AdjustTiming(uint(id), adj)
+	if err != nil {
+		return contentError(c, err, "Timing adjustment failed")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   subtitle,
+	})
+}
+
+func (r TimingRequest) adjustment() (translator.TimingAdjustment, error) {
+	adj := translator.TimingAdjustment{
+		Operation: strings.ToLower(strings.TrimSpace(r.Operation)),
+		Offset:    time.Duration(r.OffsetMS) * time.Millisecond,
+		FromFPS:   r.FromFPS,
+		ToFPS:     r.ToFPS,
+	}
+
+	if adj.Operation != translator.TimingScale {
+		return adj, nil
+	}
+	if len(r.Sync) != 2 {
+		return adj, errors.New("scale needs exactly two sync points")
+	}
+	for i, point := range r.Sync {
+		from, err := translator.ParseTimestamp(point.From)
+		if err != nil {
+			return adj, err
+		}
+		to, err := translator.ParseTimestamp(point.To)
+		if err != nil {
+			return adj, err
+		}
+		adj.Sync[i] = translator.SyncPoint{From: from, To: to}
+	}
+	return adj, nil
+}
+
+// AlignTiming handles retiming a stored subtitle against an in-sync reference subtitle
+func (h *SubtitleHandler) AlignTiming(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	var req AlignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	if req.ReferenceURL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Reference URL is required",
+			Message: "Please provide the URL of a subtitle that is already in sync",
+		})
+	}
+	if req.Format == "" {
+		req.Format = "vtt"
+	}
+	if req.Format != "vtt" && req.Format != "ass" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid format",
+			Message: "Format must be 'vtt' or 'ass'",
+		})
+	}
+
+	subtitle, alignment, err := h.service.AlignTiming(uint(id), req.ReferenceURL, req.Format, req.Referer)
+	if errors.Is(err, translator.ErrAlignmentFailed) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Alignment failed",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		return contentError(c, err, "Alignment failed")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data: fiber.Map{
+			"subtitle":  subtitle,
+			"alignment": alignment,
+		},
+	})
+}
+
+// ListRevisions handles listing the stored versions of a subtitle
+func (h *SubtitleHandler) ListRevisions(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	revisions, err := h.service.ListRevisions(uint(id))
+	if err != nil {
+		return revisionError(c, err, "Failed to list revisions")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   revisions,
+	})
+}
+
+// DiffRevisions handles the cue-level comparison of two revisions of a subtitle
+func (h *SubtitleHandler) DiffRevisions(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	from, fromErr := strconv.Atoi(c.Query("from"))
+	to, toErr := strconv.Atoi(c.Query("to"))
+	if fromErr != nil || toErr != nil || from < 1 || to < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid revision",
+			Message: "from and to must be revision numbers",
+		})
+	}
+
+	changes, err := h.service.DiffRevisions(uint(id), from, to)
+	if err != nil {
+		return revisionError(c, err, "Diff failed")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data: fiber.Map{
+			"from":    from,
+			"to":      to,
+			"changes": changes,
+		},
+	})
+}
+
+// RollbackSubtitle handles restoring a subtitle to one of its revisions
+func (h *SubtitleHandler) RollbackSubtitle(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	number, err := strconv.Atoi(c.Params("number"))
+	if err != nil || number < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid revision",
+			Message: "Revision must be a positive number",
+		})
+	}
+
+	var req RollbackRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	subtitle, err := h.service.RollbackSubtitle(uint(id), number, req.Author)
+	if err != nil {
+		return revisionError(c, err, "Rollback failed")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   subtitle,
+	})
+}
+
+// GetSubtitleSource handles returning the source subtitle a translation was made from
+func (h *SubtitleHandler) GetSubtitleSource(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	source, err := h.service.GetSubtitleSource(uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrSourceNotStored) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "Source not stored",
+				Message: err.Error(),
+			})
+		}
+		return revisionError(c, err, "Failed to get source subtitle")
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   source,
+	})
+}
+
+// ListCues handles listing the cues of a stored subtitle
+func (h *SubtitleHandler) ListCues(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	cues, err := h.service.ListCues(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Subtitle not found",
+			Message: err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderETag, `"`+cues.Revision+`"`)
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   cues,
+	})
+}
+
+// InsertCue handles adding a cue to a stored subtitle
+func (h *SubtitleHandler) InsertCue(c *fiber.Ctx) error {
+	return h.editCue(c, func(id uint, req CueRequest, edit translator.CueEdit) (*models.SubtitleCues, error) {
+		return h.service.InsertCue(id, edit, req.Revision, req.Author)
+	})
+}
+
+// EditCue handles changing the text, timing, id or settings of one cue
+func (h *SubtitleHandler) EditCue(c *fiber.Ctx) error {
+	return h.editCue(c, func(id uint, req CueRequest, edit translator.CueEdit) (*models.SubtitleCues, error) {
+		return h.service.EditCue(id, cueRef(c), edit, req.Revision, req.Author)
+	})
+}
+
+// DeleteCue handles removing one cue
+func (h *SubtitleHandler) DeleteCue(c *fiber.Ctx) error {
+	return h.editCue(c, func(id uint, req CueRequest, edit translator.CueEdit) (*models.SubtitleCues, error) {
+		return h.service.DeleteCue(id, cueRef(c), req.Revision, req.Author)
+	})
+}
+
+// editCue parses a cue edit request and answers with the cues after the edit. The revision read by the editor
+// comes from If-Match, the body or the query, and is required.
+func (h *SubtitleHandler) editCue(c *fiber.Ctx, apply func(id uint, req CueRequest, edit translator.CueEdit) (*models.SubtitleCues, error)) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+	var req CueRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+		}
+	}
+	if ifMatch := strings.Trim(strings.TrimPrefix(c.Get(fiber.HeaderIfMatch), "W/"), `"`); ifMatch != "" {
+		req.Revision = ifMatch
+	}
+	if req.Revision == "" {
+		req.Revision = c.Query("revision")
+	}
+	if req.Author == "" {
+		req.Author = c.Query("author")
+	}
+	if req.Revision == "" {
+		return c.Status(fiber.StatusPreconditionRequired).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Revision required",
+			Message: "Send the revision from the cue list as If-Match or revision",
+		})
+	}
+
+	edit, err := req.edit()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid cue",
+			Message: err.Error(),
+		})
+	}
+
+	cues, err := apply(uint(id), req, edit)
+	if err != nil {
+		return cueError(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, `"`+cues.Revision+`"`)
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   cues,
+	})
+}
+
+// cueRef returns the :cue route parameter, a 1-based index or a URL-encoded id:<cue id>.
+func cueRef(c *fiber.Ctx) string {
+	ref, err := url.PathUnescape(c.Params("cue"))
+	if err != nil {
+		return c.Params("cue")
+	}
+	return ref
+}
+
+func (r CueRequest) edit() (translator.CueEdit, error) {
+	edit := translator.CueEdit{ID: r.ID, Settings: r.Settings, Text: r.Text}
+	if r.Start != nil {
+		start, err := translator.ParseTimestamp(*r.Start)
+		if err != nil {
+			return edit, err
+		}
+		edit.Start = &start
+	}
+	if r.End != nil {
+		end, err := translator.ParseTimestamp(*r.End)
+		if err != nil {
+			return edit, err
+		}
+		edit.End = &end
+	}
+	return edit, nil
+}
+
+// cueError answers 409 for an edit made against outdated content, 404 for a missing cue, 400 for an invalid one,
+// and like contentError otherwise.
+func cueError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrContentChanged):
+		return c.Status(fiber.StatusConflict).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Revision conflict",
+			Message: err.Error(),
+		})
+	case errors.Is(err, translator.ErrCueNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Cue not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, translator.ErrInvalidCue):
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid cue",
+			Message: err.Error(),
+		})
+	}
+	return contentError(c, err, "Cue edit failed")
+}
+
+// contentError answers 400 for subtitle content with cue timings that cannot be parsed, and like revisionError
+// otherwise.
+func contentError(c *fiber.Ctx, err error, failure string) error {
+	if errors.Is(err, service.ErrMalformedTimestamp) {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Malformed timestamps",
+			Message: err.Error(),
+		})
+	}
+	return revisionError(c, err, failure)
+}
+
+// revisionError answers 404 for a missing subtitle or revision and 500 for anything else.
+func revisionError(c *fiber.Ctx, err error, failure string) error {
+	if errors.Is(err, service.ErrRevisionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Revision not found",
+			Message: err.Error(),
+		})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Subtitle not found",
+			Message: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+		Status:  false,
+		Error:   failure,
+		Message: err.Error(),
+	})
+}
+
+// ValidateSubtitle handles linting subtitle content supplied in the request
+func (h *SubtitleHandler) ValidateSubtitle(c *fiber.Ctx) error {
+	var req ValidateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	if strings.TrimSpace(req.Content) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Content is required",
+			Message: "Please provide subtitle content to validate",
+		})
+	}
+	if req.Format == "" {
+		req.Format = "vtt"
+	}
+	if req.Format != "vtt" && req.Format != "ass" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid format",
+			Message: "Format must be 'vtt' or 'ass'",
+		})
+	}
+
+	result, err := h.service.ValidateContent(req.Content, req.Format, req.lintOptions())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Validation failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   result,
+	})
+}
+
+// ValidateStoredSubtitles handles linting every stored subtitle
+func (h *SubtitleHandler) ValidateStoredSubtitles(c *fiber.Ctx) error {
+	var req ValidateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+		}
+	}
+	if c.Query("fix") == "true" {
+		req.Fix = true
+	}
+
+	results, err := h.service.ValidateStoredSubtitles(req.lintOptions())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
 			Status:  false,
-			Error:   "Update failed",
+			Error:   "Validation failed",
 			Message: err.Error(),
 		})
 	}
 
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   results,
+	})
+}
+
+func (r ValidateRequest) lintOptions() translator.LintOptions {
+	return translator.LintOptions{
+		MaxLineLength: r.MaxLineLength,
+		MaxCPS:        r.MaxCPS,
+		Fix:           r.Fix,
+	}
+}
+
+// SetSubtitleTTL handles setting how long a subtitle stays fresh
+func (h *SubtitleHandler) SetSubtitleTTL(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid ID",
+			Message: "ID must be a valid number",
+		})
+	}
+
+	var req TTLRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.TTLSeconds == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Invalid TTL",
+			Message: "ttl_seconds is required",
+		})
+	}
+
+	subtitle, err := h.service.SetSubtitleTTL(uint(id), *req.TTLSeconds)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTTL) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse{
+				Status:  false,
+				Error:   "Invalid TTL",
+				Message: err.Error(),
+			})
+		}
+		return revisionError(c, err, "Failed to set subtitle TTL")
+	}
+
 	return c.JSON(utils.SuccessResponse{
 		Status: true,
 		Data:   subtitle,
 	})
 }
 
+// CacheReport handles reporting the cache policy, storage use and next eviction candidates
+func (h *SubtitleHandler) CacheReport(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	report, err := h.service.CacheReport(limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Failed to build cache report",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   report,
+	})
+}
+
+// EnforceStorageQuota handles evicting subtitles until storage fits the quota
+func (h *SubtitleHandler) EnforceStorageQuota(c *fiber.Ctx) error {
+	eviction, err := h.service.EnforceStorageQuota()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse{
+			Status:  false,
+			Error:   "Eviction failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(utils.SuccessResponse{
+		Status: true,
+		Data:   eviction,
+	})
+}
+
 // DeleteSubtitle handles deleting a subtitle
 func (h *SubtitleHandler) DeleteSubtitle(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
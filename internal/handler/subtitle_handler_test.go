@@ -9,11 +9,15 @@ import (
 	"time"
 
 	"subtitle-translator/internal/models"
+	"subtitle-translator/internal/service"
+	"subtitle-translator/pkg/translator"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type fakeSubtitleService struct {
+	timing       translator.TimingAdjustment
 	called       bool
 	url          string
 	format       string
@@ -22,11 +26,25 @@ type fakeSubtitleService struct {
 	referer      string
 	isRefresh    bool
 	isLock       bool
+	opts         translator.Options
 	result       *models.SubtitleWithContent
 	translateErr error
+	alignErr     error
+	revisionErr  error
+	revision     int
+	author       string
+	cueRef       string
+	cueEdit      translator.CueEdit
+	cueRevision  string
+	cueErr       error
+	sourceErr    error
+	ttlSeconds   int64
+	ttlErr       error
+	updateErr    error
+	timingErr    error
 }
 
-func (f *fakeSubtitleService) TranslateSubtitle(url, format, targetLang, sourceLang, referer string, isRefresh, isLock bool) (*models.SubtitleWithContent, error) {
+func (f *fakeSubtitleService) TranslateSubtitle(url, format, targetLang, sourceLang, referer string, isRefresh, isLock bool, opts translator.Options) (*models.SubtitleWithContent, error) {
 	f.called = true
 	f.url = url
 	f.format = format
@@ -35,6 +53,7 @@ func (f *fakeSubtitleService) TranslateSubtitle(url, format, targetLang, sourceL
 	f.referer = referer
 	f.isRefresh = isRefresh
 	f.isLock = isLock
+	f.opts = opts
 
 	if f.translateErr != nil {
 		return nil, f.translateErr
@@ -54,14 +73,33 @@ func (f *fakeSubtitleService) GetSubtitleByID(id uint) (*models.SubtitleWithCont
 	return nil, nil
 }
 
-func (f *fakeSubtitleService) UpdateSubtitle(id uint, content string) (*models.SubtitleWithContent, error) {
-	return nil, nil
+func (f *fakeSubtitleService) UpdateSubtitle(id uint, content, author string) (*models.SubtitleWithContent, error) {
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	return &models.SubtitleWithContent{ID: id, Content: content}, nil
 }
 
 func (f *fakeSubtitleService) DeleteSubtitle(id uint) error {
 	return nil
 }
 
+func (f *fakeSubtitleService) TranslateHLSMaster(masterURL, subtitleURL, format, targetLang, sourceLang, referer, baseURL string) (string, *models.SubtitleWithContent, error) {
+	return "", f.result, nil
+}
+
+func (f *fakeSubtitleService) GetSubtitlePlaylist(id uint, baseURL string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeSubtitleService) ListMKVSubtitleTracks(url, referer string, upload *translator.MKVSource) ([]translator.MKVTrack, error) {
+	return nil, nil
+}
+
+func (f *fakeSubtitleService) TranslateMKVSubtitle(url, referer string, upload *translator.MKVSource, track uint64, targetLang, sourceLang string, isRefresh, isLock bool) (*models.SubtitleWithContent, error) {
+	return f.result, nil
+}
+
 func TestTranslateSubtitle_RequestWithRefreshFalse(t *testing.T) {
 	app := fiber.New()
 
@@ -192,3 +230,726 @@ func TestTranslateSubtitle_RequestWithRefreshTrue(t *testing.T) {
 		t.Fatalf("is_refresh should be true when payload sets true")
 	}
 }
+
+func TestTranslateSubtitle_BilingualOptionsArePassed(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 1, Variant: "bilingual"}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt","output":"bilingual","bilingual_order":"translation_first"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	if stub.opts.Output != "bilingual" || stub.opts.BilingualOrder != "translation_first" || stub.opts.OriginalClass != "original" {
+		t.Fatalf("unexpected options passed to service: %#v", stub.opts)
+	}
+}
+
+func TestTranslateSubtitle_RejectsUnknownOutput(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt","output":"karaoke"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+
+	if stub.called {
+		t.Fatalf("service should not be called for an invalid output mode")
+	}
+}
+
+func TestTranslateSubtitle_RomanizedOutput(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 1, Variant: "romanized"}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate?output=romanized&romanized_under=original", bytes.NewReader([]byte(`{"url":"https://example.com/a.vtt","format":"vtt"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	if stub.opts.Output != "romanized" || stub.opts.RomanizedUnder != "original" || stub.opts.OriginalClass != "original" {
+		t.Fatalf("unexpected options passed to service: %#v", stub.opts)
+	}
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt","output":"romanized","romanized_under":"above"}`)
+	req = httptest.NewRequest("POST", "/api/v1/subtitles/translate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code for invalid romanized_under: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func TestTranslateSubtitle_LongCuesSplitFromQuery(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 1}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate?long_cues=split", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	if stub.opts.LongCues != "split" {
+		t.Fatalf("expected long_cues=split to be passed, got %#v", stub.opts)
+	}
+
+	body = []byte(`{"url":"https://example.com/a.vtt","format":"vtt","long_cues":"truncate"}`)
+	req = httptest.NewRequest("POST", "/api/v1/subtitles/translate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code for invalid long_cues: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func TestTranslateSubtitle_PassesReadingSpeed(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 1}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt","max_cps":17}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate?max_extension_ms=800", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	if stub.opts.MaxCPS != 17 || stub.opts.MaxExtensionMS != 800 {
+		t.Fatalf("expected reading speed options to be passed, got %#v", stub.opts)
+	}
+}
+
+func (f *fakeSubtitleService) AdjustTiming(id uint, adj translator.TimingAdjustment) (*models.SubtitleWithContent, error) {
+	f.called = true
+	f.timing = adj
+	if f.timingErr != nil {
+		return nil, f.timingErr
+	}
+	return f.result, nil
+}
+
+func TestAdjustTiming_ParsesScaleSyncPoints(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 3}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/:id/timing", h.AdjustTiming)
+
+	body := []byte(`{"operation":"scale","sync":[{"from":"00:00:10.000","to":"00:00:11.000"},{"from":"01:40.000","to":"01:44.000"}]}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/3/timing", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	if stub.timing.Operation != "scale" || stub.timing.Sync[1].From != 100*time.Second || stub.timing.Sync[1].To != 104*time.Second {
+		t.Fatalf("unexpected adjustment passed to service: %#v", stub.timing)
+	}
+}
+
+func TestAdjustTiming_RejectsInvalidAdjustment(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/:id/timing", h.AdjustTiming)
+
+	for _, body := range []string{
+		`{"operation":"shift"}`,
+		`{"operation":"scale","sync":[{"from":"00:00:10.000","to":"00:00:11.000"}]}`,
+		`{"operation":"framerate","from_fps":25}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/subtitles/3/timing", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Fatalf("unexpected status code for %s: got %d want %d", body, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+
+	if stub.called {
+		t.Fatalf("service should not be called for an invalid adjustment")
+	}
+}
+
+func (f *fakeSubtitleService) ValidateContent(content, format string, opts translator.LintOptions) (*translator.LintResult, error) {
+	f.called = true
+	f.format = format
+	return translator.Lint(content, format, opts)
+}
+
+func (f *fakeSubtitleService) ValidateStoredSubtitles(opts translator.LintOptions) ([]models.SubtitleValidation, error) {
+	f.called = true
+	return nil, nil
+}
+
+func TestValidateSubtitle_ReturnsFindings(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/validate", h.ValidateSubtitle)
+
+	body := []byte(`{"content":"WEBVTT\n\n00:00:02.000 --> 00:00:01.000\nBackwards\n"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	var payload struct {
+		Data translator.LintResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stub.format != "vtt" || len(payload.Data.Findings) != 1 || payload.Data.Findings[0].Rule != translator.RuleNonPositiveTiming {
+		t.Fatalf("unexpected findings: %+v", payload.Data.Findings)
+	}
+}
+
+func (f *fakeSubtitleService) AlignTiming(id uint, referenceURL, format, referer string) (*models.SubtitleWithContent, *translator.Alignment, error) {
+	f.called = true
+	f.url = referenceURL
+	f.format = format
+	if f.alignErr != nil {
+		return nil, nil, f.alignErr
+	}
+	return f.result, &translator.Alignment{Offset: 2500 * time.Millisecond, Scale: 1}, nil
+}
+
+func TestAlignTiming_ReportsAlignment(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 5}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/:id/align", h.AlignTiming)
+
+	body := []byte(`{"reference_url":"https://example.com/en.ass","format":"ass"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/5/align", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	var payload struct {
+		Data struct {
+			Alignment translator.Alignment `json:"alignment"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stub.url != "https://example.com/en.ass" || stub.format != "ass" || payload.Data.Alignment.Offset != 2500*time.Millisecond {
+		t.Fatalf("unexpected alignment call or response: %+v %+v", stub, payload.Data.Alignment)
+	}
+}
+
+func TestAlignTiming_UnalignableReferenceReturns422(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{alignErr: translator.ErrAlignmentFailed}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/:id/align", h.AlignTiming)
+
+	body := []byte(`{"reference_url":"https://example.com/en.vtt"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/5/align", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusUnprocessableEntity)
+	}
+}
+
+func TestTimingEndpoints_UnknownSubtitleReturns404(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{timingErr: gorm.ErrRecordNotFound, alignErr: gorm.ErrRecordNotFound}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/:id/timing", h.AdjustTiming)
+	app.Post("/api/v1/subtitles/:id/align", h.AlignTiming)
+
+	for path, body := range map[string]string{
+		"/api/v1/subtitles/99/timing": `{"operation":"shift","offset_ms":500}`,
+		"/api/v1/subtitles/99/align":  `{"reference_url":"https://example.com/en.vtt"}`,
+	} {
+		req := httptest.NewRequest("POST", path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Fatalf("%s: unexpected status code: got %d want %d", path, resp.StatusCode, fiber.StatusNotFound)
+		}
+	}
+}
+
+func TestTranslateSubtitle_SDHOption(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 1}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt","sdh":"strip"}`)
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || stub.opts.SDH != "strip" {
+		t.Fatalf("expected sdh=strip to be passed, got status %d opts %#v", resp.StatusCode, stub.opts)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/subtitles/translate?sdh=hide", bytes.NewReader([]byte(`{"url":"https://example.com/a.vtt","format":"vtt"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code for invalid sdh: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func TestTranslateSubtitle_LyricsOption(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 1}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/translate", h.TranslateSubtitle)
+
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/translate?lyrics=romanized", bytes.NewReader([]byte(`{"url":"https://example.com/a.vtt","format":"vtt"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || stub.opts.Lyrics != "romanized" {
+		t.Fatalf("expected lyrics=romanized to be passed, got status %d opts %#v", resp.StatusCode, stub.opts)
+	}
+
+	body := []byte(`{"url":"https://example.com/a.vtt","format":"vtt","lyrics":"skip"}`)
+	req = httptest.NewRequest("POST", "/api/v1/subtitles/translate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code for invalid lyrics: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func (f *fakeSubtitleService) ListRevisions(id uint) ([]models.SubtitleRevision, error) {
+	return nil, f.revisionErr
+}
+
+func (f *fakeSubtitleService) DiffRevisions(id uint, from, to int) ([]translator.CueChange, error) {
+	if f.revisionErr != nil {
+		return nil, f.revisionErr
+	}
+	return []translator.CueChange{{Type: translator.CueModified, FromIndex: 1, ToIndex: 1}}, nil
+}
+
+func (f *fakeSubtitleService) RollbackSubtitle(id uint, number int, author string) (*models.SubtitleWithContent, error) {
+	f.revision = number
+	f.author = author
+	if f.revisionErr != nil {
+		return nil, f.revisionErr
+	}
+	return f.result, nil
+}
+
+func TestDiffRevisions_ValidatesRevisionNumbers(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Get("/api/v1/subtitles/:id/revisions/diff", h.DiffRevisions)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/subtitles/5/revisions/diff?from=1&to=2", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/subtitles/5/revisions/diff?from=1", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code for a missing revision number: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+
+	stub.revisionErr = service.ErrRevisionNotFound
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/subtitles/5/revisions/diff?from=1&to=9", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("unexpected status code for an unknown revision: got %d want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+}
+
+func TestRollbackSubtitle_PassesRevisionAndAuthor(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{result: &models.SubtitleWithContent{ID: 5}}
+
+	h := NewSubtitleHandler(stub)
+	app.Post("/api/v1/subtitles/:id/revisions/:number/rollback", h.RollbackSubtitle)
+
+	req := httptest.NewRequest("POST", "/api/v1/subtitles/5/revisions/3/rollback", bytes.NewReader([]byte(`{"author":"editor@example.com"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || stub.revision != 3 || stub.author != "editor@example.com" {
+		t.Fatalf("expected rollback to revision 3 by editor, got status %d revision %d author %q", resp.StatusCode, stub.revision, stub.author)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/api/v1/subtitles/5/revisions/first/rollback", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("unexpected status code for an invalid revision: got %d want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func (f *fakeSubtitleService) ListCues(id uint) (*models.SubtitleCues, error) {
+	return &models.SubtitleCues{ID: id, Revision: "abc"}, nil
+}
+
+func (f *fakeSubtitleService) EditCue(id uint, ref string, edit translator.CueEdit, revision, author string) (*models.SubtitleCues, error) {
+	f.cueRef, f.cueEdit, f.cueRevision, f.author = ref, edit, revision, author
+	if f.cueErr != nil {
+		return nil, f.cueErr
+	}
+	return &models.SubtitleCues{ID: id, Revision: "def"}, nil
+}
+
+func (f *fakeSubtitleService) InsertCue(id uint, edit translator.CueEdit, revision, author string) (*models.SubtitleCues, error) {
+	f.cueEdit, f.cueRevision, f.author = edit, revision, author
+	return &models.SubtitleCues{ID: id, Revision: "def"}, f.cueErr
+}
+
+func (f *fakeSubtitleService) DeleteCue(id uint, ref, revision, author string) (*models.SubtitleCues, error) {
+	f.cueRef, f.cueRevision, f.author = ref, revision, author
+	if f.cueErr != nil {
+		return nil, f.cueErr
+	}
+	return &models.SubtitleCues{ID: id, Revision: "def"}, nil
+}
+
+func (f *fakeSubtitleService) GetSubtitleSource(id uint) (*models.SubtitleSource, error) {
+	if f.sourceErr != nil {
+		return nil, f.sourceErr
+	}
+	return &models.SubtitleSource{ID: 1, ContentHash: "abc", Format: "ass", Encoding: "utf-8", Content: "[Script Info]\n"}, nil
+}
+
+func (f *fakeSubtitleService) ListVariants(url string) ([]models.Subtitle, error) {
+	f.url = url
+	return []models.Subtitle{{ID: 1, URL: url, Fingerprint: "target_lang=id"}, {ID: 2, URL: url, Fingerprint: "target_lang=en"}}, nil
+}
+
+func (f *fakeSubtitleService) SetSubtitleTTL(id uint, ttlSeconds int64) (*models.Subtitle, error) {
+	f.ttlSeconds = ttlSeconds
+	if f.ttlErr != nil {
+		return nil, f.ttlErr
+	}
+	return &models.Subtitle{ID: id, TTLSeconds: ttlSeconds}, nil
+}
+
+func (f *fakeSubtitleService) CacheReport(limit int) (*models.CacheReport, error) {
+	return &models.CacheReport{}, nil
+}
+
+func (f *fakeSubtitleService) EnforceStorageQuota() (*models.CacheEviction, error) {
+	return nil, nil
+}
+
+func TestEditCue_ParsesEditAndRevision(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Patch("/api/v1/subtitles/:id/cues/:cue", h.EditCue)
+
+	body := []byte(`{"start":"00:00:01.500","text":"Gimana kabarnya?","author":"editor"}`)
+	req := httptest.NewRequest("PATCH", "/api/v1/subtitles/5/cues/id:intro%201", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"abc"`)
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get("ETag") != `"def"` {
+		t.Fatalf("unexpected response: status %d etag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if stub.cueRef != "id:intro 1" || stub.cueRevision != "abc" || stub.author != "editor" {
+		t.Fatalf("unexpected edit target: ref %q revision %q author %q", stub.cueRef, stub.cueRevision, stub.author)
+	}
+	if stub.cueEdit.Start == nil || *stub.cueEdit.Start != 1500*time.Millisecond || stub.cueEdit.End != nil || *stub.cueEdit.Text != "Gimana kabarnya?" {
+		t.Fatalf("unexpected cue edit: %+v", stub.cueEdit)
+	}
+}
+
+func TestEditCue_StatusCodes(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Patch("/api/v1/subtitles/:id/cues/:cue", h.EditCue)
+	app.Delete("/api/v1/subtitles/:id/cues/:cue", h.DeleteCue)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		err    error
+		want   int
+	}{
+		{"missing revision", "PATCH", "/api/v1/subtitles/5/cues/2", `{"text":"Hai"}`, nil, fiber.StatusPreconditionRequired},
+		{"bad timestamp", "PATCH", "/api/v1/subtitles/5/cues/2", `{"end":"soon","revision":"abc"}`, nil, fiber.StatusBadRequest},
+		{"stale revision", "PATCH", "/api/v1/subtitles/5/cues/2", `{"text":"Hai","revision":"old"}`, service.ErrContentChanged, fiber.StatusConflict},
+		{"invalid cue", "PATCH", "/api/v1/subtitles/5/cues/2", `{"text":" ","revision":"abc"}`, translator.ErrInvalidCue, fiber.StatusBadRequest},
+		{"unknown cue", "DELETE", "/api/v1/subtitles/5/cues/99?revision=abc", "", translator.ErrCueNotFound, fiber.StatusNotFound},
+		{"malformed stored cues", "DELETE", "/api/v1/subtitles/5/cues/2?revision=abc", "", service.ErrMalformedTimestamp, fiber.StatusBadRequest},
+		{"deleted", "DELETE", "/api/v1/subtitles/5/cues/2?revision=abc", "", nil, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		stub.cueErr = tt.err
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestUpdateSubtitle_StatusCodes(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Put("/api/v1/subtitles/:id", h.UpdateSubtitle)
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"updated", nil, fiber.StatusOK},
+		{"malformed timestamps", service.ErrMalformedTimestamp, fiber.StatusBadRequest},
+		{"unknown subtitle", gorm.ErrRecordNotFound, fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		stub.updateErr = tt.err
+		req := httptest.NewRequest("PUT", "/api/v1/subtitles/5", bytes.NewReader([]byte(`{"content":"WEBVTT\n"}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestGetSubtitleSource_StatusCodes(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Get("/api/v1/subtitles/:id/source", h.GetSubtitleSource)
+
+	tests := []struct {
+		name string
+		path string
+		err  error
+		want int
+	}{
+		{"stored", "/api/v1/subtitles/5/source", nil, fiber.StatusOK},
+		{"invalid id", "/api/v1/subtitles/abc/source", nil, fiber.StatusBadRequest},
+		{"not stored", "/api/v1/subtitles/5/source", service.ErrSourceNotStored, fiber.StatusNotFound},
+		{"unknown subtitle", "/api/v1/subtitles/5/source", gorm.ErrRecordNotFound, fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		stub.sourceErr = tt.err
+		resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestListVariants_RequiresURL(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Get("/api/v1/subtitles/variants", h.ListVariants)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/subtitles/variants", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected 400 without a url, got %d", resp.StatusCode)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/subtitles/variants?url=https%3A%2F%2Fcdn.example%2Fep1.vtt", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || stub.url != "https://cdn.example/ep1.vtt" {
+		t.Fatalf("expected the variants of the url, got %d for %q", resp.StatusCode, stub.url)
+	}
+}
+
+func TestSetSubtitleTTL_StatusCodes(t *testing.T) {
+	app := fiber.New()
+	stub := &fakeSubtitleService{}
+
+	h := NewSubtitleHandler(stub)
+	app.Put("/api/v1/subtitles/:id/ttl", h.SetSubtitleTTL)
+
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"set", `{"ttl_seconds":3600}`, nil, fiber.StatusOK},
+		{"missing ttl", `{}`, nil, fiber.StatusBadRequest},
+		{"invalid ttl", `{"ttl_seconds":-5}`, service.ErrInvalidTTL, fiber.StatusBadRequest},
+		{"unknown subtitle", `{"ttl_seconds":0}`, gorm.ErrRecordNotFound, fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		stub.ttlErr = tt.err
+		req := httptest.NewRequest("PUT", "/api/v1/subtitles/5/ttl", bytes.NewReader([]byte(tt.body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+	if stub.ttlSeconds != 0 {
+		t.Fatalf("expected the last ttl to be passed on, got %d", stub.ttlSeconds)
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"subtitle-translator/pkg/translator"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,18 +9,34 @@ import (
 
 // Subtitle represents subtitle metadata in database with file path
 type Subtitle struct {
-	ID         uint           `gorm:"primaryKey" json:"id"`
-	SubtitleID string         `gorm:"uniqueIndex;size:32;not null" json:"subtitle_id"`
-	URL        string         `gorm:"type:text;not null" json:"url"`
-	TargetLang string         `gorm:"size:10;not null;index" json:"target_lang"`
-	SourceLang string         `gorm:"size:10;not null" json:"source_lang"`
-	Format     string         `gorm:"size:10;not null" json:"format"`
-	FilePath   string         `gorm:"type:varchar(500);not null" json:"file_path"` // Path to VTT file
-	FileSize   int64          `gorm:"not null" json:"file_size"`
-	IsLock     bool           `gorm:"not null;default:false;index" json:"is_lock"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	SubtitleID         string         `gorm:"uniqueIndex;size:32;not null" json:"subtitle_id"`
+	URL                string         `gorm:"type:text;not null" json:"url"`
+	TargetLang         string         `gorm:"size:10;not null;index" json:"target_lang"`
+	SourceLang         string         `gorm:"size:10;not null" json:"source_lang"`
+	Format             string         `gorm:"size:10;not null" json:"format"`
+	Variant            string         `gorm:"size:20;not null;default:translated" json:"variant"`       // translated, bilingual, romanized
+	Fingerprint        string         `gorm:"type:varchar(500);not null;default:''" json:"fingerprint"` // Canonical translation options the subtitle ID is made of
+	FilePath           string         `gorm:"type:varchar(500);not null" json:"file_path"`              // Storage key of the VTT content
+	FileSize           int64          `gorm:"not null" json:"file_size"`
+	IsLock             bool           `gorm:"not null;default:false;index" json:"is_lock"`
+	LintFindings       int            `gorm:"not null;default:0" json:"lint_findings"`    // Lint findings in the stored content
+	Lyrics             string         `gorm:"type:text" json:"-"`                         // JSON list of cues detected as song lyrics
+	CueLocks           string         `gorm:"type:text" json:"-"`                         // JSON list of human-corrected cues kept on refresh
+	Timing             string         `gorm:"type:text" json:"-"`                         // JSON list of timing adjustments replayed after every translation
+	SourceHash         string         `gorm:"size:64;index" json:"source_hash,omitempty"` // Content hash of the stored source subtitle
+	SourceETag         string         `gorm:"size:255" json:"-"`                          // Cache validators of the last source fetch
+	SourceLastModified string         `gorm:"size:64" json:"-"`
+	TTLSeconds         int64          `gorm:"not null;default:0" json:"ttl_seconds"` // 0 uses the configured TTL, -1 never expires
+	ExpiresAt          *time.Time     `gorm:"index" json:"expires_at"`               // When the content becomes stale and is refreshed on access
+	LastAccessedAt     *time.Time     `gorm:"index" json:"last_accessed_at"`
+	AccessCount        int64          `gorm:"not null;default:0" json:"access_count"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Source, when set, is a fetched source saved in the same transaction as the row, which then points at it
+	Source *SubtitleSource `gorm:"-" json:"-"`
 }
 
 // TableName specifies the table name
@@ -29,16 +46,144 @@ func (Subtitle) TableName() string {
 
 // SubtitleWithContent represents subtitle with loaded content
 type SubtitleWithContent struct {
-	ID         uint      `json:"id"`
-	SubtitleID string    `json:"subtitle_id"`
-	URL        string    `json:"url"`
-	TargetLang string    `json:"target_lang"`
-	SourceLang string    `json:"source_lang"`
-	Format     string    `json:"format"`
-	FilePath   string    `json:"file_path"`
-	Content    string    `json:"content"` // Loaded from file
-	FileSize   int64     `json:"file_size"`
-	IsLock     bool      `json:"is_lock"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID          uint       `json:"id"`
+	SubtitleID  string     `json:"subtitle_id"`
+	URL         string     `json:"url"`
+	TargetLang  string     `json:"target_lang"`
+	SourceLang  string     `json:"source_lang"`
+	Format      string     `json:"format"`
+	Variant     string     `json:"variant"`
+	Fingerprint string     `json:"fingerprint"`
+	FilePath    string     `json:"file_path"`
+	FileURL     string     `json:"file_url"` // Download URL of the stored content
+	Content     string     `json:"content"`  // Loaded from file
+	FileSize    int64      `json:"file_size"`
+	IsLock      bool       `json:"is_lock"`
+	SourceHash  string     `json:"source_hash,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Lyrics   []translator.CueNote          `json:"lyrics,omitempty"`    // Song lyric cues detected when the content was translated
+	CueLocks []translator.CueLock          `json:"cue_locks,omitempty"` // Manually corrected cues kept when refreshing
+	Timing   []translator.TimingAdjustment `json:"timing,omitempty"`    // Timing adjustments kept when refreshing
+	Report   *translator.Report            `json:"report,omitempty"`    // Set when the content was translated by this request
+
+	// Set by a refresh: whether the source was unchanged, so nothing was translated, or which source cues changed
+	SourceUnchanged bool                   `json:"source_unchanged,omitempty"`
+	SourceChanges   []translator.CueChange `json:"source_changes,omitempty"`
+
+	// Lint findings counted when the content was last translated or edited
+	LintFindings int `json:"lint_findings"`
+
+	// Set when expired content was served while a background refresh runs
+	Stale bool `json:"stale,omitempty"`
+}
+
+// SubtitleCues lists the cues of a stored subtitle. Revision is the content hash cue edits are made against.
+type SubtitleCues struct {
+	ID       uint                 `json:"id"`
+	Revision string               `json:"revision"`
+	Cues     []translator.CueView `json:"cues"`
+}
+
+// SubtitleValidation is the lint result for one stored subtitle
+type SubtitleValidation struct {
+	ID         uint                 `json:"id"`
+	SubtitleID string               `json:"subtitle_id"`
+	Findings   []translator.Finding `json:"findings"`
+	Fixed      int                  `json:"fixed"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// Revision sources: what produced a stored version of subtitle content
+const (
+	RevisionTranslate   = "translate"
+	RevisionRefresh     = "refresh"
+	RevisionManual      = "manual"
+	RevisionPostprocess = "postprocess"
+	RevisionTiming      = "timing"
+	RevisionAlign       = "align"
+	RevisionLintFix     = "lint_fix"
+	RevisionRollback    = "rollback"
+	RevisionImport      = "import" // Content stored before revisions were recorded
+)
+
+// ContentChange describes what changed stored content and, for manual edits, who
+type ContentChange struct {
+	Source string
+	Author string
+	// BaseHash, when set, is the content hash the change was made against; the update fails if it changed since
+	BaseHash string
+	// Subtitle, when set, is the row saved together with the content, so metadata such as cue locks or source
+	// validators is never out of step with it
+	Subtitle *Subtitle
+}
+
+// SubtitleRevision is one stored version of a subtitle's content
+type SubtitleRevision struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	SubtitleID  uint      `gorm:"not null;uniqueIndex:idx_subtitle_revision" json:"-"`
+	Number      int       `gorm:"not null;uniqueIndex:idx_subtitle_revision" json:"number"`
+	ContentHash string    `gorm:"size:64;not null" json:"content_hash"` // SHA-256 of the content
+	Source      string    `gorm:"size:20;not null" json:"source"`
+	Author      string    `gorm:"size:100" json:"author,omitempty"`
+	FilePath    string    `gorm:"type:varchar(500);not null" json:"-"` // Storage key of the revision content
+	FileSize    int64     `gorm:"not null" json:"file_size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name
+func (SubtitleRevision) TableName() string {
+	return "subtitle_revisions"
+}
+
+// SubtitleSource is a fetched source subtitle as it was before translation, stored once per content hash
+type SubtitleSource struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ContentHash    string    `gorm:"size:64;not null;uniqueIndex" json:"content_hash"` // SHA-256 of the decoded content
+	NormalizedHash string    `gorm:"size:64;index" json:"normalized_hash"`             // SHA-256 ignoring line endings and blank space
+	Format         string    `gorm:"size:10;not null" json:"format"`                   // Detected format: vtt or ass
+	Encoding       string    `gorm:"size:20;not null" json:"encoding"`                 // Encoding the source was fetched in
+	FilePath       string    `gorm:"type:varchar(500);not null" json:"-"`              // Storage key of the source content
+	FileSize       int64     `gorm:"not null" json:"file_size"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Content string `gorm:"-" json:"content,omitempty"` // Loaded from file
+}
+
+// TableName specifies the table name
+func (SubtitleSource) TableName() string {
+	return "subtitle_sources"
+}
+
+// CacheStats summarizes the stored subtitles and the storage their content takes
+type CacheStats struct {
+	Subtitles     int64 `json:"subtitles"`
+	Locked        int64 `json:"locked"`
+	Expired       int64 `json:"expired"`        // Unlocked subtitles past their expiry
+	SubtitleBytes int64 `json:"subtitle_bytes"` // Current content of the subtitles
+	RevisionBytes int64 `json:"revision_bytes"` // Stored revision objects
+	SourceBytes   int64 `json:"source_bytes"`   // Stored source subtitles
+	TotalBytes    int64 `json:"total_bytes"`
+}
+
+// CacheEviction is the outcome of one storage quota enforcement
+type CacheEviction struct {
+	At         time.Time `json:"at"`
+	Evicted    int       `json:"evicted"`
+	FreedBytes int64     `json:"freed_bytes"`
+	UsedBytes  int64     `json:"used_bytes"` // Storage used after evicting
+	Error      string    `json:"error,omitempty"`
+}
+
+// CacheReport describes the cache policy, the storage used and the subtitles next in line for eviction
+type CacheReport struct {
+	TTLSeconds   int64          `json:"ttl_seconds"`
+	QuotaBytes   int64          `json:"quota_bytes"`
+	OverQuota    bool           `json:"over_quota"`
+	Stats        CacheStats     `json:"stats"`
+	Refreshing   int            `json:"refreshing"`          // Background refreshes running
+	Candidates   []Subtitle     `json:"eviction_candidates"` // Least recently used evictable subtitles first
+	LastEviction *CacheEviction `json:"last_eviction,omitempty"`
 }
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"fmt"
+	"subtitle-translator/internal/models"
+	"subtitle-translator/internal/storage"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reconcileGracePeriod keeps recent files out of the orphan list: a Create on another replica writes its content
+// before its row is committed.
+const reconcileGracePeriod = 10 * time.Minute
+
+// ReconcileReport lists where stored content and database rows disagree.
+type ReconcileReport struct {
+	// OrphanedFiles are stored subtitle, revision and source objects no row points at, including temporary files
+	// left by interrupted writes.
+	OrphanedFiles []string `json:"orphaned_files"`
+	// MissingContent are rows whose content is not in the store.
+	MissingContent []MissingContent `json:"missing_content"`
+	Repaired       bool             `json:"repaired"`
+}
+
+// MissingContent identifies a row pointing at content that does not exist.
+type MissingContent struct {
+	ID         uint   `json:"id"`
+	SubtitleID string `json:"subtitle_id"`
+	FilePath   string `json:"file_path"`
+}
+
+// Empty reports whether content and rows agree.
+func (r *ReconcileReport) Empty() bool {
+	return len(r.OrphanedFiles) == 0 && len(r.MissingContent) == 0
+}
+
+// Reconcile compares the subtitle, revision and source rows with the stored content. With repair, orphaned files
+// are deleted and subtitle rows without content are removed for good, so the next request for them translates
+// again.
+func Reconcile(db *gorm.DB, store storage.ContentStore, repair bool) (*ReconcileReport, error) {
+	var subtitles []models.Subtitle
+	if err := db.Select("id", "subtitle_id", "file_path").Find(&subtitles).Error; err != nil {
+		return nil, fmt.Errorf("failed to load subtitles: %w", err)
+	}
+	var referenced []string
+	if err := db.Model(&models.SubtitleRevision{}).Distinct().Pluck("file_path", &referenced).Error; err != nil {
+		return nil, fmt.Errorf("failed to load revisions: %w", err)
+	}
+	var sources []string
+	if err := db.Model(&models.SubtitleSource{}).Pluck("file_path", &sources).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sources: %w", err)
+	}
+	referenced = append(referenced, sources...)
+
+	var objects []storage.ObjectInfo
+	for _, prefix := range []string{ContentPrefix, RevisionPrefix, SourcePrefix} {
+		listed, err := store.List(prefix + "/")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, listed...)
+	}
+
+	report := planReconcile(subtitles, referenced, objects, time.Now())
+	if !repair || report.Empty() {
+		return report, nil
+	}
+
+	for _, key := range report.OrphanedFiles {
+		if err := store.Delete(key); err != nil {
+			return report, fmt.Errorf("failed to delete orphaned file %s: %w", key, err)
+		}
+	}
+	for _, missing := range report.MissingContent {
+		if err := db.Unscoped().Delete(&models.Subtitle{}, missing.ID).Error; err != nil {
+			return report, fmt.Errorf("failed to delete subtitle %d without content: %w", missing.ID, err)
+		}
+	}
+	report.Repaired = true
+	return report, nil
+}
+
+// planReconcile finds the subtitles whose content is not among objects, and the objects neither a subtitle nor one
+// of the referenced revision and source keys points at.
+func planReconcile(subtitles []models.Subtitle, referencedKeys []string, objects []storage.ObjectInfo, now time.Time) *ReconcileReport {
+	report := &ReconcileReport{OrphanedFiles: []string{}, MissingContent: []MissingContent{}}
+
+	stored := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		stored[object.Key] = true
+	}
+
+	referenced := make(map[string]bool, len(subtitles)+len(referencedKeys))
+	for _, key := range referencedKeys {
+		referenced[key] = true
+	}
+	for _, subtitle := range subtitles {
+		key := NormalizeFilePath(subtitle.FilePath)
+		referenced[key] = true
+		if !stored[key] {
+			report.MissingContent = append(report.MissingContent, MissingContent{ID: subtitle.ID, SubtitleID: subtitle.SubtitleID, FilePath: subtitle.FilePath})
+		}
+	}
+
+	for _, object := range objects {
+		if referenced[object.Key] || now.Sub(object.ModTime) < reconcileGracePeriod {
+			continue
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, object.Key)
+	}
+	return report
+}
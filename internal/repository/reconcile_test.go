@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"subtitle-translator/internal/models"
+	"subtitle-translator/internal/storage"
+)
+
+func TestPlanReconcile_FindsOrphansAndMissingContent(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-time.Hour)
+
+	subtitles := []models.Subtitle{
+		{ID: 1, SubtitleID: "aaa", FilePath: "subtitles/aaa.vtt"},
+		{ID: 2, SubtitleID: "bbb", FilePath: "storage/subtitles/bbb.vtt"},
+		{ID: 3, SubtitleID: "ccc", FilePath: "subtitles/ccc.vtt"},
+	}
+	objects := []storage.ObjectInfo{
+		{Key: "subtitles/aaa.vtt", ModTime: old},
+		{Key: "subtitles/bbb.vtt", ModTime: old},
+		{Key: "subtitles/zzz.vtt", ModTime: old},
+		{Key: "subtitles/.aaa.vtt.tmp-123", ModTime: old},
+		{Key: "subtitles/new.vtt", ModTime: now.Add(-time.Minute)},
+		{Key: "revisions/aaa/1111.vtt", ModTime: old},
+		{Key: "revisions/ddd/2222.vtt", ModTime: old},
+		{Key: "sources/3333.ass", ModTime: old},
+		{Key: "sources/4444.vtt", ModTime: old},
+	}
+	referenced := []string{"revisions/aaa/1111.vtt", "sources/3333.ass"}
+
+	report := planReconcile(subtitles, referenced, objects, now)
+
+	if len(report.MissingContent) != 1 || report.MissingContent[0].ID != 3 {
+		t.Fatalf("unexpected missing content: %+v", report.MissingContent)
+	}
+	want := []string{"subtitles/zzz.vtt", "subtitles/.aaa.vtt.tmp-123", "revisions/ddd/2222.vtt", "sources/4444.vtt"}
+	if strings.Join(report.OrphanedFiles, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected orphaned files: %v", report.OrphanedFiles)
+	}
+}
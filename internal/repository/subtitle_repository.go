@@ -1,45 +1,108 @@
 package repository
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"log"
 	"path/filepath"
+	"strings"
 	"subtitle-translator/internal/models"
+	"subtitle-translator/internal/storage"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-const StorageDir = "storage/subtitles"
+// ContentPrefix is the storage key prefix of subtitle content.
+const ContentPrefix = "subtitles"
+
+// RevisionPrefix is the storage key prefix of revision content.
+const RevisionPrefix = "revisions"
+
+// SourcePrefix is the storage key prefix of fetched source subtitles.
+const SourcePrefix = "sources"
+
+// ErrContentChanged is returned by UpdateContent when the stored content no longer has the expected hash.
+var ErrContentChanged = errors.New("content changed since it was read")
+
+// errSourceContent marks a source whose content could not be written to the store.
+var errSourceContent = errors.New("failed to save source content")
+
+// legacyStorageRoot prefixed file paths stored before content went through a ContentStore.
+const legacyStorageRoot = "storage/"
 
 type SubtitleRepository interface {
 	Create(subtitle *models.Subtitle, content string) error
 	GetBySubtitleID(subtitleID string) (*models.Subtitle, error)
 	GetAll(page, limit int, targetLang string) ([]models.Subtitle, int64, error)
 	GetByID(id uint) (*models.Subtitle, error)
+	ListByURL(url string) ([]models.Subtitle, error)
+	ListWithoutFingerprint(afterID uint, limit int) ([]models.Subtitle, error)
 	Update(subtitle *models.Subtitle) error
-	UpdateContent(id uint, content string) error
+	UpdateContent(id uint, content string, change models.ContentChange) error
 	Delete(id uint) error
+	ListRevisions(subtitleID uint) ([]models.SubtitleRevision, error)
+	GetRevision(subtitleID uint, number int) (*models.SubtitleRevision, error)
+	SaveSource(source *models.SubtitleSource, content string) error
+	GetSource(contentHash string) (*models.SubtitleSource, error)
+	FindSourceByURL(url string) (*models.SubtitleSource, error)
+	FindBySourceContent(normalizedHash, targetLang string) ([]models.Subtitle, error)
+	Touch(id uint, at time.Time) error
+	SetMissingExpiry(ttl time.Duration) (int64, error)
+	CacheStats(now time.Time) (*models.CacheStats, error)
+	ListEvictable(limit int) ([]models.Subtitle, error)
+	Evict(id uint) (int64, error)
+	LoadContent(filePath string) (string, error)
+	FileURL(filePath string) string
 }
 
 type subtitleRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	store storage.ContentStore
 }
 
-func NewSubtitleRepository(db *gorm.DB) SubtitleRepository {
-	// Create storage directory
-	os.MkdirAll(StorageDir, 0755)
-	return &subtitleRepository{db: db}
+func NewSubtitleRepository(db *gorm.DB, store storage.ContentStore) SubtitleRepository {
+	return &subtitleRepository{db: db, store: store}
 }
 
+// Create inserts the row, with subtitle.Source when set, and writes the content inside one transaction. Content is
+// only written once the insert succeeded, and is removed again when the commit fails.
 func (r *subtitleRepository) Create(subtitle *models.Subtitle, content string) error {
-	// Save content to file
-	if err := ioutil.WriteFile(subtitle.FilePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to save content file: %w", err)
-	}
+	key := NormalizeFilePath(subtitle.FilePath)
+	written := false
+	var sourceKey string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if sourceKey, err = r.attachSource(tx, subtitle); err != nil {
+			return err
+		}
+
+		// Save metadata to database
+		if err := tx.Create(subtitle).Error; err != nil {
+			return err
+		}
+
+		// Save content to the store
+		if err := r.store.Put(key, []byte(content)); err != nil {
+			return fmt.Errorf("failed to save content file: %w", err)
+		}
+		written = true
 
-	// Save metadata to database
-	return r.db.Create(subtitle).Error
+		return r.addRevision(tx, subtitle, content, models.ContentChange{Source: models.RevisionTranslate})
+	})
+	if err != nil && written {
+		if cleanupErr := r.store.Delete(key); cleanupErr != nil {
+			log.Printf("Failed to remove content of uncommitted subtitle %s: %v", key, cleanupErr)
+		}
+	}
+	if err != nil {
+		r.removeUncommittedSource(sourceKey, subtitle.SourceHash)
+	}
+	return err
 }
 
 func (r *subtitleRepository) GetBySubtitleID(subtitleID string) (*models.Subtitle, error) {
@@ -89,54 +152,501 @@ func (r *subtitleRepository) GetByID(id uint) (*models.Subtitle, error) {
 	return &subtitle, nil
 }
 
+// ListByURL returns every stored translation of url, oldest first
+func (r *subtitleRepository) ListByURL(url string) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	err := r.db.Where("url = ?", url).Order("created_at ASC").Find(&subtitles).Error
+	return subtitles, err
+}
+
+// ListWithoutFingerprint returns up to limit subtitles stored before options fingerprints, by id after afterID
+func (r *subtitleRepository) ListWithoutFingerprint(afterID uint, limit int) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	err := r.db.Where("fingerprint = '' AND id > ?", afterID).Order("id ASC").Limit(limit).Find(&subtitles).Error
+	return subtitles, err
+}
+
 func (r *subtitleRepository) Update(subtitle *models.Subtitle) error {
 	return r.db.Save(subtitle).Error
 }
 
-func (r *subtitleRepository) UpdateContent(id uint, content string) error {
-	// Get subtitle to find file path
-	subtitle, err := r.GetByID(id)
+// UpdateContent replaces the content and its recorded size inside one transaction and records the change as a
+// revision. With change.Subtitle set, the whole row, and its Source when set, is saved in the same transaction. The
+// row stays locked until the
+// commit, so a change with a BaseHash only applies to the content it was made against. The store write is atomic,
+// and the previous content is put back when the commit fails.
+func (r *subtitleRepository) UpdateContent(id uint, content string, change models.ContentChange) error {
+	var key, sourceKey string
+	var previous []byte
+	written := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Get subtitle to find file path
+		var subtitle models.Subtitle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&subtitle, id).Error; err != nil {
+			return err
+		}
+		key = NormalizeFilePath(subtitle.FilePath)
+
+		previous, _ = r.store.Get(key)
+		if change.BaseHash != "" && ContentHash(string(previous)) != change.BaseHash {
+			return ErrContentChanged
+		}
+
+		// Update file size, and the rest of the metadata when given, in database
+		if change.Subtitle != nil {
+			if change.Subtitle.ID != subtitle.ID {
+				return fmt.Errorf("content change carries subtitle %d, not %d", change.Subtitle.ID, subtitle.ID)
+			}
+			var err error
+			if sourceKey, err = r.attachSource(tx, change.Subtitle); err != nil {
+				return err
+			}
+			change.Subtitle.FileSize = int64(len(content))
+			if err := tx.Save(change.Subtitle).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&subtitle).Update("file_size", int64(len(content))).Error; err != nil {
+			return err
+		}
+
+		// Content stored before revisions were recorded becomes the first revision
+		last, err := lastRevision(tx, subtitle.ID)
+		if err != nil {
+			return err
+		}
+		if last == nil && previous != nil {
+			if err := r.addRevision(tx, &subtitle, string(previous), models.ContentChange{Source: models.RevisionImport}); err != nil {
+				return err
+			}
+		}
+
+		// Update stored content
+		if err := r.store.Put(key, []byte(content)); err != nil {
+			return fmt.Errorf("failed to update content file: %w", err)
+		}
+		written = true
+
+		return r.addRevision(tx, &subtitle, content, change)
+	})
+	if err != nil && written && previous != nil {
+		if restoreErr := r.store.Put(key, previous); restoreErr != nil {
+			log.Printf("Failed to restore content of subtitle %s: %v", key, restoreErr)
+		}
+	}
+	if err != nil && change.Subtitle != nil {
+		r.removeUncommittedSource(sourceKey, change.Subtitle.SourceHash)
+	}
+	return err
+}
+
+// Delete soft-deletes the row and removes its revisions, and its source when no other subtitle uses it. Rows are
+// removed first and the content after the commit, so a failure can only leave orphaned files, which reconciliation
+// cleans up, and never a row without content.
+func (r *subtitleRepository) Delete(id uint) error {
+	_, err := r.remove(id, false)
+	return err
+}
+
+// ListRevisions returns the revisions of a subtitle, oldest first
+func (r *subtitleRepository) ListRevisions(subtitleID uint) ([]models.SubtitleRevision, error) {
+	var revisions []models.SubtitleRevision
+	err := r.db.Where("subtitle_id = ?", subtitleID).Order("number ASC").Find(&revisions).Error
+	return revisions, err
+}
+
+func (r *subtitleRepository) GetRevision(subtitleID uint, number int) (*models.SubtitleRevision, error) {
+	var revision models.SubtitleRevision
+	err := r.db.Where("subtitle_id = ? AND number = ?", subtitleID, number).First(&revision).Error
 	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// SaveSource stores a fetched source subtitle under its content hash and fills in the row. Sources are shared by
+// every translation of the same content, so saving one that is already stored only loads its row.
+func (r *subtitleRepository) SaveSource(source *models.SubtitleSource, content string) error {
+	source.Content = content
+	var key string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, err = r.saveSource(tx, source)
 		return err
+	})
+	if err != nil {
+		r.removeUncommittedSource(key, source.ContentHash)
 	}
+	return err
+}
+
+// saveSource stores source.Content and inserts its row within tx, unless the content is already stored. It returns
+// the key written to the store, which is removed again when tx does not commit.
+func (r *subtitleRepository) saveSource(tx *gorm.DB, source *models.SubtitleSource) (string, error) {
+	content := source.Content
+	source.ContentHash = ContentHash(content)
+	source.NormalizedHash = NormalizedContentHash(content)
 
-	// Update file content
-	if err := ioutil.WriteFile(subtitle.FilePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to update content file: %w", err)
+	var existing models.SubtitleSource
+	if err := tx.Where("content_hash = ?", source.ContentHash).First(&existing).Error; err == nil {
+		// Sources stored before the normalized hash was indexed get it now
+		if existing.NormalizedHash == "" {
+			if err := tx.Model(&existing).Update("normalized_hash", source.NormalizedHash).Error; err != nil {
+				return "", err
+			}
+		}
+		*source = existing
+		source.Content = content
+		return "", nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
 	}
 
-	// Update file size in database
-	subtitle.FileSize = int64(len(content))
-	return r.db.Save(subtitle).Error
+	// Content goes first, so a row never points at a missing object
+	source.FilePath = SourceFilePath(source.ContentHash, source.Format)
+	source.FileSize = int64(len(content))
+	if err := r.store.Put(source.FilePath, []byte(content)); err != nil {
+		return "", fmt.Errorf("%w: %v", errSourceContent, err)
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(source).Error; err != nil {
+		return source.FilePath, err
+	}
+	if source.ID == 0 {
+		// Saved concurrently by another request, whose row points at the same content
+		return "", nil
+	}
+	return source.FilePath, nil
 }
 
-func (r *subtitleRepository) Delete(id uint) error {
-	// Get subtitle to find file path
-	subtitle, err := r.GetByID(id)
+// attachSource saves subtitle.Source within tx and points the row at it. A source whose content cannot be stored
+// only costs offline re-translation, so the row is then saved without one.
+func (r *subtitleRepository) attachSource(tx *gorm.DB, subtitle *models.Subtitle) (string, error) {
+	if subtitle.Source == nil {
+		return "", nil
+	}
+	key, err := r.saveSource(tx, subtitle.Source)
+	if errors.Is(err, errSourceContent) {
+		log.Printf("Failed to store source of subtitle %s: %v", subtitle.SubtitleID, err)
+		subtitle.SourceHash = ""
+		return "", nil
+	}
+	if err != nil {
+		return key, err
+	}
+	subtitle.SourceHash = subtitle.Source.ContentHash
+	return key, nil
+}
+
+// removeUncommittedSource deletes source content written by a transaction that did not commit, unless a row for it
+// has been stored by another request since.
+func (r *subtitleRepository) removeUncommittedSource(key, contentHash string) {
+	if key == "" {
+		return
+	}
+	if _, err := r.GetSource(contentHash); !errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err := r.store.Delete(key); err != nil {
+		log.Printf("Failed to remove content of uncommitted source %s: %v", key, err)
+	}
+}
+
+func (r *subtitleRepository) GetSource(contentHash string) (*models.SubtitleSource, error) {
+	var source models.SubtitleSource
+	err := r.db.Where("content_hash = ?", contentHash).First(&source).Error
+	if err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// FindSourceByURL returns the latest source stored for any translation of url
+func (r *subtitleRepository) FindSourceByURL(url string) (*models.SubtitleSource, error) {
+	var subtitle models.Subtitle
+	err := r.db.Select("source_hash").
+		Where("url = ? AND source_hash <> ''", url).
+		Order("updated_at DESC").
+		First(&subtitle).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSource(subtitle.SourceHash)
+}
+
+// FindBySourceContent returns the translations into targetLang of any source with the given normalized content
+// hash, latest first
+func (r *subtitleRepository) FindBySourceContent(normalizedHash, targetLang string) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	err := r.db.Joins("JOIN subtitle_sources ON subtitle_sources.content_hash = subtitles.source_hash").
+		Where("subtitle_sources.normalized_hash = ? AND subtitles.target_lang = ?", normalizedHash, targetLang).
+		Order("subtitles.updated_at DESC").
+		Find(&subtitles).Error
+	return subtitles, err
+}
+
+// Touch records an access to a subtitle without changing its updated_at
+func (r *subtitleRepository) Touch(id uint, at time.Time) error {
+	return r.db.Model(&models.Subtitle{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"last_accessed_at": at,
+		"access_count":     gorm.Expr("access_count + 1"),
+	}).Error
+}
+
+// SetMissingExpiry gives subtitles without an expiry or TTL of their own one ttl after their last update, and
+// returns how many were changed. Expiries are computed here rather than in SQL, which has no portable date
+// arithmetic, a batch of rows at a time.
+func (r *subtitleRepository) SetMissingExpiry(ttl time.Duration) (int64, error) {
+	const batchSize = 100
+
+	var updated int64
+	var afterID uint
+	for {
+		var subtitles []models.Subtitle
+		err := r.db.Select("id", "updated_at").
+			Where("expires_at IS NULL AND ttl_seconds = 0 AND id > ?", afterID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&subtitles).Error
+		if err != nil {
+			return updated, err
+		}
+
+		for _, subtitle := range subtitles {
+			afterID = subtitle.ID
+			result := r.db.Model(&models.Subtitle{}).
+				Where("id = ? AND expires_at IS NULL", subtitle.ID).
+				UpdateColumn("expires_at", subtitle.UpdatedAt.Add(ttl))
+			if result.Error != nil {
+				return updated, result.Error
+			}
+			updated += result.RowsAffected
+		}
+		if len(subtitles) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// CacheStats counts the stored subtitles and sums the storage taken by their content, revisions and sources, which
+// is what evicting them can free. Revisions with identical content share one object, which is counted once.
+func (r *subtitleRepository) CacheStats(now time.Time) (*models.CacheStats, error) {
+	var stats models.CacheStats
+	if err := r.db.Model(&models.Subtitle{}).Count(&stats.Subtitles).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.Subtitle{}).Where("is_lock = ?", true).Count(&stats.Locked).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.Subtitle{}).Where("is_lock = ? AND expires_at < ?", false, now).Count(&stats.Expired).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.Subtitle{}).Select("COALESCE(SUM(file_size), 0)").Scan(&stats.SubtitleBytes).Error; err != nil {
+		return nil, err
+	}
+	live := r.db.Model(&models.Subtitle{}).Select("id")
+	revisions := r.db.Model(&models.SubtitleRevision{}).Distinct("file_path", "file_size").Where("subtitle_id IN (?)", live)
+	if err := r.db.Table("(?) AS objects", revisions).Select("COALESCE(SUM(file_size), 0)").Scan(&stats.RevisionBytes).Error; err != nil {
+		return nil, err
+	}
+	sources := r.db.Model(&models.Subtitle{}).Select("source_hash")
+	if err := r.db.Model(&models.SubtitleSource{}).Where("content_hash IN (?)", sources).Select("COALESCE(SUM(file_size), 0)").Scan(&stats.SourceBytes).Error; err != nil {
+		return nil, err
+	}
+	stats.TotalBytes = stats.SubtitleBytes + stats.RevisionBytes + stats.SourceBytes
+	return &stats, nil
+}
+
+// ListEvictable returns up to limit subtitles that may be evicted, least recently used first and, among those,
+// least used. Locked subtitles and subtitles with manually corrected cues or timing are never evicted.
+func (r *subtitleRepository) ListEvictable(limit int) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	err := r.db.Where("is_lock = ? AND (cue_locks IS NULL OR cue_locks = '') AND (timing IS NULL OR timing = '')", false).
+		Order("COALESCE(last_accessed_at, created_at) ASC, access_count ASC, id ASC").
+		Limit(limit).
+		Find(&subtitles).Error
+	return subtitles, err
+}
+
+// Evict permanently removes a subtitle like Delete and returns the bytes freed. Unlike Delete the row is not kept
+// soft-deleted, so the subtitle can be translated again under the same key.
+func (r *subtitleRepository) Evict(id uint) (int64, error) {
+	return r.remove(id, true)
+}
+
+// remove deletes a subtitle row with its revisions, and its source when no other subtitle uses it, then their
+// content, and returns the bytes freed. A permanent removal does not keep the row soft-deleted.
+func (r *subtitleRepository) remove(id uint, permanent bool) (int64, error) {
+	var subtitle models.Subtitle
+	var revisions []models.SubtitleRevision
+	var source *models.SubtitleSource
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&subtitle, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("subtitle_id = ?", id).Find(&revisions).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("subtitle_id = ?", id).Delete(&models.SubtitleRevision{}).Error; err != nil {
+			return err
+		}
+		rows := tx
+		if permanent {
+			rows = tx.Unscoped()
+		}
+		if err := rows.Delete(&models.Subtitle{}, id).Error; err != nil {
+			return err
+		}
+
+		var err error
+		source, err = unusedSource(tx, subtitle.SourceHash)
+		if err != nil || source == nil {
+			return err
+		}
+		return tx.Delete(source).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	freed := subtitle.FileSize
+	keys := []string{NormalizeFilePath(subtitle.FilePath)}
+	seen := make(map[string]bool)
+	for _, revision := range revisions {
+		if !seen[revision.FilePath] {
+			seen[revision.FilePath] = true
+			keys = append(keys, revision.FilePath)
+			freed += revision.FileSize
+		}
+	}
+	if source != nil {
+		keys = append(keys, source.FilePath)
+		freed += source.FileSize
+	}
+	for _, key := range keys {
+		if err := r.store.Delete(key); err != nil {
+			log.Printf("Failed to delete %s of subtitle %d, left for reconciliation: %v", key, id, err)
+		}
+	}
+	return freed, nil
+}
+
+// unusedSource returns the stored source with contentHash when no subtitle uses it, or nil.
+func unusedSource(tx *gorm.DB, contentHash string) (*models.SubtitleSource, error) {
+	if contentHash == "" {
+		return nil, nil
+	}
+	var users int64
+	if err := tx.Model(&models.Subtitle{}).Where("source_hash = ?", contentHash).Count(&users).Error; err != nil || users > 0 {
+		return nil, err
+	}
+	var source models.SubtitleSource
+	if err := tx.Where("content_hash = ?", contentHash).First(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &source, nil
+}
+
+// addRevision records content as the next revision of a subtitle, unless it equals the latest one. Revision
+// content is stored by hash, so identical versions share one object.
+func (r *subtitleRepository) addRevision(tx *gorm.DB, subtitle *models.Subtitle, content string, change models.ContentChange) error {
+	last, err := lastRevision(tx, subtitle.ID)
 	if err != nil {
 		return err
 	}
+	hash := ContentHash(content)
+	if last != nil && last.ContentHash == hash {
+		return nil
+	}
+
+	key := RevisionFilePath(subtitle.SubtitleID, hash)
+	if err := r.store.Put(key, []byte(content)); err != nil {
+		return fmt.Errorf("failed to save revision content: %w", err)
+	}
 
-	// Delete file
-	if err := os.Remove(subtitle.FilePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete content file: %w", err)
+	number := 1
+	if last != nil {
+		number = last.Number + 1
 	}
+	return tx.Create(&models.SubtitleRevision{
+		SubtitleID:  subtitle.ID,
+		Number:      number,
+		ContentHash: hash,
+		Source:      change.Source,
+		Author:      change.Author,
+		FilePath:    key,
+		FileSize:    int64(len(content)),
+	}).Error
+}
 
-	// Delete from database
-	return r.db.Delete(&models.Subtitle{}, id).Error
+func lastRevision(tx *gorm.DB, subtitleID uint) (*models.SubtitleRevision, error) {
+	var revisions []models.SubtitleRevision
+	if err := tx.Where("subtitle_id = ?", subtitleID).Order("number DESC").Limit(1).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	return &revisions[0], nil
 }
 
-// LoadContent loads subtitle content from file
-func LoadContent(filePath string) (string, error) {
-	content, err := ioutil.ReadFile(filePath)
+// LoadContent loads subtitle content from the store
+func (r *subtitleRepository) LoadContent(filePath string) (string, error) {
+	content, err := r.store.Get(NormalizeFilePath(filePath))
 	if err != nil {
 		return "", fmt.Errorf("failed to read content file: %w", err)
 	}
 	return string(content), nil
 }
 
-// GenerateFilePath generates file path for subtitle
+// FileURL returns where clients can download the stored content
+func (r *subtitleRepository) FileURL(filePath string) string {
+	return r.store.URL(NormalizeFilePath(filePath))
+}
+
+// GenerateFilePath generates the storage key for subtitle content
 func GenerateFilePath(subtitleID string) string {
-	filename := fmt.Sprintf("%s.vtt", subtitleID)
-	return filepath.ToSlash(filepath.Join(StorageDir, filename))
+	return ContentPrefix + "/" + subtitleID + ".vtt"
+}
+
+// RevisionFilePath generates the storage key for revision content
+func RevisionFilePath(subtitleID, contentHash string) string {
+	return RevisionPrefix + "/" + subtitleID + "/" + contentHash + ".vtt"
+}
+
+// SourceFilePath generates the storage key for source content
+func SourceFilePath(contentHash, format string) string {
+	return SourcePrefix + "/" + contentHash + "." + format
+}
+
+// ContentHash is the hex SHA-256 of content
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizedContentHash is the ContentHash of content with line endings unified, trailing blanks removed and runs
+// of blank lines collapsed, so sources that differ only in such formatting share it
+func NormalizedContentHash(content string) string {
+	content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\r", "\n")
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" && blank {
+			continue
+		}
+		blank = line == ""
+		lines = append(lines, line)
+	}
+	return ContentHash(strings.Join(lines, "\n"))
+}
+
+// NormalizeFilePath turns a stored file path into a storage key, dropping the storage/ root of older rows
+func NormalizeFilePath(filePath string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filePath), legacyStorageRoot)
 }
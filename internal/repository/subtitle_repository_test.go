@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"subtitle-translator/internal/models"
+	"subtitle-translator/internal/storage"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRepository returns a repository on a fresh SQLite database and a local store in a temporary directory.
+func newTestRepository(t *testing.T) (*subtitleRepository, *storage.LocalStore) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Subtitle{}, &models.SubtitleRevision{}, &models.SubtitleSource{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	store, err := storage.NewLocalStore(filepath.Join(dir, "content"))
+	if err != nil {
+		t.Fatalf("NewLocalStore returned error: %v", err)
+	}
+	return &subtitleRepository{db: db, store: store}, store
+}
+
+func createTestSubtitle(t *testing.T, repo *subtitleRepository, subtitleID, sourceHash, content string) *models.Subtitle {
+	t.Helper()
+	subtitle := &models.Subtitle{
+		SubtitleID: subtitleID, URL: "https://example.com/" + subtitleID + ".vtt", TargetLang: "id", SourceLang: "auto", Format: "vtt",
+		FilePath: GenerateFilePath(subtitleID), FileSize: int64(len(content)), SourceHash: sourceHash,
+	}
+	if err := repo.Create(subtitle, content); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	return subtitle
+}
+
+// failingStore fails Put for keys under failPut and every Delete when failDelete is set.
+type failingStore struct {
+	storage.ContentStore
+	failPut    string
+	failDelete bool
+}
+
+var errStoreDown = errors.New("store unavailable")
+
+func (s *failingStore) Put(key string, content []byte) error {
+	if s.failPut != "" && strings.HasPrefix(key, s.failPut) {
+		return errStoreDown
+	}
+	return s.ContentStore.Put(key, content)
+}
+
+func (s *failingStore) Delete(key string) error {
+	if s.failDelete {
+		return errStoreDown
+	}
+	return s.ContentStore.Delete(key)
+}
+
+func TestNormalizedContentHash_IgnoresFormatting(t *testing.T) {
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n"
+	same := "WEBVTT  \r\n\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nHello\r\n\r\n"
+	if NormalizedContentHash(content) != NormalizedContentHash(same) {
+		t.Fatalf("expected formatting differences to be ignored")
+	}
+	if NormalizedContentHash(content) == NormalizedContentHash("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello!\n") {
+		t.Fatalf("expected different text to hash differently")
+	}
+}
+
+func TestEvict_RemovesRevisionsAndUnusedSource(t *testing.T) {
+	repo, store := newTestRepository(t)
+	source := &models.SubtitleSource{Format: "vtt", Encoding: "utf-8"}
+	if err := repo.SaveSource(source, "WEBVTT\n\nsource\n"); err != nil {
+		t.Fatalf("SaveSource returned error: %v", err)
+	}
+	first := createTestSubtitle(t, repo, "aaaa", source.ContentHash, "WEBVTT\n\nsatu\n")
+	second := createTestSubtitle(t, repo, "bbbb", source.ContentHash, "WEBVTT\n\ndua\n")
+	if err := repo.UpdateContent(first.ID, "WEBVTT\n\nsatu lagi\n", models.ContentChange{Source: models.RevisionManual}); err != nil {
+		t.Fatalf("UpdateContent returned error: %v", err)
+	}
+
+	stats, err := repo.CacheStats(time.Now())
+	if err != nil {
+		t.Fatalf("CacheStats returned error: %v", err)
+	}
+	// Both revisions of the first subtitle, the one of the second, their current content and the shared source once
+	wantBytes := int64(len("WEBVTT\n\nsatu lagi\n")+len("WEBVTT\n\ndua\n")) +
+		int64(len("WEBVTT\n\nsatu\n")+len("WEBVTT\n\nsatu lagi\n")+len("WEBVTT\n\ndua\n")) +
+		int64(len("WEBVTT\n\nsource\n"))
+	if stats.Subtitles != 2 || stats.TotalBytes != wantBytes {
+		t.Fatalf("unexpected stats: %+v, want %d bytes", stats, wantBytes)
+	}
+
+	freed, err := repo.Evict(first.ID)
+	if err != nil {
+		t.Fatalf("Evict returned error: %v", err)
+	}
+	if want := int64(len("WEBVTT\n\nsatu lagi\n") + len("WEBVTT\n\nsatu\n") + len("WEBVTT\n\nsatu lagi\n")); freed != want {
+		t.Fatalf("expected %d bytes freed, got %d", want, freed)
+	}
+	if objects, _ := store.List(RevisionPrefix + "/aaaa/"); len(objects) != 0 {
+		t.Fatalf("expected the revisions to be removed, got %+v", objects)
+	}
+	if _, err := store.Stat(source.FilePath); err != nil {
+		t.Fatalf("expected the source still used by another subtitle to be kept: %v", err)
+	}
+	var rows int64
+	repo.db.Unscoped().Model(&models.Subtitle{}).Where("id = ?", first.ID).Count(&rows)
+	if rows != 0 {
+		t.Fatalf("expected the evicted row to be removed for good")
+	}
+	createTestSubtitle(t, repo, "aaaa", "", "WEBVTT\n\nbaru\n")
+
+	freed, err = repo.Evict(second.ID)
+	if err != nil {
+		t.Fatalf("Evict returned error: %v", err)
+	}
+	if want := int64(2*len("WEBVTT\n\ndua\n") + len("WEBVTT\n\nsource\n")); freed != want {
+		t.Fatalf("expected the unused source to be freed too, got %d want %d", freed, want)
+	}
+	if _, err := repo.GetSource(source.ContentHash); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the unused source row to be removed, got %v", err)
+	}
+}
+
+func TestDelete_RemovesRevisionsFromCacheStats(t *testing.T) {
+	repo, store := newTestRepository(t)
+	kept := createTestSubtitle(t, repo, "aaaa", "", "WEBVTT\n\nsatu\n")
+	deleted := createTestSubtitle(t, repo, "bbbb", "", "WEBVTT\n\ndua\n")
+
+	if err := repo.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if objects, _ := store.List(RevisionPrefix + "/bbbb/"); len(objects) != 0 {
+		t.Fatalf("expected the revisions of the deleted subtitle to be removed, got %+v", objects)
+	}
+
+	stats, err := repo.CacheStats(time.Now())
+	if err != nil {
+		t.Fatalf("CacheStats returned error: %v", err)
+	}
+	if stats.Subtitles != 1 || stats.TotalBytes != 2*kept.FileSize {
+		t.Fatalf("expected only the kept subtitle to be counted, got %+v", stats)
+	}
+}
+
+func TestCreate_RemovesContentWhenTransactionFails(t *testing.T) {
+	repo, store := newTestRepository(t)
+	repo.store = &failingStore{ContentStore: store, failPut: RevisionPrefix + "/"}
+
+	subtitle := &models.Subtitle{SubtitleID: "aaaa", URL: "https://example.com/a.vtt", TargetLang: "id", Format: "vtt", FilePath: GenerateFilePath("aaaa")}
+	if err := repo.Create(subtitle, "WEBVTT\n\nsatu\n"); !errors.Is(err, errStoreDown) {
+		t.Fatalf("expected the revision write to fail Create, got %v", err)
+	}
+
+	if _, err := repo.GetBySubtitleID("aaaa"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the row to be rolled back, got %v", err)
+	}
+	if _, err := store.Get(GenerateFilePath("aaaa")); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the written content to be removed, got %v", err)
+	}
+}
+
+func TestUpdateContent_SavesMetadataWithContent(t *testing.T) {
+	repo, store := newTestRepository(t)
+	subtitle := createTestSubtitle(t, repo, "aaaa", "", "WEBVTT\n\nsatu\n")
+
+	subtitle.CueLocks = `[{"start":1}]`
+	err := repo.UpdateContent(subtitle.ID, "WEBVTT\n\nsatu lagi\n", models.ContentChange{Source: models.RevisionManual, Subtitle: subtitle})
+	if err != nil {
+		t.Fatalf("UpdateContent returned error: %v", err)
+	}
+	stored, err := repo.GetByID(subtitle.ID)
+	if err != nil {
+		t.Fatalf("GetByID returned error: %v", err)
+	}
+	if stored.CueLocks != subtitle.CueLocks || stored.FileSize != int64(len("WEBVTT\n\nsatu lagi\n")) {
+		t.Fatalf("expected metadata saved with the content, got %+v", stored)
+	}
+
+	// A failing revision write rolls back the metadata and puts the previous content back
+	repo.store = &failingStore{ContentStore: store, failPut: RevisionPrefix + "/"}
+	subtitle.CueLocks = ""
+	err = repo.UpdateContent(subtitle.ID, "WEBVTT\n\ntiga\n", models.ContentChange{Source: models.RevisionManual, Subtitle: subtitle})
+	if !errors.Is(err, errStoreDown) {
+		t.Fatalf("expected the revision write to fail UpdateContent, got %v", err)
+	}
+	if stored, _ := repo.GetByID(subtitle.ID); stored.CueLocks == "" || stored.FileSize != int64(len("WEBVTT\n\nsatu lagi\n")) {
+		t.Fatalf("expected the metadata to be rolled back, got %+v", stored)
+	}
+	if content, _ := store.Get(GenerateFilePath("aaaa")); string(content) != "WEBVTT\n\nsatu lagi\n" {
+		t.Fatalf("expected the previous content to be restored, got %q", content)
+	}
+	if revisions, _ := repo.ListRevisions(subtitle.ID); len(revisions) != 2 {
+		t.Fatalf("expected no revision for the failed update, got %d", len(revisions))
+	}
+}
+
+func TestDelete_RemovesRowsWhenStoreDeleteFails(t *testing.T) {
+	repo, store := newTestRepository(t)
+	subtitle := createTestSubtitle(t, repo, "aaaa", "", "WEBVTT\n\nsatu\n")
+	repo.store = &failingStore{ContentStore: store, failDelete: true}
+
+	if err := repo.Delete(subtitle.ID); err != nil {
+		t.Fatalf("expected a failed content removal to leave the delete committed, got %v", err)
+	}
+	if _, err := repo.GetByID(subtitle.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the row to be deleted, got %v", err)
+	}
+	if revisions, _ := repo.ListRevisions(subtitle.ID); len(revisions) != 0 {
+		t.Fatalf("expected the revisions to be deleted, got %d", len(revisions))
+	}
+	// The content is left for reconciliation, which never sees a row without content
+	if _, err := store.Get(GenerateFilePath("aaaa")); err != nil {
+		t.Fatalf("expected the content to be left behind, got %v", err)
+	}
+}
+
+func TestCreate_SavesSourceWithRow(t *testing.T) {
+	repo, store := newTestRepository(t)
+	newSubtitle := func(subtitleID string) *models.Subtitle {
+		return &models.Subtitle{
+			SubtitleID: subtitleID, URL: "https://example.com/" + subtitleID + ".vtt", TargetLang: "id", Format: "vtt",
+			FilePath: GenerateFilePath(subtitleID),
+			Source:   &models.SubtitleSource{Format: "vtt", Encoding: "utf-8", Content: "WEBVTT\n\nsource\n"},
+		}
+	}
+	sourceKey := SourceFilePath(ContentHash("WEBVTT\n\nsource\n"), "vtt")
+
+	// A failing content write takes the source written in the same transaction with it
+	repo.store = &failingStore{ContentStore: store, failPut: ContentPrefix + "/"}
+	if err := repo.Create(newSubtitle("aaaa"), "WEBVTT\n\nsatu\n"); !errors.Is(err, errStoreDown) {
+		t.Fatalf("expected the content write to fail Create, got %v", err)
+	}
+	if _, err := repo.GetSource(ContentHash("WEBVTT\n\nsource\n")); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the source row to be rolled back, got %v", err)
+	}
+	if _, err := store.Get(sourceKey); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the source content to be removed, got %v", err)
+	}
+
+	// A source that cannot be stored leaves the subtitle without one
+	repo.store = &failingStore{ContentStore: store, failPut: SourcePrefix + "/"}
+	subtitle := newSubtitle("bbbb")
+	if err := repo.Create(subtitle, "WEBVTT\n\ndua\n"); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if subtitle.SourceHash != "" {
+		t.Fatalf("expected no source hash, got %q", subtitle.SourceHash)
+	}
+
+	repo.store = store
+	subtitle = newSubtitle("cccc")
+	if err := repo.Create(subtitle, "WEBVTT\n\ntiga\n"); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if subtitle.SourceHash != ContentHash("WEBVTT\n\nsource\n") {
+		t.Fatalf("expected the row to point at its source, got %q", subtitle.SourceHash)
+	}
+	if content, err := store.Get(sourceKey); err != nil || string(content) != "WEBVTT\n\nsource\n" {
+		t.Fatalf("expected the source content to be stored, got %q, %v", content, err)
+	}
+}
+
+func TestSetMissingExpiry_CountsFromLastUpdate(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	missing := createTestSubtitle(t, repo, "0000000000000000000000000000000a", "", "WEBVTT\n")
+	never := createTestSubtitle(t, repo, "0000000000000000000000000000000b", "", "WEBVTT\n")
+	expiring := createTestSubtitle(t, repo, "0000000000000000000000000000000c", "", "WEBVTT\n")
+
+	updatedAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	expiresAt := updatedAt.Add(time.Minute)
+	never.TTLSeconds = -1
+	expiring.ExpiresAt = &expiresAt
+	for _, subtitle := range []*models.Subtitle{missing, never, expiring} {
+		if err := repo.db.Model(subtitle).UpdateColumns(map[string]interface{}{
+			"updated_at": updatedAt, "ttl_seconds": subtitle.TTLSeconds, "expires_at": subtitle.ExpiresAt,
+		}).Error; err != nil {
+			t.Fatalf("failed to prepare subtitle: %v", err)
+		}
+	}
+
+	updated, err := repo.SetMissingExpiry(time.Hour)
+	if err != nil {
+		t.Fatalf("SetMissingExpiry returned error: %v", err)
+	}
+	if updated != 1 {
+		t.Fatalf("expected one subtitle to get an expiry, got %d", updated)
+	}
+
+	want := map[uint]*time.Time{missing.ID: timePtr(updatedAt.Add(time.Hour)), never.ID: nil, expiring.ID: &expiresAt}
+	for id, expected := range want {
+		stored, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID returned error: %v", err)
+		}
+		if (stored.ExpiresAt == nil) != (expected == nil) || (expected != nil && !stored.ExpiresAt.Equal(*expected)) {
+			t.Fatalf("subtitle %d: expected expiry %v, got %v", id, expected, stored.ExpiresAt)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -10,13 +10,17 @@ import (
 )
 
 func SetupRoutes(app *fiber.App) {
-	// Serve subtitle files so generated .vtt can be accessed by URL
-	app.Static("/storage/subtitles", repository.StorageDir)
-
 	// Initialize dependencies
-	subtitleRepo := repository.NewSubtitleRepository(config.DB)
-	subtitleService := service.NewSubtitleService(subtitleRepo)
+	subtitleRepo := repository.NewSubtitleRepository(config.DB, config.Store)
+	subtitleService := service.NewSubtitleService(subtitleRepo, config.URLNormalizer, service.CachePolicy{
+		TTL:   config.CacheTTL,
+		Quota: config.StorageQuota,
+	})
 	subtitleHandler := handler.NewSubtitleHandler(subtitleService)
+	storageHandler := handler.NewStorageHandler(config.Store)
+
+	// Serve subtitle files so generated .vtt can be accessed by URL
+	app.Get("/storage/subtitles/*", storageHandler.ServeSubtitleFile)
 
 	// API routes
 	api := app.Group("/api")
@@ -27,10 +31,33 @@ func SetupRoutes(app *fiber.App) {
 	subtitle.Post("/translate", subtitleHandler.TranslateSubtitle)
 	subtitle.Post("/translate/text", subtitleHandler.TranslateText)
 	subtitle.Post("/translate/batch", subtitleHandler.TranslateBatchContent)
+	subtitle.Post("/translate/hls", subtitleHandler.TranslateHLS)
+	subtitle.Post("/translate/mkv", subtitleHandler.TranslateMKV)
+	subtitle.Post("/mkv/tracks", subtitleHandler.ListMKVTracks)
+	subtitle.Post("/validate", subtitleHandler.ValidateSubtitle)
+	subtitle.Post("/validate/stored", subtitleHandler.ValidateStoredSubtitles)
 	subtitle.Get("/", subtitleHandler.GetAllSubtitles)
+	subtitle.Get("/variants", subtitleHandler.ListVariants)
 	subtitle.Get("/:id", subtitleHandler.GetSubtitleByID)
+	subtitle.Get("/:id/playlist.m3u8", subtitleHandler.GetSubtitlePlaylist)
 	subtitle.Put("/:id", subtitleHandler.UpdateSubtitle)
+	subtitle.Post("/:id/timing", subtitleHandler.AdjustTiming)
+	subtitle.Post("/:id/align", subtitleHandler.AlignTiming)
+	subtitle.Get("/:id/revisions", subtitleHandler.ListRevisions)
+	subtitle.Get("/:id/revisions/diff", subtitleHandler.DiffRevisions)
+	subtitle.Post("/:id/revisions/:number/rollback", subtitleHandler.RollbackSubtitle)
+	subtitle.Get("/:id/source", subtitleHandler.GetSubtitleSource)
+	subtitle.Get("/:id/cues", subtitleHandler.ListCues)
+	subtitle.Post("/:id/cues", subtitleHandler.InsertCue)
+	subtitle.Patch("/:id/cues/:cue", subtitleHandler.EditCue)
+	subtitle.Delete("/:id/cues/:cue", subtitleHandler.DeleteCue)
 	subtitle.Delete("/:id", subtitleHandler.DeleteSubtitle)
+	subtitle.Put("/:id/ttl", subtitleHandler.SetSubtitleTTL)
+
+	// Admin routes
+	admin := v1.Group("/admin")
+	admin.Get("/cache", subtitleHandler.CacheReport)
+	admin.Post("/cache/evict", subtitleHandler.EnforceStorageQuota)
 
 	// Health check endpoint
 	app.Get("/health", subtitleHandler.HealthCheck)
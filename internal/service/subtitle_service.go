@@ -2,48 +2,141 @@ package service
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
-	"path/filepath"
+	"strings"
 	"subtitle-translator/internal/models"
 	"subtitle-translator/internal/repository"
+	"subtitle-translator/internal/urlnorm"
 	"subtitle-translator/pkg/translator"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-var ErrSubtitleLocked = errors.New("subtitle is locked")
+var (
+	ErrSubtitleLocked      = errors.New("subtitle is locked")
+	ErrNoSubtitleRendition = errors.New("master playlist has no subtitle rendition")
+	ErrRevisionNotFound    = errors.New("revision not found")
+	ErrContentChanged      = errors.New("subtitle content changed since the given revision")
+	ErrSourceNotStored     = errors.New("source subtitle is not stored")
+	ErrInvalidTTL          = errors.New("ttl_seconds must be -1, 0 or positive")
+	ErrMalformedTimestamp  = errors.New("subtitle has cues with malformed timestamps")
+)
 
 type SubtitleService interface {
-	TranslateSubtitle(url, format, targetLang, sourceLang, referer string, isRefresh, isLock bool) (*models.SubtitleWithContent, error)
+	TranslateSubtitle(url, format, targetLang, sourceLang, referer string, isRefresh, isLock bool, opts translator.Options) (*models.SubtitleWithContent, error)
 	TranslateTexts(texts []string, targetLang, sourceLang string) ([]string, error)
 	GetAllSubtitles(page, limit int, targetLang string) ([]models.Subtitle, int64, int, error)
 	GetSubtitleByID(id uint) (*models.SubtitleWithContent, error)
-	UpdateSubtitle(id uint, content string) (*models.SubtitleWithContent, error)
+	UpdateSubtitle(id uint, content, author string) (*models.SubtitleWithContent, error)
 	DeleteSubtitle(id uint) error
+	TranslateHLSMaster(masterURL, subtitleURL, format, targetLang, sourceLang, referer, baseURL string) (string, *models.SubtitleWithContent, error)
+	GetSubtitlePlaylist(id uint, baseURL string) (string, error)
+	ListMKVSubtitleTracks(url, referer string, upload *translator.MKVSource) ([]translator.MKVTrack, error)
+	TranslateMKVSubtitle(url, referer string, upload *translator.MKVSource, track uint64, targetLang, sourceLang string, isRefresh, isLock bool) (*models.SubtitleWithContent, error)
+	AdjustTiming(id uint, adj translator.TimingAdjustment) (*models.SubtitleWithContent, error)
+	AlignTiming(id uint, referenceURL, format, referer string) (*models.SubtitleWithContent, *translator.Alignment, error)
+	ValidateContent(content, format string, opts translator.LintOptions) (*translator.LintResult, error)
+	ValidateStoredSubtitles(opts translator.LintOptions) ([]models.SubtitleValidation, error)
+	ListRevisions(id uint) ([]models.SubtitleRevision, error)
+	DiffRevisions(id uint, from, to int) ([]translator.CueChange, error)
+	RollbackSubtitle(id uint, number int, author string) (*models.SubtitleWithContent, error)
+	ListCues(id uint) (*models.SubtitleCues, error)
+	EditCue(id uint, ref string, edit translator.CueEdit, revision, author string) (*models.SubtitleCues, error)
+	InsertCue(id uint, edit translator.CueEdit, revision, author string) (*models.SubtitleCues, error)
+	DeleteCue(id uint, ref, revision, author string) (*models.SubtitleCues, error)
+	GetSubtitleSource(id uint) (*models.SubtitleSource, error)
+	ListVariants(url string) ([]models.Subtitle, error)
+	SetSubtitleTTL(id uint, ttlSeconds int64) (*models.Subtitle, error)
+	CacheReport(limit int) (*models.CacheReport, error)
+	EnforceStorageQuota() (*models.CacheEviction, error)
+}
+
+// CachePolicy bounds how long stored subtitles stay fresh and how much storage they may take. A zero TTL keeps
+// subtitles fresh forever and a zero Quota leaves storage unbounded.
+type CachePolicy struct {
+	TTL   time.Duration
+	Quota int64 // Bytes of subtitle, revision and source content
 }
 
+// evictionBatch is how many eviction candidates are loaded at a time.
+const evictionBatch = 50
+
+// uploadURLScheme prefixes the cache URL of uploaded files, named by their content hash.
+const uploadURLScheme = "upload://"
+
+// sourceFetcher fetches the source of a translation, conditionally when etag or lastModified is set.
+type sourceFetcher func(etag, lastModified string) (*translator.Source, error)
+
 type subtitleService struct {
 	repo repository.SubtitleRepository
+	// urls normalizes subtitle URLs into cache identities
+	urls *urlnorm.Normalizer
+	// translate turns a source subtitle into translated WebVTT
+	translate func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error)
+	// cache is the expiry and storage quota policy
+	cache CachePolicy
+	// spawn runs background work: refreshes of stale subtitles and quota enforcement
+	spawn func(func())
+	// refreshing holds the IDs of subtitles with a background refresh running
+	refreshing sync.Map
+	// evicting is held while the storage quota is enforced
+	evicting sync.Mutex
+
+	mu           sync.Mutex
+	lastEviction *models.CacheEviction
 }
 
-func NewSubtitleService(repo repository.SubtitleRepository) SubtitleService {
+func NewSubtitleService(repo repository.SubtitleRepository, urls *urlnorm.Normalizer, cache CachePolicy) SubtitleService {
 	return &subtitleService{
-		repo: repo,
+		repo:      repo,
+		urls:      urls,
+		translate: translator.TranslateSource,
+		cache:     cache,
+		spawn:     func(work func()) { go work() },
 	}
 }
 
-func (s *subtitleService) TranslateSubtitle(url, format, targetLang, sourceLang, referer string, isRefresh, isLock bool) (*models.SubtitleWithContent, error) {
+func (s *subtitleService) TranslateSubtitle(url, format, targetLang, sourceLang, referer string, isRefresh, isLock bool, opts translator.Options) (*models.SubtitleWithContent, error) {
+	return s.translateCached(url, format, targetLang, sourceLang, opts, isRefresh, isLock, func(etag, lastModified string) (*translator.Source, error) {
+		return translator.FetchSourceIfModified(url, format, referer, etag, lastModified)
+	})
+}
+
+// translateCached returns the stored translation for url and the options fingerprint of targetLang, sourceLang,
+// format and opts, translating the source when nothing is stored yet or a refresh is requested. A refresh keeps
+// manually corrected cues by passing the stored cue locks to the translation. The translation report is only
+// returned for content translated by this call.
+//
+// url is normalized into its cache identity; fetch still requests the URL as given. A subtitle cached before
+// fingerprints under the same url and options is adopted under its new key. The source is stored alongside
+// the translation. A new translation of a url whose source is already stored reuses it instead of calling fetch,
+// and one of a source already translated under another url copies that translation. fetch may make a conditional
+// request with the validators of the last fetch and return translator.ErrSourceNotModified.
+//
+// Stored content past its expiry is still returned, marked stale, while an unlocked subtitle is refreshed in the
+// background; uploads are not, since their content cannot change. New translations may evict least recently used
+// subtitles to stay within the storage quota.
+func (s *subtitleService) translateCached(url, format, targetLang, sourceLang string, opts translator.Options, isRefresh, isLock bool, fetch sourceFetcher) (*models.SubtitleWithContent, error) {
 	// Generate subtitle ID
-	subtitleID := s.generateSubtitleID(url, targetLang, format)
+	url = s.urls.Normalize(url)
+	fingerprint := subtitleFingerprint(targetLang, sourceLang, format, opts)
+	subtitleID := subtitleKey(url, fingerprint)
 	filePath := repository.GenerateFilePath(subtitleID)
 
 	// Check if already exists in database
 	existing, err := s.repo.GetBySubtitleID(subtitleID)
+	if err == gorm.ErrRecordNotFound {
+		existing, err = s.adoptLegacySubtitle(url, targetLang, sourceLang, format, opts, subtitleID, fingerprint)
+	}
 	if err == nil {
 		if existing.IsLock && isRefresh {
 			return nil, ErrSubtitleLocked
@@ -52,40 +145,11 @@ func (s *subtitleService) TranslateSubtitle(url, format, targetLang, sourceLang,
 		log.Printf("Subtitle already exists in DB with ID: %s, loading from file", subtitleID[:8])
 
 		if isRefresh {
-			content, err := translator.FetchAndTranslate(url, format, targetLang, sourceLang, referer)
-			if err != nil {
-				return nil, err
-			}
-			content = translator.PostProcessSubtitleContent(content, targetLang)
-
-			existing.IsLock = existing.IsLock || isLock
-			existing.FileSize = int64(len(content))
-			existing.UpdatedAt = time.Now()
-			if err := s.repo.UpdateContent(existing.ID, content); err != nil {
-				return nil, fmt.Errorf("failed to update refreshed content: %w", err)
-			}
-			if err := s.repo.Update(existing); err != nil {
-				return nil, fmt.Errorf("failed to update refreshed subtitle metadata: %w", err)
-			}
+			return s.refreshSubtitle(existing, targetLang, sourceLang, opts, isLock, fetch)
+		}
 
-			return &models.SubtitleWithContent{
-				ID:         existing.ID,
-				SubtitleID: existing.SubtitleID,
-				URL:        existing.URL,
-				TargetLang: existing.TargetLang,
-				SourceLang: existing.SourceLang,
-				Format:     existing.Format,
-				FilePath:   existing.FilePath,
-				Content:    content,
-				FileSize:   existing.FileSize,
-				IsLock:     existing.IsLock,
-				CreatedAt:  existing.CreatedAt,
-				UpdatedAt:  existing.UpdatedAt,
-			}, nil
-		}
-
-		// Keep stored path URL-safe across platforms
-		normalizedPath := filepath.ToSlash(existing.FilePath)
+		// Keep stored paths as backend-agnostic storage keys
+		normalizedPath := repository.NormalizeFilePath(existing.FilePath)
 		if existing.FilePath != normalizedPath {
 			existing.FilePath = normalizedPath
 			if updateErr := s.repo.Update(existing); updateErr != nil {
@@ -94,14 +158,14 @@ func (s *subtitleService) TranslateSubtitle(url, format, targetLang, sourceLang,
 		}
 
 		// Load content from file
-		content, err := repository.LoadContent(existing.FilePath)
+		content, err := s.repo.LoadContent(existing.FilePath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load content: %w", err)
 		}
 		cleanedContent := translator.PostProcessSubtitleContent(content, existing.TargetLang)
 		if cleanedContent != content {
 			content = cleanedContent
-			if updateErr := s.repo.UpdateContent(existing.ID, content); updateErr != nil {
+			if updateErr := s.repo.UpdateContent(existing.ID, content, models.ContentChange{Source: models.RevisionPostprocess}); updateErr != nil {
 				log.Printf("Failed to persist cleaned content for subtitle ID %s: %v", subtitleID[:8], updateErr)
 			}
 		}
@@ -112,21 +176,14 @@ func (s *subtitleService) TranslateSubtitle(url, format, targetLang, sourceLang,
 				log.Printf("Failed to lock subtitle ID %s: %v", subtitleID[:8], updateErr)
 			}
 		}
+		s.touch(existing)
 
-		return &models.SubtitleWithContent{
-			ID:         existing.ID,
-			SubtitleID: existing.SubtitleID,
-			URL:        existing.URL,
-			TargetLang: existing.TargetLang,
-			SourceLang: existing.SourceLang,
-			Format:     existing.Format,
-			FilePath:   existing.FilePath,
-			Content:    content,
-			FileSize:   existing.FileSize,
-			IsLock:     existing.IsLock,
-			CreatedAt:  existing.CreatedAt,
-			UpdatedAt:  existing.UpdatedAt,
-		}, nil
+		result := s.newSubtitleWithContent(existing, content)
+		if s.revalidatable(existing) && s.expired(existing) {
+			result.Stale = true
+			s.revalidate(existing.ID, targetLang, sourceLang, opts, fetch)
+		}
+		return result, nil
 	}
 
 	if err != gorm.ErrRecordNotFound {
@@ -135,47 +192,234 @@ func (s *subtitleService) TranslateSubtitle(url, format, targetLang, sourceLang,
 
 	log.Printf("Subtitle not found with ID: %s, fetching and translating", subtitleID[:8])
 
-	// Fetch and translate
-	content, err := translator.FetchAndTranslate(url, format, targetLang, sourceLang, referer)
+	// Fetch, reusing the source stored for another translation of the url
+	src, err := s.sourceByURL(url)
 	if err != nil {
-		return nil, err
+		if src, err = fetch("", ""); err != nil {
+			return nil, err
+		}
 	}
-	content = translator.PostProcessSubtitleContent(content, targetLang)
 
 	// Create subtitle record
+	now := time.Now()
 	subtitle := &models.Subtitle{
-		SubtitleID: subtitleID,
-		URL:        url,
-		TargetLang: targetLang,
-		SourceLang: sourceLang,
-		Format:     format,
-		FilePath:   filePath,
-		FileSize:   int64(len(content)),
-		IsLock:     isLock,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		SubtitleID:         subtitleID,
+		URL:                url,
+		TargetLang:         targetLang,
+		SourceLang:         sourceLang,
+		Format:             format,
+		Variant:            opts.Variant(),
+		Fingerprint:        fingerprint,
+		FilePath:           filePath,
+		IsLock:             isLock,
+		Source:             newSubtitleSource(src),
+		SourceETag:         src.ETag,
+		SourceLastModified: src.LastModified,
+		LastAccessedAt:     &now,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	subtitle.ExpiresAt = s.expiresAt(subtitle, now)
+
+	// The same source served from another URL is not translated again
+	if result, err := s.copySameSourceTranslation(subtitle, src); result != nil || err != nil {
+		return result, err
 	}
 
+	content, report, err := s.translate(src, targetLang, sourceLang, opts)
+	if err != nil {
+		return nil, err
+	}
+	content = translator.PostProcessSubtitleContent(content, targetLang)
+	subtitle.FileSize = int64(len(content))
+	subtitle.Lyrics = encodeLyricNotes(report)
+	subtitle.LintFindings = countLintFindings(content)
+
 	// Save to database and file
 	if err := s.repo.Create(subtitle, content); err != nil {
 		log.Printf("Failed to save subtitle: %v", err)
 		return nil, fmt.Errorf("failed to save subtitle: %w", err)
 	}
+	s.spawn(s.enforceQuota)
 
-	return &models.SubtitleWithContent{
-		ID:         subtitle.ID,
-		SubtitleID: subtitle.SubtitleID,
-		URL:        subtitle.URL,
-		TargetLang: subtitle.TargetLang,
-		SourceLang: subtitle.SourceLang,
-		Format:     subtitle.Format,
-		FilePath:   subtitle.FilePath,
-		Content:    content,
-		FileSize:   subtitle.FileSize,
-		IsLock:     subtitle.IsLock,
-		CreatedAt:  subtitle.CreatedAt,
-		UpdatedAt:  subtitle.UpdatedAt,
-	}, nil
+	result := s.newSubtitleWithContent(subtitle, content)
+	result.Report = report
+	return result, nil
+}
+
+// adoptLegacySubtitle looks up the subtitle cached for url and opts before options fingerprints and moves it to
+// subtitleID. Legacy keys ignored the source language, so the subtitle is only adopted when it was translated from
+// the same one; otherwise gorm.ErrRecordNotFound is returned and the variant is translated anew.
+func (s *subtitleService) adoptLegacySubtitle(url, targetLang, sourceLang, format string, opts translator.Options, subtitleID, fingerprint string) (*models.Subtitle, error) {
+	legacy, err := s.repo.GetBySubtitleID(legacySubtitleID(url, targetLang, format, opts.CacheKey()))
+	if err != nil {
+		return nil, err
+	}
+	if legacy.Fingerprint != "" || normalizeSourceLang(legacy.SourceLang) != normalizeSourceLang(sourceLang) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	legacy.SubtitleID = subtitleID
+	legacy.Fingerprint = fingerprint
+	if err := s.repo.Update(legacy); err != nil {
+		log.Printf("Failed to move subtitle ID %s to its fingerprinted key: %v", subtitleID[:8], err)
+	}
+	return legacy, nil
+}
+
+// copySameSourceTranslation saves subtitle with the content of a translation made with the same options from a
+// source with the same normalized content, such as the same episode under another URL. Only machine translations
+// are copied: translations with manually corrected cues or adjusted timing belong to their own row. It returns nil
+// when there is no such translation.
+func (s *subtitleService) copySameSourceTranslation(subtitle *models.Subtitle, src *translator.Source) (*models.SubtitleWithContent, error) {
+	candidates, err := s.repo.FindBySourceContent(repository.NormalizedContentHash(src.Content), subtitle.TargetLang)
+	if err != nil {
+		log.Printf("Failed to look up translations of the same source: %v", err)
+		return nil, nil
+	}
+
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.Fingerprint != subtitle.Fingerprint || candidate.CueLocks != "" || candidate.Timing != "" {
+			continue
+		}
+		content, err := s.repo.LoadContent(candidate.FilePath)
+		if err != nil {
+			log.Printf("Failed to load content of subtitle ID %s: %v", candidate.SubtitleID[:8], err)
+			continue
+		}
+
+		log.Printf("Reusing the translation of subtitle ID %s, which has the same source", candidate.SubtitleID[:8])
+		subtitle.FileSize = int64(len(content))
+		subtitle.Lyrics = candidate.Lyrics
+		subtitle.LintFindings = countLintFindings(content)
+		if err := s.repo.Create(subtitle, content); err != nil {
+			log.Printf("Failed to save subtitle: %v", err)
+			return nil, fmt.Errorf("failed to save subtitle: %w", err)
+		}
+		s.spawn(s.enforceQuota)
+		return s.newSubtitleWithContent(subtitle, content), nil
+	}
+	return nil, nil
+}
+
+// refreshSubtitle translates the source of a stored subtitle again. A source that did not change since the last
+// translation is not translated at all; otherwise only the cues that changed are, while the other cues keep their
+// stored translation and manually corrected cues their correction. Timing adjustments made since the first
+// translation are replayed on the new one. When fetch fails, such as for an unreachable origin or an expired signed
+// URL, the stored translation is kept.
+func (s *subtitleService) refreshSubtitle(existing *models.Subtitle, targetLang, sourceLang string, opts translator.Options, isLock bool, fetch sourceFetcher) (*models.SubtitleWithContent, error) {
+	var changes []translator.CueChange
+	src, err := fetch(existing.SourceETag, existing.SourceLastModified)
+	switch {
+	case errors.Is(err, translator.ErrSourceNotModified):
+		return s.keepUnchangedSource(existing, nil, isLock)
+	case err == nil && existing.SourceHash != "" && repository.ContentHash(src.Content) == existing.SourceHash:
+		return s.keepUnchangedSource(existing, src, isLock)
+	case err == nil:
+		opts.Reuse, changes = s.reusableTranslations(existing, src)
+	default:
+		log.Printf("Failed to fetch source of subtitle ID %s, keeping its translation: %v", existing.SubtitleID[:8], err)
+		return s.keepStoredTranslation(existing, nil, isLock)
+	}
+
+	opts.Locks = decodeCueLocks(existing.CueLocks)
+	content, report, err := s.translate(src, targetLang, sourceLang, opts)
+	if err != nil {
+		return nil, err
+	}
+	content = translator.PostProcessSubtitleContent(content, targetLang)
+	if content, err = replayTiming(content, existing.Timing); err != nil {
+		return nil, fmt.Errorf("failed to reapply timing adjustments: %w", err)
+	}
+
+	existing.IsLock = existing.IsLock || isLock
+	existing.Source = newSubtitleSource(src)
+	existing.SourceETag, existing.SourceLastModified = src.ETag, src.LastModified
+	existing.Lyrics = encodeLyricNotes(report)
+	if report != nil {
+		existing.CueLocks = encodeCueLocks(report.Locks)
+	}
+	existing.FileSize = int64(len(content))
+	existing.LintFindings = countLintFindings(content)
+	existing.UpdatedAt = time.Now()
+	existing.ExpiresAt = s.expiresAt(existing, existing.UpdatedAt)
+	if err := s.repo.UpdateContent(existing.ID, content, models.ContentChange{Source: models.RevisionRefresh, Subtitle: existing}); err != nil {
+		return nil, fmt.Errorf("failed to update refreshed content: %w", err)
+	}
+
+	result := s.newSubtitleWithContent(existing, content)
+	result.Report = report
+	result.SourceChanges = changes
+	return result, nil
+}
+
+// keepUnchangedSource answers a refresh whose source did not change with the stored translation. src, when the
+// source was fetched in full, carries the validators to use next time.
+func (s *subtitleService) keepUnchangedSource(existing *models.Subtitle, src *translator.Source, isLock bool) (*models.SubtitleWithContent, error) {
+	log.Printf("Source of subtitle ID %s is unchanged, keeping its translation", existing.SubtitleID[:8])
+
+	result, err := s.keepStoredTranslation(existing, src, isLock)
+	if err != nil {
+		return nil, err
+	}
+	result.SourceUnchanged = true
+	return result, nil
+}
+
+// keepStoredTranslation answers a refresh with the stored translation and renews its expiry, so a stale subtitle
+// is not refreshed again on every access. The stored validators are only replaced by those of src.
+func (s *subtitleService) keepStoredTranslation(existing *models.Subtitle, src *translator.Source, isLock bool) (*models.SubtitleWithContent, error) {
+	content, err := s.repo.LoadContent(existing.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content: %w", err)
+	}
+
+	existing.IsLock = existing.IsLock || isLock
+	if src != nil {
+		existing.SourceETag, existing.SourceLastModified = src.ETag, src.LastModified
+	}
+	existing.ExpiresAt = s.expiresAt(existing, time.Now())
+	if err := s.repo.Update(existing); err != nil {
+		log.Printf("Failed to update subtitle ID %s: %v", existing.SubtitleID[:8], err)
+	}
+
+	return s.newSubtitleWithContent(existing, content), nil
+}
+
+// reusableTranslations compares the stored source of a subtitle with a newly fetched one and returns the stored
+// translations that still apply, with the source cues that changed. Without a stored source everything is
+// translated again. The stored translation is compared on the source timeline, before its timing adjustments.
+func (s *subtitleService) reusableTranslations(existing *models.Subtitle, src *translator.Source) ([]translator.CueLock, []translator.CueChange) {
+	stored, err := s.storedSource(existing.SourceHash)
+	if err != nil {
+		return nil, nil
+	}
+	before, err := stored.Document()
+	if err != nil {
+		log.Printf("Failed to parse stored source of subtitle ID %s: %v", existing.SubtitleID[:8], err)
+		return nil, nil
+	}
+	after, err := src.Document()
+	if err != nil {
+		return nil, nil
+	}
+	content, err := s.repo.LoadContent(existing.FilePath)
+	if err != nil {
+		log.Printf("Failed to load content of subtitle ID %s: %v", existing.SubtitleID[:8], err)
+		return nil, nil
+	}
+	translated, err := translator.ParseVTT(content)
+	if err != nil {
+		log.Printf("Failed to parse content of subtitle ID %s: %v", existing.SubtitleID[:8], err)
+		return nil, nil
+	}
+	if err := translated.RevertTiming(decodeTimingAdjustments(existing.Timing)); err != nil {
+		log.Printf("Failed to revert timing of subtitle ID %s: %v", existing.SubtitleID[:8], err)
+		return nil, nil
+	}
+
+	return translator.ReuseTranslations(before, after, translated), translator.DiffCues(before, after)
 }
 
 func (s *subtitleService) TranslateTexts(texts []string, targetLang, sourceLang string) ([]string, error) {
@@ -201,7 +445,7 @@ func (s *subtitleService) GetAllSubtitles(page, limit int, targetLang string) ([
 	}
 
 	for i := range subtitles {
-		subtitles[i].FilePath = filepath.ToSlash(subtitles[i].FilePath)
+		subtitles[i].FilePath = repository.NormalizeFilePath(subtitles[i].FilePath)
 	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(limit)))
@@ -214,40 +458,73 @@ func (s *subtitleService) GetSubtitleByID(id uint) (*models.SubtitleWithContent,
 		return nil, err
 	}
 
-	subtitle.FilePath = filepath.ToSlash(subtitle.FilePath)
+	subtitle.FilePath = repository.NormalizeFilePath(subtitle.FilePath)
 
 	// Load content from file
-	content, err := repository.LoadContent(subtitle.FilePath)
+	content, err := s.repo.LoadContent(subtitle.FilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load content: %w", err)
 	}
 	cleanedContent := translator.PostProcessSubtitleContent(content, subtitle.TargetLang)
 	if cleanedContent != content {
 		content = cleanedContent
-		if updateErr := s.repo.UpdateContent(subtitle.ID, content); updateErr != nil {
+		if updateErr := s.repo.UpdateContent(subtitle.ID, content, models.ContentChange{Source: models.RevisionPostprocess}); updateErr != nil {
 			log.Printf("Failed to persist cleaned content for subtitle ID %s: %v", subtitle.SubtitleID[:8], updateErr)
 		}
 	}
 
-	return &models.SubtitleWithContent{
-		ID:         subtitle.ID,
-		SubtitleID: subtitle.SubtitleID,
-		URL:        subtitle.URL,
-		TargetLang: subtitle.TargetLang,
-		SourceLang: subtitle.SourceLang,
-		Format:     subtitle.Format,
-		FilePath:   subtitle.FilePath,
-		Content:    content,
-		FileSize:   subtitle.FileSize,
-		IsLock:     subtitle.IsLock,
-		CreatedAt:  subtitle.CreatedAt,
-		UpdatedAt:  subtitle.UpdatedAt,
-	}, nil
+	s.touch(subtitle)
+
+	return s.newSubtitleWithContent(subtitle, content), nil
+}
+
+// UpdateSubtitle saves manually edited content, recording author with the revision. Edited cues are locked so a
+// refresh keeps them. Content with cue timings that do not parse is refused, since timing and cue edits would later
+// drop those cues.
+func (s *subtitleService) UpdateSubtitle(id uint, content, author string) (*models.SubtitleWithContent, error) {
+	result, err := translator.Lint(content, "vtt", translator.LintOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, finding := range result.Findings {
+		if finding.Rule == translator.RuleMalformedTimestamp {
+			return nil, fmt.Errorf("%w: cue %d: %s", ErrMalformedTimestamp, finding.Index, finding.Message)
+		}
+	}
+
+	return s.saveManualEdit(id, content, models.ContentChange{Source: models.RevisionManual, Author: author})
+}
+
+func (s *subtitleService) saveManualEdit(id uint, content string, change models.ContentChange) (*models.SubtitleWithContent, error) {
+	subtitle, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	locks := decodeCueLocks(subtitle.CueLocks)
+	if previous, err := s.repo.LoadContent(subtitle.FilePath); err != nil {
+		log.Printf("Failed to load previous content of subtitle ID %s, cue locks unchanged: %v", subtitle.SubtitleID[:8], err)
+	} else {
+		locks = lockEditedCues(previous, content, locks, decodeTimingAdjustments(subtitle.Timing))
+	}
+
+	subtitle.CueLocks = encodeCueLocks(locks)
+	subtitle.FileSize = int64(len(content))
+	subtitle.LintFindings = countLintFindings(content)
+	subtitle.UpdatedAt = time.Now()
+	change.Subtitle = subtitle
+	if err := s.repo.UpdateContent(id, content, change); err != nil {
+		if errors.Is(err, repository.ErrContentChanged) {
+			return nil, ErrContentChanged
+		}
+		return nil, err
+	}
+
+	return s.GetSubtitleByID(id)
 }
 
-func (s *subtitleService) UpdateSubtitle(id uint, content string) (*models.SubtitleWithContent, error) {
+func (s *subtitleService) updateContent(id uint, content string, change models.ContentChange) (*models.SubtitleWithContent, error) {
 	// Update content file and database
-	if err := s.repo.UpdateContent(id, content); err != nil {
+	if err := s.repo.UpdateContent(id, content, change); err != nil {
 		return nil, err
 	}
 
@@ -259,8 +536,852 @@ func (s *subtitleService) DeleteSubtitle(id uint) error {
 	return s.repo.Delete(id)
 }
 
-func (s *subtitleService) generateSubtitleID(url, targetLang, format string) string {
+// TranslateHLSMaster translates a subtitle rendition of a master playlist (or the supplied subtitle URL)
+// and returns the master playlist with the translated track added.
+func (s *subtitleService) TranslateHLSMaster(masterURL, subtitleURL, format, targetLang, sourceLang, referer, baseURL string) (string, *models.SubtitleWithContent, error) {
+	master, err := translator.FetchHLSMasterPlaylist(masterURL, referer)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var track translator.HLSRendition
+	if subtitleURL == "" {
+		rendition, ok := master.SubtitleRendition(sourceLang)
+		if !ok {
+			return "", nil, ErrNoSubtitleRendition
+		}
+		subtitleURL = rendition.URI
+		format = "vtt"
+		track.GroupID = rendition.GroupID
+	}
+
+	subtitle, err := s.TranslateSubtitle(subtitleURL, format, targetLang, sourceLang, referer, false, false, translator.Options{})
+	if err != nil {
+		return "", nil, err
+	}
+
+	track.Type = "SUBTITLES"
+	track.Language = targetLang
+	track.Name = fmt.Sprintf("%s (Translated)", strings.ToUpper(targetLang))
+	track.Autoselect = true
+	track.URI = fmt.Sprintf("%s/api/v1/subtitles/%d/playlist.m3u8", strings.TrimRight(baseURL, "/"), subtitle.ID)
+
+	return master.InjectSubtitleRendition(track), subtitle, nil
+}
+
+// GetSubtitlePlaylist returns an HLS media playlist serving the stored VTT file as a single segment.
+func (s *subtitleService) GetSubtitlePlaylist(id uint, baseURL string) (string, error) {
+	subtitle, err := s.GetSubtitleByID(id)
+	if err != nil {
+		return "", err
+	}
+
+	vttURL := subtitle.FileURL
+	if !strings.HasPrefix(vttURL, "http://") && !strings.HasPrefix(vttURL, "https://") {
+		vttURL = strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(vttURL, "/")
+	}
+	return translator.BuildHLSSubtitlePlaylist(vttURL, subtitle.Content), nil
+}
+
+// ListMKVSubtitleTracks lists the text subtitle tracks of an uploaded or remote Matroska file.
+func (s *subtitleService) ListMKVSubtitleTracks(url, referer string, upload *translator.MKVSource) ([]translator.MKVTrack, error) {
+	src, err := openMKVSource(url, referer, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	return translator.ListMKVSubtitleTracks(src)
+}
+
+// TranslateMKVSubtitle translates one subtitle track of an uploaded or remote Matroska file.
+// Uploads are cached by content hash since they have no URL of their own.
+func (s *subtitleService) TranslateMKVSubtitle(url, referer string, upload *translator.MKVSource, track uint64, targetLang, sourceLang string, isRefresh, isLock bool) (*models.SubtitleWithContent, error) {
+	sourceURL := url
+	if upload != nil {
+		hash := sha1.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(upload, 0, upload.Size)); err != nil {
+			return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		sourceURL = uploadURLScheme + hex.EncodeToString(hash.Sum(nil))
+	}
+
+	cacheURL := fmt.Sprintf("%s#track=%d", sourceURL, track)
+	return s.translateCached(cacheURL, "mkv", targetLang, sourceLang, translator.Options{}, isRefresh, isLock, func(etag, lastModified string) (*translator.Source, error) {
+		src, err := openMKVSource(url, referer, upload)
+		if err != nil {
+			return nil, err
+		}
+		content, format, err := translator.ExtractMKVSubtitle(src, track)
+		if err != nil {
+			return nil, err
+		}
+		return translator.NewSource([]byte(content), format), nil
+	})
+}
+
+// ListVariants returns every stored translation of url, one per options fingerprint.
+func (s *subtitleService) ListVariants(url string) ([]models.Subtitle, error) {
+	return s.repo.ListByURL(s.urls.Normalize(url))
+}
+
+// SetSubtitleTTL gives a subtitle its own TTL: positive seconds, 0 for the configured TTL or -1 to never expire.
+// The new expiry counts from now.
+func (s *subtitleService) SetSubtitleTTL(id uint, ttlSeconds int64) (*models.Subtitle, error) {
+	if ttlSeconds < -1 {
+		return nil, ErrInvalidTTL
+	}
+	subtitle, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	subtitle.TTLSeconds = ttlSeconds
+	subtitle.ExpiresAt = s.expiresAt(subtitle, time.Now())
+	if err := s.repo.Update(subtitle); err != nil {
+		return nil, fmt.Errorf("failed to update subtitle TTL: %w", err)
+	}
+	return subtitle, nil
+}
+
+// CacheReport describes the cache policy and storage use, with up to limit subtitles next in line for eviction.
+func (s *subtitleService) CacheReport(limit int) (*models.CacheReport, error) {
+	stats, err := s.repo.CacheStats(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := s.repo.ListEvictable(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshing := 0
+	s.refreshing.Range(func(_, _ interface{}) bool {
+		refreshing++
+		return true
+	})
+
+	s.mu.Lock()
+	lastEviction := s.lastEviction
+	s.mu.Unlock()
+
+	return &models.CacheReport{
+		TTLSeconds:   int64(s.cache.TTL / time.Second),
+		QuotaBytes:   s.cache.Quota,
+		OverQuota:    s.cache.Quota > 0 && stats.TotalBytes > s.cache.Quota,
+		Stats:        *stats,
+		Refreshing:   refreshing,
+		Candidates:   candidates,
+		LastEviction: lastEviction,
+	}, nil
+}
+
+// EnforceStorageQuota evicts subtitles until storage fits the quota and returns what was evicted. It returns nil
+// without a quota or while another enforcement runs.
+func (s *subtitleService) EnforceStorageQuota() (*models.CacheEviction, error) {
+	if s.cache.Quota <= 0 || !s.evicting.TryLock() {
+		return nil, nil
+	}
+	defer s.evicting.Unlock()
+
+	eviction, err := s.evict()
+	if err != nil {
+		eviction.Error = err.Error()
+	}
+	s.mu.Lock()
+	s.lastEviction = eviction
+	s.mu.Unlock()
+	return eviction, err
+}
+
+// enforceQuota runs EnforceStorageQuota in the background, logging the outcome.
+func (s *subtitleService) enforceQuota() {
+	eviction, err := s.EnforceStorageQuota()
+	if err != nil {
+		log.Printf("Storage quota enforcement failed: %v", err)
+		return
+	}
+	if eviction != nil && eviction.Evicted > 0 {
+		log.Printf("Storage quota: evicted %d subtitles, freed %d bytes, %d bytes used", eviction.Evicted, eviction.FreedBytes, eviction.UsedBytes)
+	}
+}
+
+// evict removes evictable subtitles, least recently used first, while storage exceeds the quota. Storage can stay
+// over the quota when only locked or manually corrected subtitles are left.
+func (s *subtitleService) evict() (*models.CacheEviction, error) {
+	eviction := &models.CacheEviction{At: time.Now()}
+	stats, err := s.repo.CacheStats(eviction.At)
+	if err != nil {
+		return eviction, err
+	}
+	eviction.UsedBytes = stats.TotalBytes
+
+	for eviction.UsedBytes > s.cache.Quota {
+		candidates, err := s.repo.ListEvictable(evictionBatch)
+		if err != nil {
+			return eviction, err
+		}
+		if len(candidates) == 0 {
+			return eviction, nil
+		}
+		for _, candidate := range candidates {
+			if eviction.UsedBytes <= s.cache.Quota {
+				break
+			}
+			freed, err := s.repo.Evict(candidate.ID)
+			if err != nil {
+				return eviction, fmt.Errorf("failed to evict subtitle %d: %w", candidate.ID, err)
+			}
+			eviction.Evicted++
+			eviction.FreedBytes += freed
+			eviction.UsedBytes -= freed
+		}
+	}
+	return eviction, nil
+}
+
+// revalidate refreshes a stale subtitle in the background. A refresh already running for it is not started again.
+func (s *subtitleService) revalidate(id uint, targetLang, sourceLang string, opts translator.Options, fetch sourceFetcher) {
+	if _, running := s.refreshing.LoadOrStore(id, struct{}{}); running {
+		return
+	}
+
+	s.spawn(func() {
+		defer s.refreshing.Delete(id)
+
+		subtitle, err := s.repo.GetByID(id)
+		if err != nil {
+			log.Printf("Failed to load stale subtitle %d for refresh: %v", id, err)
+			return
+		}
+		if !s.revalidatable(subtitle) {
+			return
+		}
+		if _, err := s.refreshSubtitle(subtitle, targetLang, sourceLang, opts, false, fetch); err != nil {
+			log.Printf("Background refresh of subtitle ID %s failed: %v", subtitle.SubtitleID[:8], err)
+			return
+		}
+		s.enforceQuota()
+	})
+}
+
+// expiresAt is when subtitle becomes stale when its content is fresh at from, or nil when it never does.
+func (s *subtitleService) expiresAt(subtitle *models.Subtitle, from time.Time) *time.Time {
+	ttl := s.cache.TTL
+	switch {
+	case subtitle.TTLSeconds < 0:
+		return nil
+	case subtitle.TTLSeconds > 0:
+		ttl = time.Duration(subtitle.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	expiresAt := from.Add(ttl)
+	return &expiresAt
+}
+
+// revalidatable reports whether a stale subtitle is refreshed in the background. Locked subtitles are kept as they
+// are, and uploads are named by their content hash, so their source cannot change.
+func (s *subtitleService) revalidatable(subtitle *models.Subtitle) bool {
+	return !subtitle.IsLock && !strings.HasPrefix(subtitle.URL, uploadURLScheme)
+}
+
+func (s *subtitleService) expired(subtitle *models.Subtitle) bool {
+	return subtitle.ExpiresAt != nil && time.Now().After(*subtitle.ExpiresAt)
+}
+
+// touch records an access for least-recently-used eviction.
+func (s *subtitleService) touch(subtitle *models.Subtitle) {
+	now := time.Now()
+	if err := s.repo.Touch(subtitle.ID, now); err != nil {
+		log.Printf("Failed to record access to subtitle %d: %v", subtitle.ID, err)
+		return
+	}
+	subtitle.LastAccessedAt = &now
+	subtitle.AccessCount++
+}
+
+// ApplyCacheTTL gives subtitles stored without an expiry, before a TTL was configured, one ttl after their last
+// update. It returns how many subtitles were changed.
+func ApplyCacheTTL(repo repository.SubtitleRepository, ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return 0, nil
+	}
+	return repo.SetMissingExpiry(ttl)
+}
+
+// MigrateSubtitleKeys moves subtitles cached before options fingerprints to fingerprinted subtitle IDs. Only the
+// subtitles translated with default options can be recognized from their legacy ID; the others keep it until
+// they are next requested with their options. Rows stored before URL normalization get their URL normalized by
+// urls along with the new ID, so requests reach them. It returns how many subtitles were moved and how many were
+// left.
+func MigrateSubtitleKeys(repo repository.SubtitleRepository, urls *urlnorm.Normalizer) (migrated, skipped int, err error) {
+	const batchSize = 100
+
+	var afterID uint
+	for {
+		subtitles, err := repo.ListWithoutFingerprint(afterID, batchSize)
+		if err != nil {
+			return migrated, skipped, err
+		}
+		for i := range subtitles {
+			subtitle := &subtitles[i]
+			afterID = subtitle.ID
+			if subtitle.SubtitleID != legacySubtitleID(subtitle.URL, subtitle.TargetLang, subtitle.Format, "") {
+				skipped++
+				continue
+			}
+
+			subtitle.URL = urls.Normalize(subtitle.URL)
+			subtitle.Fingerprint = subtitleFingerprint(subtitle.TargetLang, subtitle.SourceLang, subtitle.Format, translator.Options{})
+			subtitle.SubtitleID = subtitleKey(subtitle.URL, subtitle.Fingerprint)
+			if err := repo.Update(subtitle); err != nil {
+				log.Printf("Failed to move subtitle %d to its fingerprinted key: %v", subtitle.ID, err)
+				skipped++
+				continue
+			}
+			migrated++
+		}
+		if len(subtitles) < batchSize {
+			return migrated, skipped, nil
+		}
+	}
+}
+
+// GetSubtitleSource returns the source subtitle a stored translation was made from, with its content.
+func (s *subtitleService) GetSubtitleSource(id uint) (*models.SubtitleSource, error) {
+	subtitle, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if subtitle.SourceHash == "" {
+		return nil, ErrSourceNotStored
+	}
+	source, err := s.repo.GetSource(subtitle.SourceHash)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrSourceNotStored
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	source.Content, err = s.repo.LoadContent(source.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source content: %w", err)
+	}
+	return source, nil
+}
+
+// newSubtitleSource describes a fetched source to be saved with the subtitle row that is translated from it.
+func newSubtitleSource(src *translator.Source) *models.SubtitleSource {
+	return &models.SubtitleSource{Format: src.Format, Encoding: src.Encoding, Content: src.Content}
+}
+
+// storedSource loads the stored source with the given content hash.
+func (s *subtitleService) storedSource(contentHash string) (*translator.Source, error) {
+	if contentHash == "" {
+		return nil, ErrSourceNotStored
+	}
+	source, err := s.repo.GetSource(contentHash)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadSource(source)
+}
+
+// sourceByURL loads the latest source stored for a translation of url.
+func (s *subtitleService) sourceByURL(url string) (*translator.Source, error) {
+	source, err := s.repo.FindSourceByURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadSource(source)
+}
+
+func (s *subtitleService) loadSource(source *models.SubtitleSource) (*translator.Source, error) {
+	content, err := s.repo.LoadContent(source.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source content: %w", err)
+	}
+	return &translator.Source{Content: content, Format: source.Format, Encoding: source.Encoding}, nil
+}
+
+// AdjustTiming shifts, scales or frame-rate converts every cue of a stored subtitle and saves the result. The
+// adjustment is kept with the subtitle and replayed when it is translated again.
+func (s *subtitleService) AdjustTiming(id uint, adj translator.TimingAdjustment) (*models.SubtitleWithContent, error) {
+	subtitle, err := s.GetSubtitleByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseStoredVTT(subtitle.Content)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.AdjustTiming(adj); err != nil {
+		return nil, err
+	}
+
+	return s.saveTiming(id, translator.FormatVTT(doc), adj, models.RevisionTiming)
+}
+
+// saveTiming saves retimed content together with the adjustment that retimed it.
+func (s *subtitleService) saveTiming(id uint, content string, adj translator.TimingAdjustment, source string) (*models.SubtitleWithContent, error) {
+	subtitle, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	subtitle.Timing = encodeTimingAdjustments(append(decodeTimingAdjustments(subtitle.Timing), adj))
+	subtitle.UpdatedAt = time.Now()
+	return s.updateContent(id, content, models.ContentChange{Source: source, Subtitle: subtitle})
+}
+
+// AlignTiming retimes a stored subtitle against an in-sync reference subtitle of the same video and saves the result.
+// Like AdjustTiming, the retiming is kept with the subtitle and replayed when it is translated again.
+func (s *subtitleService) AlignTiming(id uint, referenceURL, format, referer string) (*models.SubtitleWithContent, *translator.Alignment, error) {
+	subtitle, err := s.GetSubtitleByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := parseStoredVTT(subtitle.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	reference, err := translator.FetchDocument(referenceURL, format, referer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alignment, err := doc.AlignTo(reference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated, err := s.saveTiming(id, translator.FormatVTT(doc), alignment.Adjustment, models.RevisionAlign)
+	if err != nil {
+		return nil, nil, err
+	}
+	return updated, alignment, nil
+}
+
+// ValidateContent lints subtitle content without storing anything.
+func (s *subtitleService) ValidateContent(content, format string, opts translator.LintOptions) (*translator.LintResult, error) {
+	return translator.Lint(content, format, opts)
+}
+
+// ValidateStoredSubtitles lints every stored subtitle and, with opts.Fix, saves the fixed content.
+// Only subtitles with findings are returned.
+func (s *subtitleService) ValidateStoredSubtitles(opts translator.LintOptions) ([]models.SubtitleValidation, error) {
+	const pageSize = 100
+
+	results := []models.SubtitleValidation{}
+	for page := 1; ; page++ {
+		subtitles, _, err := s.repo.GetAll(page, pageSize, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subtitle := range subtitles {
+			validation := models.SubtitleValidation{ID: subtitle.ID, SubtitleID: subtitle.SubtitleID}
+
+			content, err := s.repo.LoadContent(subtitle.FilePath)
+			if err != nil {
+				validation.Error = err.Error()
+				results = append(results, validation)
+				continue
+			}
+
+			// Stored content is always WebVTT, whatever the source format was.
+			result, err := translator.Lint(content, "vtt", opts)
+			if err != nil {
+				validation.Error = err.Error()
+				results = append(results, validation)
+				continue
+			}
+			if len(result.Findings) == 0 {
+				continue
+			}
+
+			validation.Findings = result.Findings
+			if result.Content != "" {
+				subtitle.LintFindings = countLintFindings(result.Content)
+				change := models.ContentChange{Source: models.RevisionLintFix, Subtitle: &subtitle}
+				if err := s.repo.UpdateContent(subtitle.ID, result.Content, change); err != nil {
+					validation.Error = fmt.Sprintf("failed to save fixed content: %v", err)
+				} else {
+					validation.Fixed = result.Fixed
+				}
+			}
+			results = append(results, validation)
+		}
+
+		if len(subtitles) < pageSize {
+			return results, nil
+		}
+	}
+}
+
+// ListRevisions returns the stored versions of a subtitle, oldest first.
+func (s *subtitleService) ListRevisions(id uint) ([]models.SubtitleRevision, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRevisions(id)
+}
+
+// DiffRevisions compares two revisions of a subtitle cue by cue.
+func (s *subtitleService) DiffRevisions(id uint, from, to int) ([]translator.CueChange, error) {
+	fromDoc, err := s.loadRevision(id, from)
+	if err != nil {
+		return nil, err
+	}
+	toDoc, err := s.loadRevision(id, to)
+	if err != nil {
+		return nil, err
+	}
+	return translator.DiffCues(fromDoc, toDoc), nil
+}
+
+// RollbackSubtitle restores the content of a revision. The restored content is recorded as a new revision, so
+// the rollback itself can be undone.
+func (s *subtitleService) RollbackSubtitle(id uint, number int, author string) (*models.SubtitleWithContent, error) {
+	revision, err := s.getRevision(id, number)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.repo.LoadContent(revision.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revision content: %w", err)
+	}
+	return s.updateContent(id, content, models.ContentChange{Source: models.RevisionRollback, Author: author})
+}
+
+// ListCues returns the cues of a stored subtitle with the revision, the content hash, edits must be made against.
+func (s *subtitleService) ListCues(id uint) (*models.SubtitleCues, error) {
+	subtitle, err := s.GetSubtitleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return newSubtitleCues(subtitle)
+}
+
+// EditCue changes the text, timing, id or settings of one cue.
+func (s *subtitleService) EditCue(id uint, ref string, edit translator.CueEdit, revision, author string) (*models.SubtitleCues, error) {
+	return s.editCues(id, revision, author, func(doc *translator.Document) error {
+		i, err := doc.FindCue(ref)
+		if err != nil {
+			return err
+		}
+		return doc.EditCue(i, edit)
+	})
+}
+
+// InsertCue adds a cue at its place by start time.
+func (s *subtitleService) InsertCue(id uint, edit translator.CueEdit, revision, author string) (*models.SubtitleCues, error) {
+	return s.editCues(id, revision, author, func(doc *translator.Document) error {
+		_, err := doc.InsertCue(edit)
+		return err
+	})
+}
+
+// DeleteCue removes one cue.
+func (s *subtitleService) DeleteCue(id uint, ref, revision, author string) (*models.SubtitleCues, error) {
+	return s.editCues(id, revision, author, func(doc *translator.Document) error {
+		i, err := doc.FindCue(ref)
+		if err != nil {
+			return err
+		}
+		doc.DeleteCue(i)
+		return nil
+	})
+}
+
+// editCues applies a cue edit to the stored content and saves it as a manual edit. revision is the content hash
+// the editor read; the edit fails with ErrContentChanged when the content changed since.
+func (s *subtitleService) editCues(id uint, revision, author string, edit func(doc *translator.Document) error) (*models.SubtitleCues, error) {
+	subtitle, err := s.GetSubtitleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if repository.ContentHash(subtitle.Content) != revision {
+		return nil, ErrContentChanged
+	}
+
+	doc, err := parseStoredVTT(subtitle.Content)
+	if err != nil {
+		return nil, err
+	}
+	if err := edit(doc); err != nil {
+		return nil, err
+	}
+
+	change := models.ContentChange{Source: models.RevisionManual, Author: author, BaseHash: revision}
+	updated, err := s.saveManualEdit(id, translator.FormatVTT(doc), change)
+	if err != nil {
+		return nil, err
+	}
+	return newSubtitleCues(updated)
+}
+
+// parseStoredVTT parses stored content that is about to be rewritten. Content with cues whose timing does not
+// parse is refused with ErrMalformedTimestamp, since writing it back would drop them.
+func parseStoredVTT(content string) (*translator.Document, error) {
+	doc, err := translator.ParseVTT(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored subtitle: %w", err)
+	}
+	if len(doc.Skipped) > 0 {
+		return nil, fmt.Errorf("%w: %d cues cannot be parsed", ErrMalformedTimestamp, len(doc.Skipped))
+	}
+	return doc, nil
+}
+
+func newSubtitleCues(subtitle *models.SubtitleWithContent) (*models.SubtitleCues, error) {
+	doc, err := translator.ParseVTT(subtitle.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored subtitle: %w", err)
+	}
+	return &models.SubtitleCues{
+		ID:       subtitle.ID,
+		Revision: repository.ContentHash(subtitle.Content),
+		Cues:     doc.CueViews(),
+	}, nil
+}
+
+func (s *subtitleService) getRevision(id uint, number int) (*models.SubtitleRevision, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	revision, err := s.repo.GetRevision(id, number)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrRevisionNotFound
+	}
+	return revision, err
+}
+
+func (s *subtitleService) loadRevision(id uint, number int) (*translator.Document, error) {
+	revision, err := s.getRevision(id, number)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.repo.LoadContent(revision.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revision content: %w", err)
+	}
+	doc, err := translator.ParseVTT(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse revision %d: %w", number, err)
+	}
+	return doc, nil
+}
+
+func openMKVSource(url, referer string, upload *translator.MKVSource) (translator.MKVSource, error) {
+	if upload != nil {
+		return *upload, nil
+	}
+	if url == "" {
+		return translator.MKVSource{}, errors.New("either a file upload or a URL is required")
+	}
+	return translator.OpenRemoteMKV(url, referer)
+}
+
+// subtitleFingerprint canonically describes how a translation is made: its languages, the requested format, the
+// translation engine and register, the output variant and any other non-default options. Subtitles of one URL
+// with different fingerprints are stored side by side.
+func subtitleFingerprint(targetLang, sourceLang, format string, opts translator.Options) string {
+	targetLang = strings.ToLower(strings.TrimSpace(targetLang))
+	fields := []string{
+		"target_lang=" + targetLang,
+		"source_lang=" + normalizeSourceLang(sourceLang),
+		"format=" + strings.ToLower(strings.TrimSpace(format)),
+		"engine=" + translator.Engine,
+		"register=" + translator.Register(targetLang),
+		"output=" + opts.Variant(),
+	}
+	if key := opts.CacheKey(); key != "" {
+		fields = append(fields, "options="+key)
+	}
+	return strings.Join(fields, "&")
+}
+
+// normalizeSourceLang treats a missing source language as detected.
+func normalizeSourceLang(sourceLang string) string {
+	sourceLang = strings.ToLower(strings.TrimSpace(sourceLang))
+	if sourceLang == "" {
+		return "auto"
+	}
+	return sourceLang
+}
+
+// subtitleKey is the subtitle ID of the translation of url described by fingerprint.
+func subtitleKey(url, fingerprint string) string {
+	hash := md5.Sum([]byte(url + "|" + fingerprint))
+	return hex.EncodeToString(hash[:])
+}
+
+// legacySubtitleID is the subtitle ID used before options fingerprints, made of url, targetLang, format and the
+// cache key of non-default options.
+func legacySubtitleID(url, targetLang, format, variantKey string) string {
 	key := fmt.Sprintf("%s|%s|%s", url, targetLang, format)
+	if variantKey != "" {
+		key += "|" + variantKey
+	}
 	hash := md5.Sum([]byte(key))
 	return hex.EncodeToString(hash[:])
 }
+
+func (s *subtitleService) newSubtitleWithContent(subtitle *models.Subtitle, content string) *models.SubtitleWithContent {
+	return &models.SubtitleWithContent{
+		ID:          subtitle.ID,
+		SubtitleID:  subtitle.SubtitleID,
+		URL:         subtitle.URL,
+		TargetLang:  subtitle.TargetLang,
+		SourceLang:  subtitle.SourceLang,
+		Format:      subtitle.Format,
+		Variant:     subtitle.Variant,
+		Fingerprint: subtitle.Fingerprint,
+		FilePath:    subtitle.FilePath,
+		FileURL:     s.repo.FileURL(subtitle.FilePath),
+		Content:     content,
+		FileSize:    subtitle.FileSize,
+		IsLock:      subtitle.IsLock,
+		SourceHash:  subtitle.SourceHash,
+		ExpiresAt:   subtitle.ExpiresAt,
+		CreatedAt:   subtitle.CreatedAt,
+		UpdatedAt:   subtitle.UpdatedAt,
+		Lyrics:      decodeLyricNotes(subtitle.Lyrics),
+		CueLocks:    decodeCueLocks(subtitle.CueLocks),
+		Timing:      decodeTimingAdjustments(subtitle.Timing),
+
+		LintFindings: subtitle.LintFindings,
+	}
+}
+
+// countLintFindings lints content about to be stored and returns how many findings it has. Nothing is fixed: the
+// count only tells which subtitles are worth a look with the validation endpoints.
+func countLintFindings(content string) int {
+	result, err := translator.Lint(content, "vtt", translator.LintOptions{})
+	if err != nil {
+		log.Printf("Failed to lint subtitle content: %v", err)
+		return 0
+	}
+	return len(result.Findings)
+}
+
+// encodeLyricNotes stores the lyric cues of a translation report alongside the subtitle.
+func encodeLyricNotes(report *translator.Report) string {
+	if report == nil || len(report.Lyrics) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(report.Lyrics)
+	if err != nil {
+		log.Printf("Failed to encode lyric cues: %v", err)
+		return ""
+	}
+	return string(encoded)
+}
+
+func decodeLyricNotes(stored string) []translator.CueNote {
+	if stored == "" {
+		return nil
+	}
+	var notes []translator.CueNote
+	if err := json.Unmarshal([]byte(stored), &notes); err != nil {
+		log.Printf("Failed to decode stored lyric cues: %v", err)
+		return nil
+	}
+	return notes
+}
+
+// lockEditedCues updates cue locks for a manual edit from previous to content. Locks are matched to source cues,
+// so both versions are compared on the source timeline, before timing. Content that does not parse leaves the
+// locks as they were.
+func lockEditedCues(previous, content string, locks []translator.CueLock, timing []translator.TimingAdjustment) []translator.CueLock {
+	before, err := translator.ParseVTT(previous)
+	if err != nil {
+		log.Printf("Failed to parse previous content, cue locks unchanged: %v", err)
+		return locks
+	}
+	after, err := translator.ParseVTT(content)
+	if err != nil {
+		log.Printf("Failed to parse edited content, cue locks unchanged: %v", err)
+		return locks
+	}
+	if err := before.RevertTiming(timing); err != nil {
+		log.Printf("Failed to revert timing of previous content, cue locks unchanged: %v", err)
+		return locks
+	}
+	if err := after.RevertTiming(timing); err != nil {
+		log.Printf("Failed to revert timing of edited content, cue locks unchanged: %v", err)
+		return locks
+	}
+	return translator.LockEditedCues(before, after, locks)
+}
+
+// encodeCueLocks stores cue locks alongside the subtitle.
+func encodeCueLocks(locks []translator.CueLock) string {
+	if len(locks) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(locks)
+	if err != nil {
+		log.Printf("Failed to encode cue locks: %v", err)
+		return ""
+	}
+	return string(encoded)
+}
+
+func decodeCueLocks(stored string) []translator.CueLock {
+	if stored == "" {
+		return nil
+	}
+	var locks []translator.CueLock
+	if err := json.Unmarshal([]byte(stored), &locks); err != nil {
+		log.Printf("Failed to decode stored cue locks: %v", err)
+		return nil
+	}
+	return locks
+}
+
+// replayTiming applies the timing adjustments stored with a subtitle to a new translation of its source.
+func replayTiming(content, stored string) (string, error) {
+	adjustments := decodeTimingAdjustments(stored)
+	if len(adjustments) == 0 {
+		return content, nil
+	}
+	doc, err := translator.ParseVTT(content)
+	if err != nil {
+		return "", err
+	}
+	if err := doc.ReplayTiming(adjustments); err != nil {
+		return "", err
+	}
+	return translator.FormatVTT(doc), nil
+}
+
+// encodeTimingAdjustments stores timing adjustments alongside the subtitle, in the order they were made.
+func encodeTimingAdjustments(adjustments []translator.TimingAdjustment) string {
+	if len(adjustments) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(adjustments)
+	if err != nil {
+		log.Printf("Failed to encode timing adjustments: %v", err)
+		return ""
+	}
+	return string(encoded)
+}
+
+func decodeTimingAdjustments(stored string) []translator.TimingAdjustment {
+	if stored == "" {
+		return nil
+	}
+	var adjustments []translator.TimingAdjustment
+	if err := json.Unmarshal([]byte(stored), &adjustments); err != nil {
+		log.Printf("Failed to decode stored timing adjustments: %v", err)
+		return nil
+	}
+	return adjustments
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,28 +12,67 @@ import (
 	"time"
 
 	"subtitle-translator/internal/models"
+	"subtitle-translator/internal/urlnorm"
+	"subtitle-translator/pkg/translator"
+
+	"gorm.io/gorm"
 )
 
 type fakeSubtitleRepository struct {
 	subtitleByID       *models.Subtitle
+	bySubtitleID       map[string]*models.Subtitle
 	subtitleByPrimary  *models.Subtitle
+	all                []models.Subtitle
 	updatedContent     string
 	updateContentCalls int
+	lastChange         models.ContentChange
+	revisions          []models.SubtitleRevision
+	source             *models.SubtitleSource
+	savedSource        string
+	sameSource         []models.Subtitle
+	created            *models.Subtitle
+	createdContent     string
+	withoutFingerprint []models.Subtitle
+	updated            []models.Subtitle
+	touched            int
+	stats              models.CacheStats
+	evictable          []models.Subtitle
+	evicted            []uint
+}
+
+// attachSource stores subtitle.Source the way the repository does with the row.
+func (f *fakeSubtitleRepository) attachSource(subtitle *models.Subtitle) {
+	if subtitle.Source != nil {
+		f.SaveSource(subtitle.Source, subtitle.Source.Content)
+		subtitle.SourceHash = subtitle.Source.ContentHash
+	}
 }
 
 func (f *fakeSubtitleRepository) Create(subtitle *models.Subtitle, content string) error {
-	return errors.New("not implemented in test")
+	f.attachSource(subtitle)
+	f.created = subtitle
+	f.createdContent = content
+	return nil
 }
 
 func (f *fakeSubtitleRepository) GetBySubtitleID(subtitleID string) (*models.Subtitle, error) {
+	if f.bySubtitleID != nil {
+		if subtitle, ok := f.bySubtitleID[subtitleID]; ok {
+			return subtitle, nil
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
 	if f.subtitleByID == nil {
-		return nil, errors.New("not found")
+		return nil, gorm.ErrRecordNotFound
 	}
 	return f.subtitleByID, nil
 }
 
 func (f *fakeSubtitleRepository) GetAll(page, limit int, targetLang string) ([]models.Subtitle, int64, error) {
-	return nil, 0, nil
+	if page > 1 {
+		return nil, int64(len(f.all)), nil
+	}
+	return f.all, int64(len(f.all)), nil
 }
 
 func (f *fakeSubtitleRepository) GetByID(id uint) (*models.Subtitle, error) {
@@ -41,14 +83,20 @@ func (f *fakeSubtitleRepository) GetByID(id uint) (*models.Subtitle, error) {
 }
 
 func (f *fakeSubtitleRepository) Update(subtitle *models.Subtitle) error {
+	f.updated = append(f.updated, *subtitle)
 	f.subtitleByID = subtitle
 	f.subtitleByPrimary = subtitle
 	return nil
 }
 
-func (f *fakeSubtitleRepository) UpdateContent(id uint, content string) error {
+func (f *fakeSubtitleRepository) UpdateContent(id uint, content string, change models.ContentChange) error {
 	f.updatedContent = content
 	f.updateContentCalls++
+	f.lastChange = change
+	if change.Subtitle != nil {
+		f.attachSource(change.Subtitle)
+		f.Update(change.Subtitle)
+	}
 	return os.WriteFile(f.subtitleByID.FilePath, []byte(content), 0644)
 }
 
@@ -56,6 +104,104 @@ func (f *fakeSubtitleRepository) Delete(id uint) error {
 	return nil
 }
 
+func (f *fakeSubtitleRepository) LoadContent(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	return string(content), err
+}
+
+func (f *fakeSubtitleRepository) FileURL(filePath string) string {
+	return "/storage/" + filepath.Base(filePath)
+}
+
+func (f *fakeSubtitleRepository) ListRevisions(subtitleID uint) ([]models.SubtitleRevision, error) {
+	return f.revisions, nil
+}
+
+func (f *fakeSubtitleRepository) GetRevision(subtitleID uint, number int) (*models.SubtitleRevision, error) {
+	for i := range f.revisions {
+		if f.revisions[i].Number == number {
+			return &f.revisions[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeSubtitleRepository) SaveSource(source *models.SubtitleSource, content string) error {
+	source.ContentHash = "hash:" + content
+	f.savedSource = content
+	return nil
+}
+
+func (f *fakeSubtitleRepository) GetSource(contentHash string) (*models.SubtitleSource, error) {
+	if f.source == nil || f.source.ContentHash != contentHash {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return f.source, nil
+}
+
+func (f *fakeSubtitleRepository) FindSourceByURL(url string) (*models.SubtitleSource, error) {
+	if f.source == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return f.source, nil
+}
+
+func (f *fakeSubtitleRepository) FindBySourceContent(normalizedHash, targetLang string) ([]models.Subtitle, error) {
+	return f.sameSource, nil
+}
+
+func (f *fakeSubtitleRepository) ListByURL(url string) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	for _, subtitle := range f.all {
+		if subtitle.URL == url {
+			subtitles = append(subtitles, subtitle)
+		}
+	}
+	return subtitles, nil
+}
+
+func (f *fakeSubtitleRepository) Touch(id uint, at time.Time) error {
+	f.touched++
+	return nil
+}
+
+func (f *fakeSubtitleRepository) SetMissingExpiry(ttl time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeSubtitleRepository) CacheStats(now time.Time) (*models.CacheStats, error) {
+	stats := f.stats
+	return &stats, nil
+}
+
+func (f *fakeSubtitleRepository) ListEvictable(limit int) ([]models.Subtitle, error) {
+	if len(f.evictable) > limit {
+		return f.evictable[:limit], nil
+	}
+	return f.evictable, nil
+}
+
+func (f *fakeSubtitleRepository) Evict(id uint) (int64, error) {
+	for i, subtitle := range f.evictable {
+		if subtitle.ID == id {
+			f.evictable = append(f.evictable[:i:i], f.evictable[i+1:]...)
+			f.evicted = append(f.evicted, id)
+			return subtitle.FileSize, nil
+		}
+	}
+	return 0, gorm.ErrRecordNotFound
+}
+
+func (f *fakeSubtitleRepository) ListWithoutFingerprint(afterID uint, limit int) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	for _, subtitle := range f.withoutFingerprint {
+		if subtitle.ID > afterID && len(subtitles) < limit {
+			subtitles = append(subtitles, subtitle)
+		}
+	}
+	return subtitles, nil
+}
+
 func TestTranslateSubtitle_ExistingCachedContentIsNormalizedWhenRefreshFalse(t *testing.T) {
 	tempDir := t.TempDir()
 	filePath := filepath.Join(tempDir, "cached.vtt")
@@ -80,9 +226,9 @@ func TestTranslateSubtitle_ExistingCachedContentIsNormalizedWhenRefreshFalse(t *
 	}
 
 	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
-	svc := NewSubtitleService(repo)
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
 
-	result, err := svc.TranslateSubtitle(sub.URL, sub.Format, sub.TargetLang, sub.SourceLang, "https://example.com", false, false)
+	result, err := svc.TranslateSubtitle(sub.URL, sub.Format, sub.TargetLang, sub.SourceLang, "https://example.com", false, false, translator.Options{})
 	if err != nil {
 		t.Fatalf("TranslateSubtitle returned error: %v", err)
 	}
@@ -103,3 +249,810 @@ func TestTranslateSubtitle_ExistingCachedContentIsNormalizedWhenRefreshFalse(t *
 		t.Fatalf("expected normalized cached content to be persisted")
 	}
 }
+
+func TestAdjustTiming_RewritesStoredContent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "timed.vtt")
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef", TargetLang: "en", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	result, err := svc.AdjustTiming(4, translator.TimingAdjustment{Operation: translator.TimingShift, Offset: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("AdjustTiming returned error: %v", err)
+	}
+
+	want := "WEBVTT\n\n00:00:02.500 --> 00:00:03.500\nHalo!\n"
+	if repo.updatedContent != want || result.Content != want {
+		t.Fatalf("unexpected adjusted content: stored %q, returned %q", repo.updatedContent, result.Content)
+	}
+	if repo.lastChange.Source != models.RevisionTiming {
+		t.Fatalf("expected a timing revision, got %+v", repo.lastChange)
+	}
+}
+
+func TestAdjustTiming_IsKeptByRefresh(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "timed.vtt")
+	sourcePath := filepath.Join(dir, "source.vtt")
+	if err := os.WriteFile(filePath, []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+	source := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello!\n"
+	if err := os.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to prepare source file: %v", err)
+	}
+
+	sub := &models.Subtitle{
+		ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "en", Format: "vtt",
+		FilePath: filePath, SourceHash: "stored",
+	}
+	repo := &fakeSubtitleRepository{
+		subtitleByID:      sub,
+		subtitleByPrimary: sub,
+		source:            &models.SubtitleSource{ContentHash: "stored", Format: "vtt", FilePath: sourcePath},
+	}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	shift := translator.TimingAdjustment{Operation: translator.TimingShift, Offset: 1500 * time.Millisecond}
+	if _, err := svc.AdjustTiming(4, shift); err != nil {
+		t.Fatalf("AdjustTiming returned error: %v", err)
+	}
+	if timing := decodeTimingAdjustments(sub.Timing); len(timing) != 1 || timing[0] != shift {
+		t.Fatalf("expected the adjustment to be stored with the subtitle, got %q", sub.Timing)
+	}
+
+	// The new source adds a cue; the translation is made on the source timeline
+	var reuse []translator.CueLock
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		reuse = opts.Reuse
+		return "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n\n00:00:03.000 --> 00:00:04.000\nApa kabar?\n", nil, nil
+	}
+	changed := func(etag, lastModified string) (*translator.Source, error) {
+		return &translator.Source{Content: source + "\n00:00:03.000 --> 00:00:04.000\nHow are you?\n", Format: "vtt"}, nil
+	}
+	result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, changed)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if len(reuse) != 1 || reuse[0].Start != time.Second || reuse[0].Text != "Halo!" {
+		t.Fatalf("expected the retimed translation to be reused on the source timeline, got %+v", reuse)
+	}
+
+	want := "WEBVTT\n\n00:00:02.500 --> 00:00:03.500\nHalo!\n\n00:00:04.500 --> 00:00:05.500\nApa kabar?\n"
+	if result.Content != want || repo.updatedContent != want {
+		t.Fatalf("expected the refresh to keep the timing adjustment, got %q", result.Content)
+	}
+	if len(result.Timing) != 1 {
+		t.Fatalf("expected the adjustment to stay with the subtitle, got %+v", result.Timing)
+	}
+}
+
+func TestAlignTiming_IsKeptByRefresh(t *testing.T) {
+	// Cues with irregular durations and gaps, so their rhythm can be matched
+	starts := []int{1000, 2500, 6200, 7000, 11800, 13100, 17500}
+	lengths := []int{900, 3100, 500, 2600, 700, 1900, 1200}
+	vtt := func(shift int) string {
+		content := "WEBVTT\n"
+		for i, start := range starts {
+			from, to := start+shift, start+shift+lengths[i]
+			content += fmt.Sprintf("\n00:00:%02d.%03d --> 00:00:%02d.%03d\nCue %d\n", from/1000, from%1000, to/1000, to%1000, i)
+		}
+		return content
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(vtt(2000)))
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "aligned.vtt")
+	if err := os.WriteFile(filePath, []byte(vtt(0)), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+	sub := &models.Subtitle{ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "en", Format: "vtt", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	if _, _, err := svc.AlignTiming(4, server.URL+"/reference.vtt", "vtt", ""); err != nil {
+		t.Fatalf("AlignTiming returned error: %v", err)
+	}
+	if timing := decodeTimingAdjustments(sub.Timing); len(timing) != 1 || timing[0].Operation != translator.TimingScale {
+		t.Fatalf("expected the alignment to be stored with the subtitle, got %q", sub.Timing)
+	}
+
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		return vtt(0), nil, nil
+	}
+	fetch := func(etag, lastModified string) (*translator.Source, error) {
+		return &translator.Source{Content: vtt(0), Format: "vtt"}, nil
+	}
+	result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, fetch)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if result.Content != vtt(2000) {
+		t.Fatalf("expected the refresh to keep the alignment, got %q", result.Content)
+	}
+}
+
+func TestTimingAndCueEdits_RefuseMalformedTimestamps(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "broken.vtt")
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n\n00:99:00.000 --> 00:99:01.000\nRusak\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef", TargetLang: "en", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	if _, err := svc.UpdateSubtitle(4, content, "editor"); !errors.Is(err, ErrMalformedTimestamp) {
+		t.Fatalf("expected UpdateSubtitle to refuse malformed timestamps, got %v", err)
+	}
+	if _, err := svc.AdjustTiming(4, translator.TimingAdjustment{Operation: translator.TimingShift, Offset: time.Second}); !errors.Is(err, ErrMalformedTimestamp) {
+		t.Fatalf("expected AdjustTiming to refuse stored malformed timestamps, got %v", err)
+	}
+	cues, err := svc.ListCues(4)
+	if err != nil {
+		t.Fatalf("ListCues returned error: %v", err)
+	}
+	if _, err := svc.DeleteCue(4, "1", cues.Revision, "editor"); !errors.Is(err, ErrMalformedTimestamp) {
+		t.Fatalf("expected DeleteCue to refuse stored malformed timestamps, got %v", err)
+	}
+	if repo.updateContentCalls != 0 {
+		t.Fatalf("expected the stored content to be left alone, got %q", repo.updatedContent)
+	}
+}
+
+func TestValidateStoredSubtitles_FixesAndReportsFindings(t *testing.T) {
+	dir := t.TempDir()
+	cleanPath := filepath.Join(dir, "clean.vtt")
+	brokenPath := filepath.Join(dir, "broken.vtt")
+	if err := os.WriteFile(cleanPath, []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+	broken := "WEBVTT\n\n00:00:01.000 --> 00:00:03.000\nHalo!\n\n00:00:02.000 --> 00:00:04.000\nApa kabar?\n"
+	if err := os.WriteFile(brokenPath, []byte(broken), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	clean := models.Subtitle{ID: 1, SubtitleID: "clean", FilePath: cleanPath}
+	sub := models.Subtitle{ID: 2, SubtitleID: "broken", FilePath: brokenPath}
+	repo := &fakeSubtitleRepository{subtitleByID: &sub, subtitleByPrimary: &sub, all: []models.Subtitle{clean, sub}}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	results, err := svc.ValidateStoredSubtitles(translator.LintOptions{Fix: true})
+	if err != nil {
+		t.Fatalf("ValidateStoredSubtitles returned error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].ID != 2 || results[0].Fixed != 1 {
+		t.Fatalf("expected one fixed subtitle, got %+v", results)
+	}
+	if !strings.Contains(repo.updatedContent, "00:00:01.000 --> 00:00:02.000\nHalo!") {
+		t.Fatalf("expected overlap to be trimmed in stored content, got %q", repo.updatedContent)
+	}
+}
+
+func TestTranslateCached_PersistsDetectedLyrics(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cached.vtt")
+	if err := os.WriteFile(filePath, []byte("WEBVTT\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare cached subtitle file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 3, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "en", Format: "vtt", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	lyric := translator.CueNote{Index: 2, Start: time.Second, End: 2 * time.Second, Reason: translator.LyricReasonMusicNotes}
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		return "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n", &translator.Report{Lyrics: []translator.CueNote{lyric}}, nil
+	}
+
+	if _, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, fetchTestSource); err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if sub.Lyrics == "" {
+		t.Fatalf("expected detected lyrics to be stored on the subtitle")
+	}
+
+	result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, false, false, fetchTestSource)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if len(result.Lyrics) != 1 || result.Lyrics[0] != lyric || result.Report != nil {
+		t.Fatalf("expected stored lyrics on the cached result, got %+v report %+v", result.Lyrics, result.Report)
+	}
+}
+
+func TestTranslateCached_CountsLintFindingsOfStoredContent(t *testing.T) {
+	repo := &fakeSubtitleRepository{}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	translated := "WEBVTT\n\n00:00:01.000 --> 00:00:03.000\nHalo\n\n00:00:02.000 --> 00:00:04.000\nApa kabar?\n"
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		return translated, nil, nil
+	}
+
+	result, err := svc.translateCached("https://example.com/a.vtt", "vtt", "id", "", translator.Options{}, false, false, fetchTestSource)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if repo.created == nil || repo.created.LintFindings != 1 || result.LintFindings != 1 {
+		t.Fatalf("expected the overlapping cues to be counted, got %+v", result)
+	}
+
+	repo.created.FilePath = filepath.Join(t.TempDir(), "stored.vtt")
+	if err := os.WriteFile(repo.created.FilePath, []byte(repo.createdContent), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+	repo.subtitleByID, repo.subtitleByPrimary = repo.created, repo.created
+	translated = "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo\n\n00:00:03.000 --> 00:00:04.000\nApa kabar?\n"
+	fetchChanged := func(etag, lastModified string) (*translator.Source, error) {
+		return &translator.Source{Content: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n", Format: "vtt", Encoding: translator.EncodingUTF8}, nil
+	}
+	result, err = svc.translateCached("https://example.com/a.vtt", "vtt", "id", "", translator.Options{}, true, false, fetchChanged)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if repo.created.LintFindings != 0 || result.LintFindings != 0 {
+		t.Fatalf("expected the refresh to reset the count, got %d", result.LintFindings)
+	}
+}
+
+func TestRollbackSubtitle_RestoresRevisionContent(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "current.vtt")
+	firstPath := filepath.Join(dir, "first.vtt")
+	first := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n"
+	if err := os.WriteFile(filePath, []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHai!\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+	if err := os.WriteFile(firstPath, []byte(first), 0644); err != nil {
+		t.Fatalf("failed to prepare revision file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef", TargetLang: "en", FilePath: filePath}
+	repo := &fakeSubtitleRepository{
+		subtitleByID:      sub,
+		subtitleByPrimary: sub,
+		revisions:         []models.SubtitleRevision{{SubtitleID: 4, Number: 1, FilePath: firstPath}},
+	}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	result, err := svc.RollbackSubtitle(4, 1, "editor")
+	if err != nil {
+		t.Fatalf("RollbackSubtitle returned error: %v", err)
+	}
+	if result.Content != first {
+		t.Fatalf("unexpected restored content: %q", result.Content)
+	}
+	if repo.lastChange.Source != models.RevisionRollback || repo.lastChange.Author != "editor" {
+		t.Fatalf("expected a rollback revision by editor, got %+v", repo.lastChange)
+	}
+
+	if _, err := svc.RollbackSubtitle(4, 7, "editor"); !errors.Is(err, ErrRevisionNotFound) {
+		t.Fatalf("expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestDiffRevisions_ComparesStoredRevisions(t *testing.T) {
+	dir := t.TempDir()
+	firstPath := filepath.Join(dir, "first.vtt")
+	secondPath := filepath.Join(dir, "second.vtt")
+	if err := os.WriteFile(firstPath, []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare revision file: %v", err)
+	}
+	if err := os.WriteFile(secondPath, []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHai!\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare revision file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef"}
+	repo := &fakeSubtitleRepository{
+		subtitleByPrimary: sub,
+		revisions: []models.SubtitleRevision{
+			{SubtitleID: 4, Number: 1, FilePath: firstPath},
+			{SubtitleID: 4, Number: 2, FilePath: secondPath},
+		},
+	}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	changes, err := svc.DiffRevisions(4, 1, 2)
+	if err != nil {
+		t.Fatalf("DiffRevisions returned error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Type != translator.CueModified || changes[0].To.Text != "Hai!" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestUpdateSubtitle_LocksEditedCuesForRefresh(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "edited.vtt")
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n\n00:00:03.000 --> 00:00:04.000\nApa kabar?\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 3, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "id", Format: "vtt", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	edited := strings.Replace(content, "Apa kabar?", "Gimana kabarnya?", 1)
+	result, err := svc.UpdateSubtitle(3, edited, "editor")
+	if err != nil {
+		t.Fatalf("UpdateSubtitle returned error: %v", err)
+	}
+	if len(result.CueLocks) != 1 || result.CueLocks[0].Text != "Gimana kabarnya?" || result.CueLocks[0].Start != 3*time.Second {
+		t.Fatalf("expected the edited cue to be locked, got %+v", result.CueLocks)
+	}
+
+	var passed []translator.CueLock
+	kept := translator.CueLock{Start: 3 * time.Second, End: 4 * time.Second, Text: "Gimana kabarnya?", Source: "How are you?"}
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		passed = opts.Locks
+		return edited, &translator.Report{Locks: []translator.CueLock{kept}}, nil
+	}
+	if _, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, fetchTestSource); err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if len(passed) != 1 || passed[0].Text != "Gimana kabarnya?" {
+		t.Fatalf("expected stored locks to be passed to the refresh, got %+v", passed)
+	}
+	if locks := decodeCueLocks(sub.CueLocks); len(locks) != 1 || locks[0] != kept {
+		t.Fatalf("expected the locks of the refresh to be stored, got %+v", locks)
+	}
+}
+
+func TestEditCue_WritesThroughWithRevisionCheck(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cues.vtt")
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello!\n\n00:00:03.000 --> 00:00:04.000\nHow are you?\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 3, SubtitleID: "0123456789abcdef0123456789abcdef", TargetLang: "en", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	listed, err := svc.ListCues(3)
+	if err != nil {
+		t.Fatalf("ListCues returned error: %v", err)
+	}
+
+	text := "How have you been?"
+	edited, err := svc.EditCue(3, "2", translator.CueEdit{Text: &text}, listed.Revision, "editor")
+	if err != nil {
+		t.Fatalf("EditCue returned error: %v", err)
+	}
+	if edited.Cues[1].Text != text || edited.Revision == listed.Revision {
+		t.Fatalf("unexpected cues after edit: %+v", edited)
+	}
+	if !strings.Contains(repo.updatedContent, text) || repo.lastChange.Source != models.RevisionManual || repo.lastChange.BaseHash != listed.Revision {
+		t.Fatalf("expected a manual change against the listed revision, got %+v", repo.lastChange)
+	}
+	if locks := decodeCueLocks(sub.CueLocks); len(locks) != 1 || locks[0].Text != text {
+		t.Fatalf("expected the edited cue to be locked, got %+v", locks)
+	}
+
+	if _, err := svc.DeleteCue(3, "1", listed.Revision, "editor"); !errors.Is(err, ErrContentChanged) {
+		t.Fatalf("expected ErrContentChanged for a stale revision, got %v", err)
+	}
+}
+
+func fetchTestSource(etag, lastModified string) (*translator.Source, error) {
+	return &translator.Source{Content: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo\n", Format: "vtt", Encoding: translator.EncodingUTF8}, nil
+}
+
+func TestTranslateCached_RefreshStoresSourceAndKeepsTranslationWhenFetchFails(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cached.vtt")
+	if err := os.WriteFile(filePath, []byte("WEBVTT\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare cached subtitle file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 3, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "en", Format: "vtt", FilePath: filePath}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	translations := 0
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		translations++
+		return src.Content, nil, nil
+	}
+
+	if _, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, fetchTestSource); err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	fetched, _ := fetchTestSource("", "")
+	if repo.savedSource != fetched.Content || sub.SourceHash != "hash:"+fetched.Content {
+		t.Fatalf("expected the fetched source to be stored and linked, got %q hash %q", repo.savedSource, sub.SourceHash)
+	}
+
+	sub.SourceETag = `"v1"`
+	failing := func(etag, lastModified string) (*translator.Source, error) {
+		return nil, errors.New("origin unavailable")
+	}
+	result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, failing)
+	if err != nil {
+		t.Fatalf("expected the refresh to keep the stored translation, got %v", err)
+	}
+	if translations != 1 || result.Content != fetched.Content || result.SourceUnchanged {
+		t.Fatalf("expected the stored translation without translating again, got %d translations and %+v", translations, result)
+	}
+	if sub.SourceETag != `"v1"` || sub.SourceHash != "hash:"+fetched.Content {
+		t.Fatalf("expected the stored validators and source to be kept, got %q and %q", sub.SourceETag, sub.SourceHash)
+	}
+}
+
+func TestGetSubtitleSource(t *testing.T) {
+	sourcePath := filepath.Join(t.TempDir(), "source.ass")
+	if err := os.WriteFile(sourcePath, []byte("[Script Info]\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare source file: %v", err)
+	}
+
+	sub := &models.Subtitle{ID: 3, SubtitleID: "0123456789abcdef0123456789abcdef"}
+	repo := &fakeSubtitleRepository{subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{})
+
+	if _, err := svc.GetSubtitleSource(3); !errors.Is(err, ErrSourceNotStored) {
+		t.Fatalf("expected ErrSourceNotStored without a source, got %v", err)
+	}
+
+	sub.SourceHash = "abc"
+	repo.source = &models.SubtitleSource{ContentHash: "abc", Format: "ass", Encoding: translator.EncodingWindows1252, FilePath: sourcePath}
+	source, err := svc.GetSubtitleSource(3)
+	if err != nil {
+		t.Fatalf("GetSubtitleSource returned error: %v", err)
+	}
+	if source.Content != "[Script Info]\n" || source.Format != "ass" {
+		t.Fatalf("unexpected source: %+v", source)
+	}
+}
+
+func TestRefreshSubtitle_SkipsUnchangedSourceAndReusesUnchangedCues(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "cached.vtt")
+	sourcePath := filepath.Join(dir, "source.vtt")
+	translated := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo!\n\n00:00:03.000 --> 00:00:04.000\nApa kabar?\n"
+	if err := os.WriteFile(filePath, []byte(translated), 0644); err != nil {
+		t.Fatalf("failed to prepare cached subtitle file: %v", err)
+	}
+	source := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello!\n\n00:00:03.000 --> 00:00:04.000\nHow are you?\n"
+	if err := os.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		t.Fatalf("failed to prepare source file: %v", err)
+	}
+
+	sub := &models.Subtitle{
+		ID: 3, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "en", Format: "vtt",
+		FilePath: filePath, SourceHash: "stored", SourceETag: `"v1"`,
+	}
+	repo := &fakeSubtitleRepository{
+		subtitleByID:      sub,
+		subtitleByPrimary: sub,
+		source:            &models.SubtitleSource{ContentHash: "stored", Format: "vtt", FilePath: sourcePath},
+	}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+
+	var reuse []translator.CueLock
+	translations := 0
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		translations++
+		reuse = opts.Reuse
+		return translated, &translator.Report{Reused: len(opts.Reuse)}, nil
+	}
+
+	var etag string
+	notModified := func(e, lastModified string) (*translator.Source, error) {
+		etag = e
+		return nil, translator.ErrSourceNotModified
+	}
+	result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, notModified)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if etag != `"v1"` || !result.SourceUnchanged || result.Content != translated || translations != 0 {
+		t.Fatalf("expected a conditional fetch and no translation, got etag %q unchanged %v translations %d", etag, result.SourceUnchanged, translations)
+	}
+
+	changed := func(e, lastModified string) (*translator.Source, error) {
+		content := strings.Replace(source, "How are you?", "How are you doing?", 1)
+		return &translator.Source{Content: content, Format: "vtt", ETag: `"v2"`}, nil
+	}
+	result, err = svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, true, false, changed)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if len(reuse) != 1 || reuse[0].Text != "Halo!" {
+		t.Fatalf("expected the unchanged cue to keep its translation, got %+v", reuse)
+	}
+	if len(result.SourceChanges) != 1 || result.SourceChanges[0].Type != translator.CueModified || result.SourceChanges[0].ToIndex != 2 {
+		t.Fatalf("expected the changed source cue to be returned, got %+v", result.SourceChanges)
+	}
+	if sub.SourceETag != `"v2"` {
+		t.Fatalf("expected the new validators to be stored, got %q", sub.SourceETag)
+	}
+}
+
+func TestTranslateCached_NormalizesURLAndCopiesTranslationOfSameSource(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "other.vtt")
+	translated := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo\n"
+	if err := os.WriteFile(filePath, []byte(translated), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+	editedPath := filepath.Join(dir, "edited.vtt")
+	if err := os.WriteFile(editedPath, []byte("WEBVTT\n\n00:00:02.000 --> 00:00:03.000\nHalo, kawan\n"), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	svc := NewSubtitleService(nil, urlnorm.New(urlnorm.DefaultRules), CachePolicy{}).(*subtitleService)
+	mirror := "https://mirror.example/ep1.vtt"
+	plain := subtitleFingerprint("en", "auto", "vtt", translator.Options{})
+	stripped := subtitleFingerprint("en", "auto", "vtt", translator.Options{SDH: translator.SDHStrip})
+	repo := &fakeSubtitleRepository{sameSource: []models.Subtitle{
+		// Translated with other options, so not reused
+		{SubtitleID: subtitleKey(mirror, stripped), Fingerprint: stripped, URL: mirror, TargetLang: "en", Format: "vtt", FilePath: "missing.vtt"},
+		// Manually corrected or retimed, so not a machine translation
+		{SubtitleID: subtitleKey(mirror+"?v=2", plain), Fingerprint: plain, URL: mirror, TargetLang: "en", Format: "vtt", FilePath: editedPath, CueLocks: `[{"start":1000000000}]`},
+		{SubtitleID: subtitleKey(mirror+"?v=3", plain), Fingerprint: plain, URL: mirror, TargetLang: "en", Format: "vtt", FilePath: editedPath, Timing: `[{"operation":"shift","offset":1000000000}]`},
+		{SubtitleID: subtitleKey(mirror, plain), Fingerprint: plain, URL: mirror, TargetLang: "en", Format: "vtt", FilePath: filePath, Lyrics: "[]"},
+	}}
+	svc.repo = repo
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		t.Fatalf("expected the translation of the same source to be reused")
+		return "", nil, nil
+	}
+
+	fetched := false
+	fetch := func(etag, lastModified string) (*translator.Source, error) {
+		fetched = true
+		return fetchTestSource(etag, lastModified)
+	}
+	result, err := svc.translateCached("https://cdn.example/ep1.vtt?token=abc&expires=1", "vtt", "en", "", translator.Options{}, false, false, fetch)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if !fetched || result.URL != "https://cdn.example/ep1.vtt" || result.SubtitleID != subtitleKey("https://cdn.example/ep1.vtt", plain) {
+		t.Fatalf("expected the normalized URL to be the cache identity, got %q (%s)", result.URL, result.SubtitleID)
+	}
+	if result.Content != translated || repo.createdContent != translated || repo.created.Lyrics != "[]" {
+		t.Fatalf("expected the translation of the same source to be copied, got %q", result.Content)
+	}
+	if repo.created.CueLocks != "" || repo.created.Timing != "" {
+		t.Fatalf("expected the copy to start without cue locks or timing, got %q and %q", repo.created.CueLocks, repo.created.Timing)
+	}
+}
+
+func TestSubtitleFingerprint_SeparatesVariants(t *testing.T) {
+	plain := subtitleFingerprint("id", "", "vtt", translator.Options{})
+	if plain != "target_lang=id&source_lang=auto&format=vtt&engine=google-gtx&register=informal&output=translated" {
+		t.Fatalf("unexpected fingerprint: %q", plain)
+	}
+	if other := subtitleFingerprint(" ID ", "AUTO", "VTT", translator.Options{}); other != plain {
+		t.Fatalf("expected the fingerprint to be canonical, got %q", other)
+	}
+
+	variants := []string{
+		subtitleFingerprint("id", "ja", "vtt", translator.Options{}),
+		subtitleFingerprint("en", "", "vtt", translator.Options{}),
+		subtitleFingerprint("id", "", "srt", translator.Options{}),
+		subtitleFingerprint("id", "", "vtt", translator.Options{Output: translator.OutputBilingual}),
+		subtitleFingerprint("id", "", "vtt", translator.Options{SDH: translator.SDHStrip}),
+	}
+	for _, variant := range variants {
+		if variant == plain {
+			t.Fatalf("expected %q to differ from the plain fingerprint", variant)
+		}
+	}
+}
+
+func TestTranslateCached_AdoptsLegacySubtitleWithSameSourceLanguage(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "legacy.vtt")
+	translated := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n"
+	if err := os.WriteFile(filePath, []byte(translated), 0644); err != nil {
+		t.Fatalf("failed to prepare subtitle file: %v", err)
+	}
+
+	url := "https://cdn.example/ep1.vtt"
+	opts := translator.Options{SDH: translator.SDHStrip}
+	legacyID := legacySubtitleID(url, "en", "vtt", opts.CacheKey())
+	legacy := &models.Subtitle{ID: 7, SubtitleID: legacyID, URL: url, TargetLang: "en", SourceLang: "auto", Format: "vtt", FilePath: filePath}
+	repo := &fakeSubtitleRepository{bySubtitleID: map[string]*models.Subtitle{legacyID: legacy}}
+	svc := NewSubtitleService(repo, nil, CachePolicy{}).(*subtitleService)
+	svc.translate = func(src *translator.Source, targetLang, sourceLang string, opts translator.Options) (string, *translator.Report, error) {
+		return "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHallo\n", nil, nil
+	}
+	fetch := func(etag, lastModified string) (*translator.Source, error) {
+		return fetchTestSource(etag, lastModified)
+	}
+
+	result, err := svc.translateCached(url, "vtt", "en", "", opts, false, false, fetch)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	fingerprint := subtitleFingerprint("en", "", "vtt", opts)
+	if result.Content != translated || result.Fingerprint != fingerprint || result.SubtitleID != subtitleKey(url, fingerprint) {
+		t.Fatalf("expected the legacy subtitle to be adopted, got %+v", result)
+	}
+	if len(repo.updated) != 1 || repo.updated[0].SubtitleID != subtitleKey(url, fingerprint) {
+		t.Fatalf("expected the adopted subtitle to be stored under its new key, got %+v", repo.updated)
+	}
+
+	// Legacy keys ignored the source language, so another one is a new variant
+	legacy.SubtitleID, legacy.Fingerprint = legacyID, ""
+	repo.updated = nil
+	result, err = svc.translateCached(url, "vtt", "en", "ja", opts, false, false, fetch)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if len(repo.updated) != 0 || repo.created == nil || result.Fingerprint != subtitleFingerprint("en", "ja", "vtt", opts) {
+		t.Fatalf("expected a new variant for another source language, got %+v", result)
+	}
+}
+
+func TestMigrateSubtitleKeys(t *testing.T) {
+	url := "https://cdn.example/ep1.vtt"
+	repo := &fakeSubtitleRepository{withoutFingerprint: []models.Subtitle{
+		{ID: 1, SubtitleID: legacySubtitleID(url, "id", "vtt", ""), URL: url, TargetLang: "id", SourceLang: "auto", Format: "vtt"},
+		// Non-default options cannot be recovered from the key
+		{ID: 2, SubtitleID: legacySubtitleID(url, "id", "vtt", "sdh=strip"), URL: url, TargetLang: "id", SourceLang: "auto", Format: "vtt"},
+	}}
+
+	migrated, skipped, err := MigrateSubtitleKeys(repo, urlnorm.New(urlnorm.DefaultRules))
+	if err != nil {
+		t.Fatalf("MigrateSubtitleKeys returned error: %v", err)
+	}
+	if migrated != 1 || skipped != 1 {
+		t.Fatalf("expected 1 migrated and 1 skipped subtitle, got %d and %d", migrated, skipped)
+	}
+	fingerprint := subtitleFingerprint("id", "auto", "vtt", translator.Options{})
+	if len(repo.updated) != 1 || repo.updated[0].ID != 1 || repo.updated[0].Fingerprint != fingerprint || repo.updated[0].SubtitleID != subtitleKey(url, fingerprint) {
+		t.Fatalf("expected the default subtitle to be moved to its fingerprinted key, got %+v", repo.updated)
+	}
+}
+
+func TestMigrateSubtitleKeys_NormalizesSignedURLs(t *testing.T) {
+	signed := "https://cdn.example/ep1.vtt?token=abc&expires=1"
+	repo := &fakeSubtitleRepository{withoutFingerprint: []models.Subtitle{
+		{ID: 1, SubtitleID: legacySubtitleID(signed, "id", "vtt", ""), URL: signed, TargetLang: "id", SourceLang: "auto", Format: "vtt"},
+	}}
+	urls := urlnorm.New(urlnorm.DefaultRules)
+
+	if migrated, _, err := MigrateSubtitleKeys(repo, urls); err != nil || migrated != 1 {
+		t.Fatalf("expected the subtitle to be migrated, got %d (%v)", migrated, err)
+	}
+
+	// A request for the same URL with another token finds the migrated row
+	url := urls.Normalize("https://cdn.example/ep1.vtt?token=xyz&expires=2")
+	fingerprint := subtitleFingerprint("id", "", "vtt", translator.Options{})
+	if len(repo.updated) != 1 || repo.updated[0].URL != url || repo.updated[0].SubtitleID != subtitleKey(url, fingerprint) {
+		t.Fatalf("expected the row to be moved to the key of its normalized URL, got %+v", repo.updated)
+	}
+}
+
+func TestTranslateCached_ServesStaleContentWhileRefreshing(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cached.vtt")
+	translated := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo\n"
+	if err := os.WriteFile(filePath, []byte(translated), 0644); err != nil {
+		t.Fatalf("failed to prepare cached subtitle file: %v", err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	sub := &models.Subtitle{
+		ID: 4, SubtitleID: "0123456789abcdef0123456789abcdef", URL: "https://example.com/a.vtt", TargetLang: "en", Format: "vtt",
+		FilePath: filePath, ExpiresAt: &expired,
+	}
+	repo := &fakeSubtitleRepository{subtitleByID: sub, subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{TTL: time.Hour}).(*subtitleService)
+	var background []func()
+	svc.spawn = func(work func()) { background = append(background, work) }
+
+	notModified := func(etag, lastModified string) (*translator.Source, error) {
+		return nil, translator.ErrSourceNotModified
+	}
+	for i := 0; i < 2; i++ {
+		result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, false, false, notModified)
+		if err != nil {
+			t.Fatalf("translateCached returned error: %v", err)
+		}
+		if !result.Stale || result.Content != translated {
+			t.Fatalf("expected the stored content to be served stale, got %+v", result)
+		}
+	}
+	if len(background) != 1 || repo.touched != 2 {
+		t.Fatalf("expected one background refresh and two recorded accesses, got %d and %d", len(background), repo.touched)
+	}
+
+	background[0]()
+	if sub.ExpiresAt == nil || !sub.ExpiresAt.After(time.Now().Add(59*time.Minute)) {
+		t.Fatalf("expected the refresh to renew the expiry, got %v", sub.ExpiresAt)
+	}
+	result, err := svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, false, false, notModified)
+	if err != nil {
+		t.Fatalf("translateCached returned error: %v", err)
+	}
+	if result.Stale || len(background) != 1 {
+		t.Fatalf("expected fresh content after the refresh")
+	}
+
+	// Locked subtitles are served as they are
+	sub.IsLock, sub.ExpiresAt = true, &expired
+	if result, err = svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, false, false, notModified); err != nil || result.Stale || len(background) != 1 {
+		t.Fatalf("expected no refresh of a locked subtitle, got %+v (%v)", result, err)
+	}
+
+	// So are uploads, whose source cannot change
+	sub.IsLock, sub.URL = false, "upload://0a1b2c#track=3"
+	if result, err = svc.translateCached(sub.URL, sub.Format, sub.TargetLang, "", translator.Options{}, false, false, notModified); err != nil || result.Stale || len(background) != 1 {
+		t.Fatalf("expected no refresh of an upload, got %+v (%v)", result, err)
+	}
+}
+
+func TestEnforceStorageQuota_EvictsLeastRecentlyUsedUntilUnderQuota(t *testing.T) {
+	repo := &fakeSubtitleRepository{
+		stats:     models.CacheStats{TotalBytes: 350},
+		evictable: []models.Subtitle{{ID: 1, FileSize: 100}, {ID: 2, FileSize: 120}, {ID: 3, FileSize: 100}},
+	}
+	svc := NewSubtitleService(repo, nil, CachePolicy{Quota: 150})
+
+	eviction, err := svc.EnforceStorageQuota()
+	if err != nil {
+		t.Fatalf("EnforceStorageQuota returned error: %v", err)
+	}
+	if eviction.Evicted != 2 || eviction.FreedBytes != 220 || eviction.UsedBytes != 130 {
+		t.Fatalf("unexpected eviction: %+v", eviction)
+	}
+	if len(repo.evicted) != 2 || repo.evicted[0] != 1 || repo.evicted[1] != 2 {
+		t.Fatalf("expected the least recently used subtitles to be evicted first, got %v", repo.evicted)
+	}
+
+	report, err := svc.CacheReport(10)
+	if err != nil {
+		t.Fatalf("CacheReport returned error: %v", err)
+	}
+	if report.QuotaBytes != 150 || !report.OverQuota || report.LastEviction != eviction || len(report.Candidates) != 1 {
+		t.Fatalf("unexpected cache report: %+v", report)
+	}
+
+	unbounded := NewSubtitleService(repo, nil, CachePolicy{})
+	if eviction, err := unbounded.EnforceStorageQuota(); eviction != nil || err != nil {
+		t.Fatalf("expected no eviction without a quota, got %+v (%v)", eviction, err)
+	}
+}
+
+func TestSetSubtitleTTL(t *testing.T) {
+	sub := &models.Subtitle{ID: 5, SubtitleID: "0123456789abcdef0123456789abcdef"}
+	repo := &fakeSubtitleRepository{subtitleByPrimary: sub}
+	svc := NewSubtitleService(repo, nil, CachePolicy{TTL: time.Hour})
+
+	if _, err := svc.SetSubtitleTTL(5, -2); !errors.Is(err, ErrInvalidTTL) {
+		t.Fatalf("expected ErrInvalidTTL, got %v", err)
+	}
+
+	tests := []struct {
+		ttl  int64
+		want time.Duration // 0 for no expiry
+	}{
+		{60, time.Minute},
+		{0, time.Hour},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		updated, err := svc.SetSubtitleTTL(5, tt.ttl)
+		if err != nil {
+			t.Fatalf("SetSubtitleTTL(%d) returned error: %v", tt.ttl, err)
+		}
+		if tt.want == 0 {
+			if updated.ExpiresAt != nil {
+				t.Fatalf("expected ttl %d to never expire, got %v", tt.ttl, updated.ExpiresAt)
+			}
+			continue
+		}
+		if updated.ExpiresAt == nil || time.Until(*updated.ExpiresAt) > tt.want || time.Until(*updated.ExpiresAt) < tt.want-time.Minute/2 {
+			t.Fatalf("expected ttl %d to expire in %s, got %v", tt.ttl, tt.want, updated.ExpiresAt)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import "testing"
+
+func TestLocalStore_RoundTripAndKeyValidation(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore returned error: %v", err)
+	}
+
+	if err := store.Put("subtitles/x.vtt", []byte("WEBVTT\n")); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if got, err := store.Get("subtitles/x.vtt"); err != nil || string(got) != "WEBVTT\n" {
+		t.Fatalf("unexpected Get result: %q, %v", got, err)
+	}
+	if info, err := store.Stat("subtitles/x.vtt"); err != nil || info.Size != 7 || info.Key != "subtitles/x.vtt" {
+		t.Fatalf("unexpected Stat result: %+v, %v", info, err)
+	}
+	if got := store.URL("subtitles/x.vtt"); got != "/storage/subtitles/x.vtt" {
+		t.Fatalf("unexpected URL: %q", got)
+	}
+
+	if objects, err := store.List("subtitles/"); err != nil || len(objects) != 1 || objects[0].Key != "subtitles/x.vtt" {
+		t.Fatalf("unexpected List result: %+v, %v", objects, err)
+	}
+
+	if err := store.Delete("subtitles/x.vtt"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := store.Get("subtitles/x.vtt"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := store.Get("../etc/passwd"); err != ErrInvalidKey {
+		t.Fatalf("expected ErrInvalidKey for an escaping key, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no content is stored under a key.
+var ErrNotFound = errors.New("stored content not found")
+
+// ErrInvalidKey is returned for keys that are empty or escape the store, such as "../secret".
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// ContentStore keeps subtitle content under slash-separated keys such as "subtitles/<id>.vtt".
+type ContentStore interface {
+	Put(key string, content []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	Stat(key string) (ObjectInfo, error)
+	// List returns every stored object whose key starts with prefix.
+	List(prefix string) ([]ObjectInfo, error)
+	// URL is where clients can download the content: a path served by this API or an absolute public URL.
+	URL(key string) string
+}
+
+// ObjectInfo describes stored content.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// DownloadPrefix is the route under which stores without a public URL are served.
+const DownloadPrefix = "/storage/"
+
+// CleanKey validates a key and returns it in canonical form.
+func CleanKey(key string) (string, error) {
+	key = strings.TrimLeft(strings.ReplaceAll(key, "\\", "/"), "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
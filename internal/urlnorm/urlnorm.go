@@ -0,0 +1,141 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Rule strips query parameters from the URLs of matching hosts.
+type Rule struct {
+	// Host is a host name, "*.example.com" for a domain and its subdomains, or "*" for every host.
+	Host string
+	// Params are the query parameter names to strip, compared case-insensitively. A trailing "*" matches a prefix.
+	Params []string
+}
+
+// DefaultRules strip the rotating parameters of signed CDN and object store URLs: generic tokens and expiry
+// times, CloudFront and S3 presigned signatures, and Akamai tokens.
+var DefaultRules = []Rule{
+	{Host: "*", Params: []string{"token", "expires", "Signature", "Key-Pair-Id", "Policy", "X-Amz-*", "hdnts", "hdntl", "__token__"}},
+}
+
+// Normalizer turns URLs into stable cache identities. A nil Normalizer leaves URLs unchanged.
+type Normalizer struct {
+	rules []Rule
+}
+
+// New returns a Normalizer applying rules.
+func New(rules []Rule) *Normalizer {
+	return &Normalizer{rules: rules}
+}
+
+// Normalize strips the query parameters the rules name for the host of raw. A URL without such parameters is
+// returned as it is, so cache keys of other URLs do not change; the remaining parameters keep their order and
+// encoding, and a fragment is kept.
+func (n *Normalizer) Normalize(raw string) string {
+	if n == nil {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	params := n.params(strings.ToLower(u.Hostname()))
+	if len(params) == 0 {
+		return raw
+	}
+
+	base, query, _ := strings.Cut(raw, "?")
+	query, fragment, hasFragment := strings.Cut(query, "#")
+
+	parts := strings.Split(query, "&")
+	kept := parts[:0:0]
+	for _, part := range parts {
+		name, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !matchParam(params, name) {
+			kept = append(kept, part)
+		}
+	}
+	if len(kept) == len(parts) {
+		return raw
+	}
+
+	normalized := base
+	if len(kept) > 0 {
+		normalized += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		normalized += "#" + fragment
+	}
+	return normalized
+}
+
+// params collects the parameters the rules strip for host.
+func (n *Normalizer) params(host string) []string {
+	var params []string
+	for _, rule := range n.rules {
+		if matchHost(strings.ToLower(rule.Host), host) {
+			params = append(params, rule.Params...)
+		}
+	}
+	return params
+}
+
+func matchHost(pattern, host string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		domain := pattern[2:]
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+	return pattern == host
+}
+
+func matchParam(params []string, name string) bool {
+	for _, param := range params {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(param, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRules reads rules written as "host=param,param;host=param", such as
+// "cdn.example.com=token,expires;*.akamaized.net=hdnts".
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, list, ok := strings.Cut(entry, "=")
+		host = strings.TrimSpace(host)
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid URL normalization rule %q: expected host=param,param", entry)
+		}
+
+		rule := Rule{Host: host}
+		for _, param := range strings.Split(list, ",") {
+			if param = strings.TrimSpace(param); param != "" {
+				rule.Params = append(rule.Params, param)
+			}
+		}
+		if len(rule.Params) == 0 {
+			return nil, fmt.Errorf("invalid URL normalization rule %q: no parameters", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
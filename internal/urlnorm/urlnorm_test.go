@@ -0,0 +1,44 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	rules, err := ParseRules("cdn.example.com=sig, exp ; *.media.example=auth_*")
+	if err != nil {
+		t.Fatalf("ParseRules returned error: %v", err)
+	}
+	n := New(append(DefaultRules, rules...))
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"no query", "https://cdn.example.com/ep1.vtt", "https://cdn.example.com/ep1.vtt"},
+		{"untouched query", "https://other.example/ep1.vtt?lang=en&b=1", "https://other.example/ep1.vtt?lang=en&b=1"},
+		{"default params", "https://other.example/ep1.vtt?lang=en&token=abc&Expires=123", "https://other.example/ep1.vtt?lang=en"},
+		{"s3 presigned", "https://bucket.s3.amazonaws.com/ep1.vtt?X-Amz-Signature=a&x-amz-date=b", "https://bucket.s3.amazonaws.com/ep1.vtt"},
+		{"host rule", "https://CDN.example.com/ep1.vtt?sig=a&id=7&exp=1", "https://CDN.example.com/ep1.vtt?id=7"},
+		{"host rule elsewhere", "https://other.example/ep1.vtt?sig=a", "https://other.example/ep1.vtt?sig=a"},
+		{"subdomain prefix rule", "https://eu.media.example/ep1.vtt?auth_key=1&auth_ts=2&q=%20x", "https://eu.media.example/ep1.vtt?q=%20x"},
+		{"fragment kept", "https://other.example/a.mkv?token=abc#track=3", "https://other.example/a.mkv#track=3"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.url); got != tt.want {
+			t.Fatalf("%s: got %q want %q", tt.name, got, tt.want)
+		}
+	}
+
+	var none *Normalizer
+	if got := none.Normalize("https://other.example/a.vtt?token=abc"); got != "https://other.example/a.vtt?token=abc" {
+		t.Fatalf("expected a nil normalizer to keep the URL, got %q", got)
+	}
+}
+
+func TestParseRules_RejectsMalformedRules(t *testing.T) {
+	for _, spec := range []string{"cdn.example.com", "=token", "cdn.example.com= , "} {
+		if _, err := ParseRules(spec); err == nil {
+			t.Fatalf("expected an error for %q", spec)
+		}
+	}
+}
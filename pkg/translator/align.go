@@ -0,0 +1,195 @@
+package translator
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// alignSkipPenalty is the cost of leaving a cue of either track unmatched.
+	alignSkipPenalty = 0.6
+	// alignMaxResidual drops matched pairs further than this from the fitted line before refitting;
+	// the limit halves on each refit.
+	alignMaxResidual = time.Second
+	alignMinMatches  = 3
+)
+
+// ErrAlignmentFailed is returned when the two tracks share too little rhythm to be aligned.
+var ErrAlignmentFailed = errors.New("not enough matching cues to align with the reference")
+
+// Alignment describes the linear mapping found between a subtitle and an in-sync reference:
+// reference time = source time * Scale + Offset.
+type Alignment struct {
+	Offset time.Duration `json:"offset"`
+	Scale  float64       `json:"scale"`
+	// DriftPerHour is how much the correction grows over one hour of source time on top of Offset.
+	DriftPerHour time.Duration `json:"drift_per_hour"`
+	Matched      int           `json:"matched"`
+	Cues         int           `json:"cues"`
+	Reference    int           `json:"reference_cues"`
+	// Adjustment is the timing adjustment the document was retimed with.
+	Adjustment TimingAdjustment `json:"-"`
+}
+
+// AlignTo retimes the document against reference, a subtitle of the same video already in sync in any language.
+// Cues are paired by rhythm (durations and the gaps between cue starts) with a dynamic-programming alignment, then
+// a line fitted through the pairs maps every timestamp.
+func (d *Document) AlignTo(reference *Document) (*Alignment, error) {
+	source := sortedCueTimes(d.Cues)
+	target := sortedCueTimes(reference.Cues)
+
+	pairs := alignCueRhythm(source, target)
+	scale, offset, pairs := fitAlignment(source, target, pairs)
+	if len(pairs) < alignMinMatches {
+		return nil, ErrAlignmentFailed
+	}
+
+	first, last := source[pairs[0][0]].Start, source[pairs[len(pairs)-1][0]].Start
+	if first == last {
+		last = first + time.Hour
+	}
+	mapped := func(t time.Duration) time.Duration {
+		return time.Duration(float64(t)*scale) + offset
+	}
+	adj := TimingAdjustment{
+		Operation: TimingScale,
+		Sync:      [2]SyncPoint{{From: first, To: mapped(first)}, {From: last, To: mapped(last)}},
+	}
+	if err := d.AdjustTiming(adj); err != nil {
+		return nil, err
+	}
+
+	return &Alignment{
+		Offset:       offset.Round(time.Millisecond),
+		Scale:        scale,
+		DriftPerHour: time.Duration((scale - 1) * float64(time.Hour)).Round(time.Millisecond),
+		Matched:      len(pairs),
+		Cues:         len(source),
+		Reference:    len(target),
+		Adjustment:   adj,
+	}, nil
+}
+
+type cueTime struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func sortedCueTimes(cues []*Cue) []cueTime {
+	times := make([]cueTime, 0, len(cues))
+	for _, cue := range cues {
+		times = append(times, cueTime{Start: cue.Start, End: cue.End})
+	}
+	sort.SliceStable(times, func(i, j int) bool { return times[i].Start < times[j].Start })
+	return times
+}
+
+// rhythmCost compares cue i of a with cue j of b by duration and by the gap to the following cue start.
+// Ratios are compared on a log scale so the cost does not depend on offset or mild drift.
+func rhythmCost(a []cueTime, i int, b []cueTime, j int) float64 {
+	cost := math.Abs(logRatio(a[i].End-a[i].Start, b[j].End-b[j].Start))
+	if i+1 < len(a) && j+1 < len(b) {
+		cost += math.Abs(logRatio(a[i+1].Start-a[i].Start, b[j+1].Start-b[j].Start))
+	}
+	if i > 0 && j > 0 {
+		cost += math.Abs(logRatio(a[i].Start-a[i-1].Start, b[j].Start-b[j-1].Start))
+	}
+	return cost
+}
+
+func logRatio(x, y time.Duration) float64 {
+	const floor = float64(50 * time.Millisecond)
+	return math.Log(math.Max(float64(x), floor) / math.Max(float64(y), floor))
+}
+
+// alignCueRhythm pairs source and reference cues in order, minimising rhythm cost plus a penalty per unmatched cue.
+func alignCueRhythm(source, target []cueTime) [][2]int {
+	n, m := len(source), len(target)
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	const (
+		stepMatch = iota
+		stepSkipSource
+		stepSkipTarget
+	)
+	cost := make([][]float64, n+1)
+	step := make([][]uint8, n+1)
+	for i := range cost {
+		cost[i] = make([]float64, m+1)
+		step[i] = make([]uint8, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		cost[i][0], step[i][0] = float64(i)*alignSkipPenalty, stepSkipSource
+	}
+	for j := 1; j <= m; j++ {
+		cost[0][j], step[0][j] = float64(j)*alignSkipPenalty, stepSkipTarget
+	}
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			best, move := cost[i-1][j-1]+rhythmCost(source, i-1, target, j-1), uint8(stepMatch)
+			if c := cost[i-1][j] + alignSkipPenalty; c < best {
+				best, move = c, stepSkipSource
+			}
+			if c := cost[i][j-1] + alignSkipPenalty; c < best {
+				best, move = c, stepSkipTarget
+			}
+			cost[i][j], step[i][j] = best, move
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := n, m; i > 0 || j > 0; {
+		switch step[i][j] {
+		case stepMatch:
+			// Only confident matches are used for fitting.
+			if rhythmCost(source, i-1, target, j-1) < 2*alignSkipPenalty {
+				pairs = append(pairs, [2]int{i - 1, j - 1})
+			}
+			i, j = i-1, j-1
+		case stepSkipSource:
+			i--
+		default:
+			j--
+		}
+	}
+	for l, r := 0, len(pairs)-1; l < r; l, r = l+1, r-1 {
+		pairs[l], pairs[r] = pairs[r], pairs[l]
+	}
+	return pairs
+}
+
+// fitAlignment fits reference start = scale*source start + offset by least squares, dropping outlying pairs.
+func fitAlignment(source, target []cueTime, pairs [][2]int) (float64, time.Duration, [][2]int) {
+	scale, offset := 1.0, time.Duration(0)
+	limit := alignMaxResidual
+	for round := 0; round < 3 && len(pairs) >= alignMinMatches; round++ {
+		var sx, sy, sxx, sxy float64
+		for _, p := range pairs {
+			x, y := source[p[0]].Start.Seconds(), target[p[1]].Start.Seconds()
+			sx, sy, sxx, sxy = sx+x, sy+y, sxx+x*x, sxy+x*y
+		}
+		count := float64(len(pairs))
+		if denom := count*sxx - sx*sx; denom > 0 {
+			scale = (count*sxy - sx*sy) / denom
+		} else {
+			scale = 1
+		}
+		offset = time.Duration((sy - scale*sx) / count * float64(time.Second))
+
+		kept := pairs[:0]
+		for _, p := range pairs {
+			predicted := time.Duration(float64(source[p[0]].Start)*scale) + offset
+			if residual := predicted - target[p[1]].Start; residual.Abs() <= limit {
+				kept = append(kept, p)
+			}
+		}
+		pairs = kept
+		limit /= 2
+	}
+	return scale, offset, pairs
+}
@@ -0,0 +1,79 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+func rhythmDocument(count int) *Document {
+	doc := &Document{Format: formatVTT}
+	seed := uint32(7)
+	next := func(min, spread time.Duration) time.Duration {
+		seed = seed*1664525 + 1013904223
+		return min + time.Duration(seed>>8)%spread
+	}
+
+	at := 3 * time.Second
+	for i := 0; i < count; i++ {
+		duration := next(800*time.Millisecond, 3*time.Second)
+		doc.Cues = append(doc.Cues, &Cue{Start: at, End: at + duration, Lines: []Line{ParseVTTLine("line")}})
+		at += duration + next(100*time.Millisecond, 4*time.Second)
+	}
+	return doc
+}
+
+func TestAlignTo_RecoversOffsetAndDrift(t *testing.T) {
+	reference := rhythmDocument(120)
+
+	// The source is shifted and runs slightly fast, misses a few cues and has one extra.
+	source := &Document{Format: formatVTT}
+	for i, cue := range reference.Cues {
+		if i%17 == 5 {
+			continue
+		}
+		start := time.Duration(float64(cue.Start-2500*time.Millisecond) / 1.001).Round(time.Millisecond)
+		end := time.Duration(float64(cue.End-2500*time.Millisecond) / 1.001).Round(time.Millisecond)
+		source.Cues = append(source.Cues, &Cue{Start: start, End: end, Lines: cue.Lines})
+	}
+	source.Cues = append(source.Cues[:40], append([]*Cue{{Start: source.Cues[39].End + 10*time.Millisecond, End: source.Cues[39].End + 60*time.Millisecond, Lines: []Line{ParseVTTLine("extra")}}}, source.Cues[40:]...)...)
+
+	result, err := source.AlignTo(reference)
+	if err != nil {
+		t.Fatalf("AlignTo returned error: %v", err)
+	}
+
+	if diff := result.Offset - 2500*time.Millisecond; diff.Abs() > 20*time.Millisecond {
+		t.Fatalf("expected offset near 2.5s, got %v", result.Offset)
+	}
+	if diff := result.DriftPerHour - 3600*time.Millisecond; diff.Abs() > 100*time.Millisecond {
+		t.Fatalf("expected drift near 3.6s per hour, got %v", result.DriftPerHour)
+	}
+	if result.Matched < 100 {
+		t.Fatalf("expected most cues to match, got %d", result.Matched)
+	}
+
+	for _, cue := range source.Cues {
+		if cue.PlainText() == "extra" {
+			continue
+		}
+		matched := false
+		for _, ref := range reference.Cues {
+			if diff := cue.Start - ref.Start; diff.Abs() <= 10*time.Millisecond {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			t.Fatalf("cue at %v did not land on a reference cue", cue.Start)
+		}
+	}
+}
+
+func TestAlignTo_FailsWithoutCommonRhythm(t *testing.T) {
+	reference := rhythmDocument(2)
+	source := rhythmDocument(2)
+
+	if _, err := source.AlignTo(reference); err != ErrAlignmentFailed {
+		t.Fatalf("expected ErrAlignmentFailed, got %v", err)
+	}
+}
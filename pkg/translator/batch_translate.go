@@ -15,6 +15,8 @@ const (
 	chunkSize          = 80
 	maxChunkChars      = 1800
 	maxSingleTextChars = 1400
+
+	maxConcurrentTransliterations = 10
 )
 
 var horizontalWhitespaceRe = regexp.MustCompile(`[ \t]+`)
@@ -240,3 +242,33 @@ func splitTextByLength(text string, maxLen int) []string {
 	}
 	return result
 }
+
+// BatchTransliterate transliterates texts to Latin script with a bounded number of concurrent requests.
+// Texts that could not be transliterated come back empty.
+func BatchTransliterate(texts []string, sourceLang string) []string {
+	result := make([]string, len(texts))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentTransliterations)
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			romanized, err := GoogleTransliterate(text, sourceLang)
+			if err != nil {
+				log.Printf("Transliteration failed: %v", err)
+				return
+			}
+			result[i] = romanized
+		}(i, text)
+	}
+	wg.Wait()
+
+	return result
+}
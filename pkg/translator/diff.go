@@ -0,0 +1,170 @@
+package translator
+
+import "time"
+
+// Cue change types reported by DiffCues.
+const (
+	CueAdded    = "added"
+	CueRemoved  = "removed"
+	CueModified = "modified"
+	CueRetimed  = "retimed"
+)
+
+// CueChange is one difference between two versions of a document. Indexes are 1-based; FromIndex is 0 for an
+// added cue and ToIndex is 0 for a removed one.
+type CueChange struct {
+	Type      string       `json:"type"`
+	FromIndex int          `json:"from_index,omitempty"`
+	ToIndex   int          `json:"to_index,omitempty"`
+	From      *CueSnapshot `json:"from,omitempty"`
+	To        *CueSnapshot `json:"to,omitempty"`
+}
+
+// CueSnapshot is a cue as it reads in one version.
+type CueSnapshot struct {
+	ID    string        `json:"id,omitempty"`
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	Text  string        `json:"text"`
+}
+
+// DiffCues lists the cue-level changes from one document to another. Cues are matched on their text; a matched
+// cue with different timing is retimed, and unmatched cues between two matches pair up as modified in order, the
+// rest being added or removed.
+func DiffCues(from, to *Document) []CueChange {
+	changes := []CueChange{}
+	for _, step := range alignCues(from.Cues, to.Cues) {
+		if step.kind != "" {
+			changes = append(changes, newCueChange(step.kind, from.Cues, to.Cues, step.from, step.to))
+		}
+	}
+	return changes
+}
+
+// cueStep pairs cue a[from] with b[to]; from or to is -1 for an added or removed cue, and kind is empty for a cue
+// that did not change.
+type cueStep struct {
+	kind     string
+	from, to int
+}
+
+// alignCues walks both cue lists in order, matching them as DiffCues describes.
+func alignCues(a, b []*Cue) []cueStep {
+	// Unchanged leading and trailing cues need no alignment
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix].Text() == b[prefix].Text() {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix].Text() == b[len(b)-1-suffix].Text() {
+		suffix++
+	}
+
+	pairs := make([][2]int, 0, len(a))
+	for i := 0; i < prefix; i++ {
+		pairs = append(pairs, [2]int{i, i})
+	}
+	for _, pair := range matchCueTexts(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		pairs = append(pairs, [2]int{pair[0] + prefix, pair[1] + prefix})
+	}
+	for i := suffix; i > 0; i-- {
+		pairs = append(pairs, [2]int{len(a) - i, len(b) - i})
+	}
+
+	var steps []cueStep
+	nextA, nextB := 0, 0
+	for _, pair := range append(pairs, [2]int{len(a), len(b)}) {
+		steps = append(steps, unmatchedCueSteps(nextA, pair[0], nextB, pair[1])...)
+		if pair[0] < len(a) && pair[1] < len(b) {
+			step := cueStep{from: pair[0], to: pair[1]}
+			if a[pair[0]].Start != b[pair[1]].Start || a[pair[0]].End != b[pair[1]].End {
+				step.kind = CueRetimed
+			}
+			steps = append(steps, step)
+		}
+		nextA, nextB = pair[0]+1, pair[1]+1
+	}
+	return steps
+}
+
+// matchCueTexts returns the index pairs of the longest common subsequence of cue texts.
+func matchCueTexts(a, b []*Cue) [][2]int {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	textsA := make([]string, len(a))
+	for i, cue := range a {
+		textsA[i] = cue.Text()
+	}
+	textsB := make([]string, len(b))
+	for j, cue := range b {
+		textsB[j] = cue.Text()
+	}
+
+	// lengths[i][j] is the LCS length of textsA[i:] and textsB[j:]
+	lengths := make([][]int32, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case textsA[i] == textsB[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case textsA[i] == textsB[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// unmatchedCueSteps pairs the cues a[fromA:toA] and b[fromB:toB] left between two matches.
+func unmatchedCueSteps(fromA, toA, fromB, toB int) []cueStep {
+	var steps []cueStep
+	for fromA < toA && fromB < toB {
+		steps = append(steps, cueStep{kind: CueModified, from: fromA, to: fromB})
+		fromA++
+		fromB++
+	}
+	for ; fromA < toA; fromA++ {
+		steps = append(steps, cueStep{kind: CueRemoved, from: fromA, to: -1})
+	}
+	for ; fromB < toB; fromB++ {
+		steps = append(steps, cueStep{kind: CueAdded, from: -1, to: fromB})
+	}
+	return steps
+}
+
+func newCueChange(changeType string, a, b []*Cue, i, j int) CueChange {
+	change := CueChange{Type: changeType}
+	if i >= 0 {
+		change.FromIndex = i + 1
+		change.From = newCueSnapshot(a[i])
+	}
+	if j >= 0 {
+		change.ToIndex = j + 1
+		change.To = newCueSnapshot(b[j])
+	}
+	return change
+}
+
+func newCueSnapshot(cue *Cue) *CueSnapshot {
+	return &CueSnapshot{ID: cue.ID, Start: cue.Start, End: cue.End, Text: cue.Text()}
+}
@@ -0,0 +1,94 @@
+package translator
+
+import "testing"
+
+func TestDiffCues_ReportsEachKindOfChange(t *testing.T) {
+	from := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Hello there",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"How are you?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Fine, thanks",
+		"",
+		"00:00:07.000 --> 00:00:08.000",
+		"See you",
+	)
+	to := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Hello there",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"How are you doing?",
+		"",
+		"00:00:05.500 --> 00:00:06.500",
+		"Fine, thanks",
+		"",
+		"00:00:07.000 --> 00:00:08.000",
+		"See you",
+		"",
+		"00:00:09.000 --> 00:00:10.000",
+		"Bye",
+	)
+
+	changes := DiffCues(from, to)
+
+	want := []struct {
+		kind     string
+		from, to int
+	}{
+		{CueModified, 2, 2},
+		{CueRetimed, 3, 3},
+		{CueAdded, 0, 5},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i, w := range want {
+		got := changes[i]
+		if got.Type != w.kind || got.FromIndex != w.from || got.ToIndex != w.to {
+			t.Fatalf("change %d: got %s %d->%d want %s %d->%d", i, got.Type, got.FromIndex, got.ToIndex, w.kind, w.from, w.to)
+		}
+	}
+	if changes[0].From.Text != "How are you?" || changes[0].To.Text != "How are you doing?" {
+		t.Fatalf("unexpected modified cue texts: %+v -> %+v", changes[0].From, changes[0].To)
+	}
+}
+
+func TestDiffCues_RemovedCues(t *testing.T) {
+	from := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"One",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Two",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Three",
+	)
+	to := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"One",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Three",
+	)
+
+	changes := DiffCues(from, to)
+	if len(changes) != 1 || changes[0].Type != CueRemoved || changes[0].FromIndex != 2 || changes[0].To != nil {
+		t.Fatalf("expected cue 2 to be removed, got %+v", changes)
+	}
+	if got := DiffCues(to, to); len(got) != 0 {
+		t.Fatalf("expected no changes between identical documents, got %+v", got)
+	}
+}
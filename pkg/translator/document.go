@@ -0,0 +1,233 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subtitle document formats.
+const (
+	formatVTT = "vtt"
+	formatASS = "ass"
+)
+
+var timestampPartsRe = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})(?:[.,](\d{1,3}))?$`)
+
+// Document is a parsed subtitle file independent of its on-disk format.
+type Document struct {
+	Format string
+	// Header holds the WEBVTT line with its metadata (VTT) or every section before [Events] (ASS).
+	Header []string
+	// Styles holds raw STYLE and REGION blocks of a VTT file.
+	Styles []string
+	// EventFormat lists the ASS [Events] Format fields.
+	EventFormat []string
+	Cues        []*Cue
+	// Comments holds NOTE blocks that follow the last cue.
+	Comments []string
+	// Skipped holds the cue blocks (VTT) or Dialogue lines (ASS) whose timing could not be parsed. They are not
+	// written back, so content with skipped cues must not be saved over its source.
+	Skipped []string
+}
+
+// Cue is a single timed subtitle entry.
+type Cue struct {
+	ID       string
+	Start    time.Duration
+	End      time.Duration
+	Settings string
+	Lines    []Line
+	// Comments holds NOTE blocks (VTT) or Comment events (ASS) directly preceding the cue.
+	Comments []string
+
+	// ASS-only fields kept for round trips and style-based processing.
+	Style  string
+	Actor  string
+	Fields map[string]string
+}
+
+// Line is one text line of a cue, split into plain text and markup runs.
+type Line []Run
+
+// Run is a span of cue text; tag runs carry markup such as <i>, <v Bob>, <00:01.500> or {\an8}.
+type Run struct {
+	Text string
+	Tag  bool
+}
+
+// String returns the line with its markup.
+func (l Line) String() string {
+	var b strings.Builder
+	for _, r := range l {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+// Plain returns the line without markup.
+func (l Line) Plain() string {
+	var b strings.Builder
+	for _, r := range l {
+		if !r.Tag {
+			b.WriteString(r.Text)
+		}
+	}
+	return b.String()
+}
+
+// ParseVTTLine splits a VTT text line into text and tag runs.
+func ParseVTTLine(text string) Line {
+	return splitRuns(text, vttTagRe)
+}
+
+// ParseASSLine splits an ASS text line into text and override-block runs.
+func ParseASSLine(text string) Line {
+	return splitRuns(text, assBraceRe)
+}
+
+func splitRuns(text string, tagRe *regexp.Regexp) Line {
+	var line Line
+	pos := 0
+	for _, loc := range tagRe.FindAllStringIndex(text, -1) {
+		if loc[0] > pos {
+			line = append(line, Run{Text: text[pos:loc[0]]})
+		}
+		line = append(line, Run{Text: text[loc[0]:loc[1]], Tag: true})
+		pos = loc[1]
+	}
+	if pos < len(text) {
+		line = append(line, Run{Text: text[pos:]})
+	}
+	return line
+}
+
+// Text returns the cue text with markup, lines separated by "\n".
+func (c *Cue) Text() string {
+	lines := make([]string, 0, len(c.Lines))
+	for _, l := range c.Lines {
+		lines = append(lines, l.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlainText returns the cue text without markup, lines separated by "\n".
+func (c *Cue) PlainText() string {
+	lines := make([]string, 0, len(c.Lines))
+	for _, l := range c.Lines {
+		lines = append(lines, l.Plain())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SetText replaces the cue text; "\n" separates lines and VTT tags become tag runs.
+func (c *Cue) SetText(text string) {
+	c.SetLines(strings.Split(text, "\n"))
+}
+
+// SetLines replaces the cue text with the given lines, skipping empty ones.
+func (c *Cue) SetLines(lines []string) {
+	c.Lines = c.Lines[:0:0]
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		c.Lines = append(c.Lines, ParseVTTLine(l))
+	}
+}
+
+// Duration returns End - Start.
+func (c *Cue) Duration() time.Duration {
+	return c.End - c.Start
+}
+
+// Clone returns a deep copy of the cue.
+func (c *Cue) Clone() *Cue {
+	clone := *c
+	clone.Lines = make([]Line, len(c.Lines))
+	for i, l := range c.Lines {
+		clone.Lines[i] = append(Line(nil), l...)
+	}
+	clone.Comments = append([]string(nil), c.Comments...)
+	if c.Fields != nil {
+		clone.Fields = make(map[string]string, len(c.Fields))
+		for k, v := range c.Fields {
+			clone.Fields[k] = v
+		}
+	}
+	return &clone
+}
+
+// Clone returns a deep copy of the document.
+func (d *Document) Clone() *Document {
+	clone := *d
+	clone.Header = append([]string(nil), d.Header...)
+	clone.Styles = append([]string(nil), d.Styles...)
+	clone.EventFormat = append([]string(nil), d.EventFormat...)
+	clone.Comments = append([]string(nil), d.Comments...)
+	clone.Cues = make([]*Cue, len(d.Cues))
+	for i, c := range d.Cues {
+		clone.Cues[i] = c.Clone()
+	}
+	return &clone
+}
+
+// ParseDocument parses content in the given format ("vtt" or "ass").
+func ParseDocument(content, format string) (*Document, error) {
+	if strings.EqualFold(format, formatASS) {
+		return ParseASS(content)
+	}
+	return ParseVTT(content)
+}
+
+// ParseTimestamp parses VTT (HH:MM:SS.mmm, MM:SS.mmm), SRT (HH:MM:SS,mmm) and ASS (H:MM:SS.cc) timestamps.
+func ParseTimestamp(s string) (time.Duration, error) {
+	m := timestampPartsRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	if min > 59 || sec > 59 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	// Fractions are right-padded: ".5" is 500ms and ASS ".25" is 250ms.
+	frac := m[4]
+	for len(frac) < 3 {
+		frac += "0"
+	}
+	ms, _ := strconv.Atoi(frac)
+
+	return time.Duration(h)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second + time.Duration(ms)*time.Millisecond, nil
+}
+
+// FormatVTTTimestamp formats a duration as HH:MM:SS.mmm.
+func FormatVTTTimestamp(d time.Duration) string {
+	return formatVTTTimestamp(d)
+}
+
+// FormatASSTimestamp formats a duration as H:MM:SS.cc.
+func FormatASSTimestamp(d time.Duration) string {
+	return formatASSTimestamp(d)
+}
+
+func formatVTTTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+func formatASSTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
@@ -0,0 +1,213 @@
+package translator
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+var defaultASSEventFormat = []string{"Layer", "Start", "End", "Style", "Name", "MarginL", "MarginR", "MarginV", "Effect", "Text"}
+
+// ParseASS parses an ASS/SSA script into a Document. Sections before [Events] are kept verbatim in Header.
+// Dialogue lines with too few fields or unparsable timings are skipped rather than failing the document and
+// returned in Skipped; Lint reports them as malformed timestamps.
+func ParseASS(content string) (*Document, error) {
+	content = strings.ReplaceAll(strings.TrimPrefix(content, utf8BOM), "\r\n", "\n")
+	doc := &Document{Format: formatASS}
+
+	inEvents := false
+	var pending []string
+	for _, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			if inEvents {
+				continue
+			}
+		}
+		if !inEvents {
+			doc.Header = append(doc.Header, strings.TrimRight(raw, " \t"))
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "Format":
+			doc.EventFormat = nil
+			for _, field := range strings.Split(value, ",") {
+				doc.EventFormat = append(doc.EventFormat, strings.TrimSpace(field))
+			}
+		case "Dialogue":
+			cue, err := parseASSDialogue(value, doc.eventFormat())
+			if err != nil {
+				log.Printf("Skipping dialogue: %v", err)
+				doc.Skipped = append(doc.Skipped, line)
+				continue
+			}
+			cue.ID = strconv.Itoa(len(doc.Cues) + 1)
+			cue.Comments = pending
+			pending = nil
+			doc.Cues = append(doc.Cues, cue)
+		case "Comment":
+			pending = append(pending, line)
+		}
+	}
+	doc.Comments = pending
+
+	// Drop blank lines trailing the last header section.
+	for len(doc.Header) > 0 && doc.Header[len(doc.Header)-1] == "" {
+		doc.Header = doc.Header[:len(doc.Header)-1]
+	}
+
+	return doc, nil
+}
+
+// FormatASS serializes a Document as an ASS script. Documents parsed from VTT get a minimal script header.
+func FormatASS(doc *Document) string {
+	header := doc.Header
+	if doc.Format != formatASS || len(header) == 0 {
+		header = []string{
+			"[Script Info]",
+			"ScriptType: v4.00+",
+			"",
+			"[V4+ Styles]",
+			"Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding",
+			"Style: Default,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1",
+		}
+	}
+
+	format := doc.eventFormat()
+	lines := append(append([]string{}, header...), "", "[Events]", "Format: "+strings.Join(format, ", "))
+	for _, cue := range doc.Cues {
+		lines = append(lines, cue.Comments...)
+
+		fields := make([]string, len(format))
+		for i, name := range format {
+			fields[i] = cue.assField(name, doc.Format)
+		}
+		lines = append(lines, "Dialogue: "+strings.Join(fields, ","))
+	}
+	lines = append(lines, doc.Comments...)
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (d *Document) eventFormat() []string {
+	if len(d.EventFormat) == 0 {
+		return defaultASSEventFormat
+	}
+	return d.EventFormat
+}
+
+func parseASSDialogue(value string, format []string) (*Cue, error) {
+	parts := strings.SplitN(value, ",", len(format))
+	if len(parts) < len(format) {
+		return nil, fmt.Errorf("invalid dialogue line %q", value)
+	}
+
+	cue := &Cue{Fields: make(map[string]string)}
+	for i, name := range format {
+		field := parts[i]
+		if name != "Text" {
+			field = strings.TrimSpace(field)
+		}
+
+		switch name {
+		case "Start", "End":
+			ts, err := ParseTimestamp(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dialogue timing %q: %w", value, err)
+			}
+			if name == "Start" {
+				cue.Start = ts
+			} else {
+				cue.End = ts
+			}
+		case "Style":
+			cue.Style = field
+		case "Name":
+			cue.Actor = field
+		case "Text":
+			field = strings.ReplaceAll(field, "\\n", "\\N")
+			for _, line := range strings.Split(field, "\\N") {
+				cue.Lines = append(cue.Lines, ParseASSLine(line))
+			}
+		default:
+			cue.Fields[name] = field
+		}
+	}
+
+	return cue, nil
+}
+
+// assField returns the value written for one ASS event field. Cue text from other formats loses its markup.
+func (c *Cue) assField(name, format string) string {
+	switch name {
+	case "Start":
+		return formatASSTimestamp(c.Start)
+	case "End":
+		return formatASSTimestamp(c.End)
+	case "Style":
+		if c.Style == "" {
+			return "Default"
+		}
+		return c.Style
+	case "Name":
+		return c.Actor
+	case "Text":
+		lines := make([]string, 0, len(c.Lines))
+		for _, line := range c.Lines {
+			if format == formatASS {
+				lines = append(lines, line.String())
+				continue
+			}
+			lines = append(lines, line.Plain())
+		}
+		return strings.Join(lines, "\\N")
+	}
+
+	if v, ok := c.Fields[name]; ok {
+		return v
+	}
+	switch name {
+	case "Layer", "MarginL", "MarginR", "MarginV":
+		return "0"
+	}
+	return ""
+}
+
+// ToVTT converts a document to a WebVTT document with plain cue text, numbering cues from 1.
+// Empty cues are dropped; ASS style and actor are kept on each cue, and karaoke timing is recorded in Fields.
+func (d *Document) ToVTT() *Document {
+	if d.Format == formatVTT {
+		return d.Clone()
+	}
+
+	out := &Document{Format: formatVTT}
+	for _, src := range d.Cues {
+		text := strings.TrimSpace(src.PlainText())
+		if text == "" {
+			continue
+		}
+
+		cue := src.Clone()
+		cue.ID = strconv.Itoa(len(out.Cues) + 1)
+		cue.Comments = nil
+		if hasKaraokeTiming(src) {
+			if cue.Fields == nil {
+				cue.Fields = make(map[string]string)
+			}
+			cue.Fields[karaokeField] = "1"
+		}
+		cue.SetText(text)
+		out.Cues = append(out.Cues, cue)
+	}
+	return out
+}
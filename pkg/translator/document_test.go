@@ -0,0 +1,162 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp_AcceptsVTTSRTAndASS(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"00:00:17.976", 17976 * time.Millisecond},
+		{"01:17.976", 77976 * time.Millisecond},
+		{"01:02:03,004", time.Hour + 2*time.Minute + 3004*time.Millisecond},
+		{"0:00:01.25", 1250 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if err != nil {
+			t.Fatalf("ParseTimestamp(%q) returned error: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseTimestamp(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := ParseTimestamp("00:61:00.000"); err == nil {
+		t.Fatalf("expected error for out-of-range minutes")
+	}
+}
+
+func TestParseVTT_RoundTripsHeaderNotesStylesAndMarkup(t *testing.T) {
+	content := strings.Join([]string{
+		"WEBVTT",
+		"X-TIMESTAMP-MAP=LOCAL:00:00:00.000,MPEGTS:900000",
+		"",
+		"STYLE",
+		"::cue(.original) { color: gray }",
+		"",
+		"NOTE translated by hand",
+		"",
+		"intro",
+		"00:00:01.000 --> 00:00:02.500 line:20% align:start",
+		"<v Bob><i>Hello</i> there</v>",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Second",
+		"",
+	}, "\n")
+
+	doc, err := ParseVTT(content)
+	if err != nil {
+		t.Fatalf("ParseVTT returned error: %v", err)
+	}
+
+	if len(doc.Cues) != 2 || len(doc.Styles) != 1 {
+		t.Fatalf("expected 2 cues and 1 style block, got %d cues and %d styles", len(doc.Cues), len(doc.Styles))
+	}
+
+	cue := doc.Cues[0]
+	if cue.ID != "intro" || cue.Settings != "line:20% align:start" || cue.Start != time.Second || cue.End != 2500*time.Millisecond {
+		t.Fatalf("unexpected first cue: %#v", cue)
+	}
+	if len(cue.Comments) != 1 || cue.Comments[0] != "NOTE translated by hand" {
+		t.Fatalf("expected NOTE attached to first cue, got %#v", cue.Comments)
+	}
+	if cue.PlainText() != "Hello there" {
+		t.Fatalf("unexpected plain text: %q", cue.PlainText())
+	}
+
+	if got := FormatVTT(doc); got != content {
+		t.Fatalf("round trip mismatch:\n got %q\nwant %q", got, content)
+	}
+}
+
+func TestParseASS_RoundTripsDialogueAndOverrides(t *testing.T) {
+	content := strings.Join([]string{
+		"[Script Info]",
+		"ScriptType: v4.00+",
+		"",
+		"[V4+ Styles]",
+		"Format: Name, Fontname",
+		"Style: OP,Arial",
+		"",
+		"[Events]",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text",
+		"Comment: 0,0:00:00.00,0:00:01.00,OP,,0,0,0,,timing note",
+		"Dialogue: 0,0:00:01.50,0:00:03.00,OP,Singer,0,0,0,,{\\an8}Hello,\\Nworld",
+		"",
+	}, "\n")
+
+	doc, err := ParseASS(content)
+	if err != nil {
+		t.Fatalf("ParseASS returned error: %v", err)
+	}
+
+	if len(doc.Cues) != 1 {
+		t.Fatalf("expected 1 cue, got %d", len(doc.Cues))
+	}
+
+	cue := doc.Cues[0]
+	if cue.Style != "OP" || cue.Actor != "Singer" || cue.Start != 1500*time.Millisecond || cue.End != 3*time.Second {
+		t.Fatalf("unexpected cue: %#v", cue)
+	}
+	if cue.PlainText() != "Hello,\nworld" || !cue.Lines[0][0].Tag {
+		t.Fatalf("unexpected cue text runs: %#v", cue.Lines)
+	}
+
+	if got := FormatASS(doc); got != content {
+		t.Fatalf("round trip mismatch:\n got %q\nwant %q", got, content)
+	}
+
+	vtt := FormatVTT(doc.ToVTT())
+	want := "WEBVTT\n\n1\n00:00:01.500 --> 00:00:03.000\nHello,\nworld\n"
+	if vtt != want {
+		t.Fatalf("unexpected VTT conversion: %q", vtt)
+	}
+}
+
+func TestParse_SkipsMalformedCues(t *testing.T) {
+	doc, err := ParseVTT(strings.Join([]string{
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"First",
+		"",
+		"00:99:00.000 --> 00:99:01.000",
+		"Broken",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Last",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseVTT returned error: %v", err)
+	}
+	if len(doc.Cues) != 2 || doc.Cues[0].Text() != "First" || doc.Cues[1].Text() != "Last" {
+		t.Fatalf("expected the malformed cue to be skipped, got %+v", doc.Cues)
+	}
+	if len(doc.Skipped) != 1 || doc.Skipped[0] != "00:99:00.000 --> 00:99:01.000\nBroken" {
+		t.Fatalf("expected the malformed cue to be returned as skipped, got %q", doc.Skipped)
+	}
+
+	doc, err = ParseASS(strings.Join([]string{
+		"[Events]",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text",
+		"Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,First",
+		"Dialogue: 0,0:00:02.00",
+		"Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,Last",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseASS returned error: %v", err)
+	}
+	if len(doc.Cues) != 2 || doc.Cues[1].Text() != "Last" || doc.Cues[1].ID != "2" {
+		t.Fatalf("expected the short dialogue line to be skipped, got %+v", doc.Cues)
+	}
+	if len(doc.Skipped) != 1 || doc.Skipped[0] != "Dialogue: 0,0:00:02.00" {
+		t.Fatalf("expected the short dialogue line to be returned as skipped, got %q", doc.Skipped)
+	}
+}
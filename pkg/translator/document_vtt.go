@@ -0,0 +1,139 @@
+package translator
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ParseVTT parses WebVTT (or SRT-like) content into a Document.
+// Blocks that are neither cues, NOTE, STYLE nor REGION blocks are kept verbatim as comments. Cues whose timing
+// cannot be parsed are skipped rather than failing the document and returned in Skipped; Lint reports them as
+// malformed timestamps.
+func ParseVTT(content string) (*Document, error) {
+	content = strings.ReplaceAll(strings.TrimPrefix(content, utf8BOM), "\r\n", "\n")
+	doc := &Document{Format: formatVTT}
+
+	var pending []string
+	for i, block := range splitVTTBlocks(content) {
+		first := strings.TrimSpace(block[0])
+
+		if i == 0 && strings.HasPrefix(first, "WEBVTT") {
+			doc.Header = block
+			continue
+		}
+
+		switch {
+		case first == "NOTE" || strings.HasPrefix(first, "NOTE ") || strings.HasPrefix(first, "NOTE\t"):
+			pending = append(pending, strings.Join(block, "\n"))
+			continue
+		case (first == "STYLE" || first == "REGION") && len(doc.Cues) == 0:
+			doc.Styles = append(doc.Styles, strings.Join(block, "\n"))
+			continue
+		}
+
+		cue, ok, err := parseVTTCue(block)
+		if err != nil {
+			log.Printf("Skipping cue: %v", err)
+			doc.Skipped = append(doc.Skipped, strings.Join(block, "\n"))
+			continue
+		}
+		if !ok {
+			pending = append(pending, strings.Join(block, "\n"))
+			continue
+		}
+
+		cue.Comments = pending
+		pending = nil
+		doc.Cues = append(doc.Cues, cue)
+	}
+	doc.Comments = pending
+
+	return doc, nil
+}
+
+// FormatVTT serializes a Document as WebVTT.
+func FormatVTT(doc *Document) string {
+	header := doc.Header
+	if doc.Format != formatVTT || len(header) == 0 {
+		header = []string{"WEBVTT"}
+	}
+
+	blocks := []string{strings.Join(header, "\n")}
+	blocks = append(blocks, doc.Styles...)
+	for _, cue := range doc.Cues {
+		blocks = append(blocks, cue.Comments...)
+
+		lines := make([]string, 0, len(cue.Lines)+2)
+		if cue.ID != "" {
+			lines = append(lines, cue.ID)
+		}
+		timing := formatVTTTimestamp(cue.Start) + " --> " + formatVTTTimestamp(cue.End)
+		if cue.Settings != "" {
+			timing += " " + cue.Settings
+		}
+		lines = append(lines, timing)
+		for _, line := range cue.Lines {
+			lines = append(lines, line.String())
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	blocks = append(blocks, doc.Comments...)
+
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func splitVTTBlocks(content string) [][]string {
+	var blocks [][]string
+	var current []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, strings.TrimRight(line, " \t"))
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+// parseVTTCue reads one cue block; ok is false when the block has no timing line.
+func parseVTTCue(block []string) (*Cue, bool, error) {
+	timingIdx := -1
+	var match []string
+	for i, line := range block {
+		if match = vttTimestampRe.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			timingIdx = i
+			break
+		}
+	}
+	if timingIdx == -1 {
+		return nil, false, nil
+	}
+
+	start, err := ParseTimestamp(match[1])
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid cue timing %q: %w", block[timingIdx], err)
+	}
+	end, err := ParseTimestamp(match[2])
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid cue timing %q: %w", block[timingIdx], err)
+	}
+
+	cue := &Cue{
+		ID:       strings.TrimSpace(strings.Join(block[:timingIdx], " ")),
+		Start:    start,
+		End:      end,
+		Settings: strings.TrimSpace(match[3]),
+	}
+	for _, line := range block[timingIdx+1:] {
+		cue.Lines = append(cue.Lines, ParseVTTLine(line))
+	}
+
+	return cue, true, nil
+}
@@ -0,0 +1,162 @@
+package translator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cueIDPrefix marks a cue reference as a cue id rather than a 1-based index.
+const cueIDPrefix = "id:"
+
+var (
+	ErrCueNotFound = errors.New("cue not found")
+	ErrInvalidCue  = errors.New("invalid cue")
+)
+
+// CueView is a cue as shown to editors, with WebVTT timestamps.
+type CueView struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Settings string `json:"settings,omitempty"`
+	Text     string `json:"text"`
+}
+
+// CueEdit changes the fields of a cue that are set.
+type CueEdit struct {
+	ID       *string
+	Start    *time.Duration
+	End      *time.Duration
+	Settings *string
+	Text     *string
+}
+
+// CueViews lists the cues of a document in order.
+func (d *Document) CueViews() []CueView {
+	views := make([]CueView, 0, len(d.Cues))
+	for i, cue := range d.Cues {
+		views = append(views, CueView{
+			Index:    i + 1,
+			ID:       cue.ID,
+			Start:    formatVTTTimestamp(cue.Start),
+			End:      formatVTTTimestamp(cue.End),
+			Settings: cue.Settings,
+			Text:     cue.Text(),
+		})
+	}
+	return views
+}
+
+// FindCue resolves a cue reference: a 1-based index, or a cue id prefixed with "id:". It returns the 0-based
+// position of the cue.
+func (d *Document) FindCue(ref string) (int, error) {
+	if id, ok := strings.CutPrefix(ref, cueIDPrefix); ok {
+		for i, cue := range d.Cues {
+			if cue.ID == id {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: no cue with id %q", ErrCueNotFound, id)
+	}
+
+	index, err := strconv.Atoi(ref)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is neither an index nor an id:<cue id> reference", ErrCueNotFound, ref)
+	}
+	if index < 1 || index > len(d.Cues) {
+		return 0, fmt.Errorf("%w: index %d is out of range 1-%d", ErrCueNotFound, index, len(d.Cues))
+	}
+	return index - 1, nil
+}
+
+// EditCue applies edit to the cue at position i. Cues stay ordered by start time, so a retimed cue may move.
+func (d *Document) EditCue(i int, edit CueEdit) error {
+	cue := d.Cues[i].Clone()
+	edit.apply(cue)
+	if err := d.validateCue(cue, i); err != nil {
+		return err
+	}
+	d.Cues[i] = cue
+	d.sortCues()
+	return nil
+}
+
+// InsertCue adds a cue built from edit, which needs start, end and text, at its place by start time. It returns
+// the 0-based position of the new cue.
+func (d *Document) InsertCue(edit CueEdit) (int, error) {
+	if edit.Start == nil || edit.End == nil || edit.Text == nil {
+		return 0, fmt.Errorf("%w: start, end and text are required", ErrInvalidCue)
+	}
+	cue := &Cue{}
+	edit.apply(cue)
+	if err := d.validateCue(cue, -1); err != nil {
+		return 0, err
+	}
+
+	d.Cues = append(d.Cues, cue)
+	d.sortCues()
+	for i := range d.Cues {
+		if d.Cues[i] == cue {
+			return i, nil
+		}
+	}
+	return len(d.Cues) - 1, nil
+}
+
+// DeleteCue removes the cue at position i.
+func (d *Document) DeleteCue(i int) {
+	d.Cues = append(d.Cues[:i], d.Cues[i+1:]...)
+}
+
+func (e CueEdit) apply(cue *Cue) {
+	if e.ID != nil {
+		cue.ID = strings.TrimSpace(*e.ID)
+	}
+	if e.Start != nil {
+		cue.Start = *e.Start
+	}
+	if e.End != nil {
+		cue.End = *e.End
+	}
+	if e.Settings != nil {
+		cue.Settings = strings.TrimSpace(*e.Settings)
+	}
+	if e.Text != nil {
+		cue.SetText(strings.ReplaceAll(*e.Text, "\r\n", "\n"))
+	}
+}
+
+// validateCue checks a cue about to be stored at position i (-1 for a new cue): it must have text, a positive
+// duration, and an id that fits on its line. A new or changed id must not be used by another cue.
+func (d *Document) validateCue(cue *Cue, i int) error {
+	switch {
+	case cue.Start < 0:
+		return fmt.Errorf("%w: start must not be negative", ErrInvalidCue)
+	case cue.End <= cue.Start:
+		return fmt.Errorf("%w: end must be after start", ErrInvalidCue)
+	case strings.TrimSpace(cue.PlainText()) == "":
+		return fmt.Errorf("%w: text must not be empty", ErrInvalidCue)
+	case strings.ContainsAny(cue.ID, "\r\n") || strings.Contains(cue.ID, "-->"):
+		return fmt.Errorf("%w: id must be one line without \"-->\"", ErrInvalidCue)
+	case strings.ContainsAny(cue.Settings, "\r\n"):
+		return fmt.Errorf("%w: settings must be one line", ErrInvalidCue)
+	}
+	if cue.ID == "" || (i >= 0 && d.Cues[i].ID == cue.ID) {
+		return nil
+	}
+	for j, other := range d.Cues {
+		if j != i && other.ID == cue.ID {
+			return fmt.Errorf("%w: id %q is already used by cue %d", ErrInvalidCue, cue.ID, j+1)
+		}
+	}
+	return nil
+}
+
+func (d *Document) sortCues() {
+	sort.SliceStable(d.Cues, func(i, j int) bool { return d.Cues[i].Start < d.Cues[j].Start })
+}
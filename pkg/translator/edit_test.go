@@ -0,0 +1,90 @@
+package translator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func editTestDoc(t *testing.T) *Document {
+	return mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"intro",
+		"00:00:01.000 --> 00:00:02.000",
+		"Halo!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Apa kabar?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Sampai jumpa",
+	)
+}
+
+func TestFindCue_ByIndexAndID(t *testing.T) {
+	doc := editTestDoc(t)
+
+	if i, err := doc.FindCue("2"); err != nil || i != 1 {
+		t.Fatalf("expected index 2 to resolve to position 1, got %d %v", i, err)
+	}
+	if i, err := doc.FindCue("id:intro"); err != nil || i != 0 {
+		t.Fatalf("expected id:intro to resolve to position 0, got %d %v", i, err)
+	}
+	for _, ref := range []string{"0", "4", "id:outro", "intro"} {
+		if _, err := doc.FindCue(ref); !errors.Is(err, ErrCueNotFound) {
+			t.Fatalf("%q: expected ErrCueNotFound, got %v", ref, err)
+		}
+	}
+}
+
+func TestEditCue_RetimeKeepsCuesOrdered(t *testing.T) {
+	doc := editTestDoc(t)
+
+	start, end := 7*time.Second, 8*time.Second
+	if err := doc.EditCue(1, CueEdit{Start: &start, End: &end}); err != nil {
+		t.Fatalf("EditCue returned error: %v", err)
+	}
+
+	views := doc.CueViews()
+	if views[2].Text != "Apa kabar?" || views[2].Start != "00:00:07.000" || views[2].Index != 3 {
+		t.Fatalf("expected the retimed cue to move last, got %+v", views)
+	}
+}
+
+func TestEditCue_RejectsInvalidCues(t *testing.T) {
+	doc := editTestDoc(t)
+
+	end := 500 * time.Millisecond
+	blank := "  "
+	used := "intro"
+	for name, edit := range map[string]CueEdit{
+		"end before start": {End: &end},
+		"empty text":       {Text: &blank},
+		"duplicate id":     {ID: &used},
+	} {
+		if err := doc.EditCue(2, edit); !errors.Is(err, ErrInvalidCue) {
+			t.Fatalf("%s: expected ErrInvalidCue, got %v", name, err)
+		}
+	}
+	if doc.Cues[2].Text() != "Sampai jumpa" || doc.Cues[2].End != 6*time.Second {
+		t.Fatalf("a rejected edit changed the cue: %+v", doc.Cues[2])
+	}
+}
+
+func TestInsertCue_PlacedByStartTime(t *testing.T) {
+	doc := editTestDoc(t)
+
+	start, end, text := 2500*time.Millisecond, 2900*time.Millisecond, "Eh?"
+	i, err := doc.InsertCue(CueEdit{Start: &start, End: &end, Text: &text})
+	if err != nil {
+		t.Fatalf("InsertCue returned error: %v", err)
+	}
+	if i != 1 || len(doc.Cues) != 4 || doc.Cues[1].Text() != "Eh?" {
+		t.Fatalf("expected the new cue at position 1, got %d: %+v", i, doc.CueViews())
+	}
+
+	if _, err := doc.InsertCue(CueEdit{Text: &text}); !errors.Is(err, ErrInvalidCue) {
+		t.Fatalf("expected ErrInvalidCue without timing, got %v", err)
+	}
+}
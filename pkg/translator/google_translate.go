@@ -13,6 +13,9 @@ import (
 
 const googleTranslateURL = "https://translate.googleapis.com/translate_a/single"
 
+// Engine names the translation engine behind BatchTranslate, so stored translations can tell which one made them.
+const Engine = "google-gtx"
+
 var (
 	// Reuse HTTP client with connection pooling
 	httpClient     *http.Client
@@ -90,10 +93,71 @@ func GoogleTranslate(text, targetLang, sourceLang string) (string, error) {
 	}
 
 	// Apply Indonesian post-processing for subtitle naturalness.
-	if targetLang == "id" {
+	if Register(targetLang) == RegisterInformal {
 		translated = FormalizeToInformal(translated)
 		translated = EnhanceIndonesianSubtitle(translated)
 	}
 
 	return translated, nil
 }
+
+// GoogleTransliterate returns text written in Latin script, such as romaji for Japanese, using the
+// transliteration part of the Google Translate free API.
+func GoogleTransliterate(text, sourceLang string) (string, error) {
+	if text == "" {
+		return text, nil
+	}
+
+	params := url.Values{}
+	params.Set("client", "gtx")
+	params.Set("sl", sourceLang)
+	params.Set("tl", "en")
+	params.Set("dt", "rm")
+	params.Set("q", text)
+
+	reqURL := fmt.Sprintf("%s?%s", googleTranslateURL, params.Encode())
+
+	resp, err := getHTTPClient().Get(reqURL)
+	if err != nil {
+		return "", fmt.Errorf("google transliterate request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google transliterate returned status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return parseTransliteration(body)
+}
+
+// parseTransliteration reads the source transliteration, the fourth field of the entries in the first
+// element of a dt=rm response.
+func parseTransliteration(body []byte) (string, error) {
+	var result []interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("empty transliteration result")
+	}
+
+	entries, ok := result[0].([]interface{})
+	if !ok {
+		return "", fmt.Errorf("invalid transliteration format")
+	}
+
+	var romanized string
+	for _, entry := range entries {
+		if fields, ok := entry.([]interface{}); ok && len(fields) > 3 {
+			if text, ok := fields[3].(string); ok {
+				romanized += text
+			}
+		}
+	}
+	return romanized, nil
+}
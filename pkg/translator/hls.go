@@ -0,0 +1,439 @@
+package translator
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	hlsHeaderTag     = "#EXTM3U"
+	hlsMediaTag      = "#EXT-X-MEDIA:"
+	hlsStreamInfTag  = "#EXT-X-STREAM-INF:"
+	hlsIFrameInfTag  = "#EXT-X-I-FRAME-STREAM-INF:"
+	hlsTimestampMap  = "X-TIMESTAMP-MAP"
+	hlsDefaultGroup  = "subs"
+	hlsSegmentLimit  = 2000
+	hlsSubtitlesType = "SUBTITLES"
+	utf8BOM          = "\ufeff"
+)
+
+var hlsAttributeRe = regexp.MustCompile(`([A-Z0-9-]+)=("[^"]*"|[^",]*)`)
+
+// HLSRendition describes an EXT-X-MEDIA entry of a master playlist.
+type HLSRendition struct {
+	Type       string `json:"type"`
+	GroupID    string `json:"group_id"`
+	Language   string `json:"language"`
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	Default    bool   `json:"default"`
+	Autoselect bool   `json:"autoselect"`
+}
+
+// HLSMasterPlaylist is a parsed master playlist whose relative URIs are resolved against its URL.
+type HLSMasterPlaylist struct {
+	URL        string
+	Lines      []string
+	Renditions []HLSRendition
+}
+
+type hlsAttribute struct {
+	key   string
+	value string
+}
+
+// IsHLSPlaylist reports whether content looks like an m3u8 playlist.
+func IsHLSPlaylist(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(content, utf8BOM)), hlsHeaderTag)
+}
+
+// ParseHLSMasterPlaylist parses a master playlist and resolves every URI against baseURL.
+func ParseHLSMasterPlaylist(content, baseURL string) (*HLSMasterPlaylist, error) {
+	if !IsHLSPlaylist(content) {
+		return nil, fmt.Errorf("content is not an HLS playlist")
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid playlist URL: %w", err)
+	}
+
+	raw := strings.Split(strings.ReplaceAll(strings.TrimPrefix(content, utf8BOM), "\r\n", "\n"), "\n")
+	playlist := &HLSMasterPlaylist{URL: baseURL, Lines: make([]string, 0, len(raw))}
+
+	for _, line := range raw {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			playlist.Lines = append(playlist.Lines, "")
+		case strings.HasPrefix(trimmed, "#EXT"):
+			// Any tag may carry a URI attribute: EXT-X-MEDIA, EXT-X-I-FRAME-STREAM-INF, EXT-X-SESSION-KEY,
+			// EXT-X-SESSION-DATA and others.
+			tag, attrs := splitHLSTag(trimmed)
+			resolved := false
+			for i := range attrs {
+				if attrs[i].key == "URI" {
+					attrs[i].value = quoteHLS(resolveHLSURI(base, unquoteHLS(attrs[i].value)))
+					resolved = true
+				}
+			}
+			if resolved {
+				trimmed = tag + formatHLSAttributes(attrs)
+			}
+			playlist.Lines = append(playlist.Lines, trimmed)
+
+			if tag == hlsMediaTag {
+				playlist.Renditions = append(playlist.Renditions, renditionFromAttributes(attrs))
+			}
+		case strings.HasPrefix(trimmed, "#"):
+			playlist.Lines = append(playlist.Lines, trimmed)
+		default:
+			playlist.Lines = append(playlist.Lines, resolveHLSURI(base, trimmed))
+		}
+	}
+
+	return playlist, nil
+}
+
+// SubtitleRendition picks the subtitle rendition to translate, preferring the given language and then the default one.
+func (p *HLSMasterPlaylist) SubtitleRendition(language string) (HLSRendition, bool) {
+	var fallback *HLSRendition
+	for i := range p.Renditions {
+		r := p.Renditions[i]
+		if r.Type != hlsSubtitlesType || r.URI == "" {
+			continue
+		}
+
+		if language != "" && language != "auto" && strings.EqualFold(primaryLanguage(r.Language), primaryLanguage(language)) {
+			return r, true
+		}
+		if fallback == nil || (r.Default && !fallback.Default) {
+			fallback = &p.Renditions[i]
+		}
+	}
+
+	if fallback == nil {
+		return HLSRendition{}, false
+	}
+	return *fallback, true
+}
+
+// InjectSubtitleRendition returns the playlist with an extra subtitle rendition available to every variant stream.
+// A variant can only reference one subtitle group, so variants that already reference one keep it and the track is
+// added to that group as well; variants without one are pointed at track.GroupID.
+func (p *HLSMasterPlaylist) InjectSubtitleRendition(track HLSRendition) string {
+	if track.GroupID == "" {
+		track.GroupID = hlsDefaultGroup
+		for _, r := range p.Renditions {
+			if r.Type == hlsSubtitlesType && r.GroupID != "" {
+				track.GroupID = r.GroupID
+				break
+			}
+		}
+	}
+
+	// Groups the track is added to, in the order variants reference them
+	var groups []string
+	addGroup := func(group string) {
+		for _, g := range groups {
+			if g == group {
+				return
+			}
+		}
+		groups = append(groups, group)
+	}
+	for _, line := range p.Lines {
+		if !strings.HasPrefix(line, hlsStreamInfTag) {
+			continue
+		}
+		_, attrs := splitHLSTag(line)
+		if group := unquoteHLS(hlsAttributeValue(attrs, "SUBTITLES")); group != "" {
+			addGroup(group)
+			continue
+		}
+		addGroup(track.GroupID)
+	}
+	if len(groups) == 0 {
+		addGroup(track.GroupID)
+	}
+
+	mediaLines := make([]string, 0, len(groups))
+	for _, group := range groups {
+		mediaLines = append(mediaLines, hlsMediaTag+formatHLSAttributes([]hlsAttribute{
+			{key: "TYPE", value: hlsSubtitlesType},
+			{key: "GROUP-ID", value: quoteHLS(group)},
+			{key: "LANGUAGE", value: quoteHLS(track.Language)},
+			{key: "NAME", value: quoteHLS(track.Name)},
+			{key: "DEFAULT", value: hlsBool(track.Default)},
+			{key: "AUTOSELECT", value: hlsBool(track.Autoselect)},
+			{key: "FORCED", value: "NO"},
+			{key: "URI", value: quoteHLS(track.URI)},
+		}))
+	}
+	inGroups := func(attrs []hlsAttribute) bool {
+		group := unquoteHLS(hlsAttributeValue(attrs, "GROUP-ID"))
+		for _, g := range groups {
+			if g == group {
+				return true
+			}
+		}
+		return false
+	}
+
+	insertAt := -1
+	for i, line := range p.Lines {
+		if strings.HasPrefix(line, hlsMediaTag) {
+			insertAt = i + 1
+		}
+	}
+	if insertAt == -1 {
+		for i, line := range p.Lines {
+			if strings.HasPrefix(line, hlsStreamInfTag) {
+				insertAt = i
+				break
+			}
+		}
+	}
+	if insertAt == -1 {
+		insertAt = len(p.Lines)
+	}
+
+	out := make([]string, 0, len(p.Lines)+len(mediaLines))
+	for i, line := range p.Lines {
+		if i == insertAt {
+			out = append(out, mediaLines...)
+		}
+
+		if strings.HasPrefix(line, hlsMediaTag) && track.Default {
+			line = withHLSAttribute(line, hlsMediaTag, "DEFAULT", "NO", func(attrs []hlsAttribute) bool {
+				return hlsAttributeValue(attrs, "TYPE") == hlsSubtitlesType && inGroups(attrs)
+			})
+		}
+		if strings.HasPrefix(line, hlsStreamInfTag) {
+			line = withHLSAttribute(line, hlsStreamInfTag, "SUBTITLES", quoteHLS(track.GroupID), func(attrs []hlsAttribute) bool {
+				return hlsAttributeValue(attrs, "SUBTITLES") == ""
+			})
+		}
+		out = append(out, line)
+	}
+	if insertAt == len(p.Lines) {
+		out = append(out, mediaLines...)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// BuildHLSSubtitlePlaylist wraps a single VTT file into a VOD media playlist.
+func BuildHLSSubtitlePlaylist(vttURL, content string) string {
+	duration := lastCueEndSeconds(content)
+	target := int(math.Ceil(duration))
+	if target < 1 {
+		target = 1
+	}
+
+	lines := []string{
+		hlsHeaderTag,
+		"#EXT-X-VERSION:3",
+		"#EXT-X-TARGETDURATION:" + strconv.Itoa(target),
+		"#EXT-X-MEDIA-SEQUENCE:0",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		fmt.Sprintf("#EXTINF:%.3f,", duration),
+		vttURL,
+		"#EXT-X-ENDLIST",
+		"",
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// joinHLSSubtitleSegments downloads the WebVTT segments of a media playlist and merges them into one file.
+func joinHLSSubtitleSegments(playlist, playlistURL, referer string) (string, error) {
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid playlist URL: %w", err)
+	}
+
+	var segments []string
+	for _, line := range strings.Split(strings.ReplaceAll(playlist, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, hlsStreamInfTag) {
+			return "", fmt.Errorf("expected a subtitle media playlist, got a master playlist")
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		segments = append(segments, resolveHLSURI(base, trimmed))
+	}
+
+	if len(segments) == 0 {
+		return "", fmt.Errorf("subtitle playlist has no segments")
+	}
+	if len(segments) > hlsSegmentLimit {
+		return "", fmt.Errorf("subtitle playlist has too many segments: %d", len(segments))
+	}
+
+	bodies := make([]string, 0, len(segments))
+	for _, segmentURL := range segments {
+		body, err := fetchSubtitle(segmentURL, referer)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch subtitle segment: %w", err)
+		}
+		bodies = append(bodies, body)
+	}
+
+	return mergeVTTSegments(bodies), nil
+}
+
+// mergeVTTSegments joins segmented WebVTT files, keeping the first header and dropping cues repeated across segments.
+func mergeVTTSegments(segments []string) string {
+	header := []string{"WEBVTT"}
+	headerDone := false
+	seen := make(map[string]bool)
+	var cues []string
+
+	for _, segment := range segments {
+		blocks := strings.Split(strings.ReplaceAll(strings.TrimPrefix(segment, utf8BOM), "\r\n", "\n"), "\n\n")
+		for i, block := range blocks {
+			block = strings.Trim(block, "\n")
+			if block == "" {
+				continue
+			}
+
+			if i == 0 && strings.HasPrefix(block, "WEBVTT") {
+				if !headerDone {
+					for _, line := range strings.Split(block, "\n")[1:] {
+						// Segments normally share one X-TIMESTAMP-MAP, so the first one wins.
+						if strings.HasPrefix(line, hlsTimestampMap) {
+							header = append(header, line)
+						}
+					}
+					headerDone = true
+				}
+				continue
+			}
+
+			if seen[block] {
+				continue
+			}
+			seen[block] = true
+			cues = append(cues, block)
+		}
+	}
+
+	return strings.Join(header, "\n") + "\n\n" + strings.Join(cues, "\n\n") + "\n"
+}
+
+func lastCueEndSeconds(content string) float64 {
+	doc, err := ParseVTT(content)
+	if err != nil {
+		return 0
+	}
+
+	var end time.Duration
+	for _, cue := range doc.Cues {
+		if cue.End > end {
+			end = cue.End
+		}
+	}
+	return end.Seconds()
+}
+
+func splitHLSTag(line string) (string, []hlsAttribute) {
+	idx := strings.Index(line, ":")
+	if idx == -1 {
+		return line, nil
+	}
+
+	var attrs []hlsAttribute
+	for _, m := range hlsAttributeRe.FindAllStringSubmatch(line[idx+1:], -1) {
+		attrs = append(attrs, hlsAttribute{key: m[1], value: m[2]})
+	}
+	return line[:idx+1], attrs
+}
+
+func formatHLSAttributes(attrs []hlsAttribute) string {
+	parts := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		parts = append(parts, a.key+"="+a.value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func withHLSAttribute(line, tag, key, value string, match func([]hlsAttribute) bool) string {
+	_, attrs := splitHLSTag(line)
+	if !match(attrs) {
+		return line
+	}
+
+	for i := range attrs {
+		if attrs[i].key == key {
+			attrs[i].value = value
+			return tag + formatHLSAttributes(attrs)
+		}
+	}
+	attrs = append(attrs, hlsAttribute{key: key, value: value})
+	return tag + formatHLSAttributes(attrs)
+}
+
+func hlsAttributeValue(attrs []hlsAttribute, key string) string {
+	for _, a := range attrs {
+		if a.key == key {
+			return a.value
+		}
+	}
+	return ""
+}
+
+func renditionFromAttributes(attrs []hlsAttribute) HLSRendition {
+	return HLSRendition{
+		Type:       hlsAttributeValue(attrs, "TYPE"),
+		GroupID:    unquoteHLS(hlsAttributeValue(attrs, "GROUP-ID")),
+		Language:   unquoteHLS(hlsAttributeValue(attrs, "LANGUAGE")),
+		Name:       unquoteHLS(hlsAttributeValue(attrs, "NAME")),
+		URI:        unquoteHLS(hlsAttributeValue(attrs, "URI")),
+		Default:    hlsAttributeValue(attrs, "DEFAULT") == "YES",
+		Autoselect: hlsAttributeValue(attrs, "AUTOSELECT") == "YES",
+	}
+}
+
+func resolveHLSURI(base *url.URL, ref string) string {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// hlsUnquotable holds the characters an HLS quoted-string cannot contain.
+var hlsUnquotable = strings.NewReplacer(`"`, "", "\r", "", "\n", "")
+
+// quoteHLS makes value an HLS quoted-string. Quoted-strings have no escape syntax (RFC 8216, section 4.2), so double
+// quotes and line breaks are dropped and everything else is kept as is.
+func quoteHLS(value string) string {
+	return `"` + hlsUnquotable.Replace(value) + `"`
+}
+
+func unquoteHLS(value string) string {
+	return strings.Trim(value, `"`)
+}
+
+func hlsBool(v bool) string {
+	if v {
+		return "YES"
+	}
+	return "NO"
+}
+
+func primaryLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if idx := strings.IndexAny(lang, "-_"); idx > 0 {
+		return lang[:idx]
+	}
+	return lang
+}
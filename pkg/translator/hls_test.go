@@ -0,0 +1,197 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",LANGUAGE="ja",NAME="Japanese",DEFAULT=YES,URI="audio/ja.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",LANGUAGE="en",NAME="English",DEFAULT=YES,AUTOSELECT=YES,URI="subs/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2",AUDIO="aud",SUBTITLES="subs"
+720p/index.m3u8
+`
+
+func TestParseHLSMasterPlaylist_ResolvesRelativeURIs(t *testing.T) {
+	playlist, err := ParseHLSMasterPlaylist(testMasterPlaylist, "https://cdn.example.com/show/ep1/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+
+	rendition, ok := playlist.SubtitleRendition("auto")
+	if !ok {
+		t.Fatalf("expected a subtitle rendition")
+	}
+
+	if rendition.URI != "https://cdn.example.com/show/ep1/subs/en.m3u8" {
+		t.Fatalf("unexpected rendition URI: %q", rendition.URI)
+	}
+
+	if rendition.GroupID != "subs" || rendition.Language != "en" {
+		t.Fatalf("unexpected rendition attributes: %#v", rendition)
+	}
+
+	joined := strings.Join(playlist.Lines, "\n")
+	if !strings.Contains(joined, "\nhttps://cdn.example.com/show/ep1/720p/index.m3u8") {
+		t.Fatalf("expected variant URI to be absolute, got: %q", joined)
+	}
+}
+
+func TestInjectSubtitleRendition_AddsTrackToExistingGroup(t *testing.T) {
+	playlist, err := ParseHLSMasterPlaylist(testMasterPlaylist, "https://cdn.example.com/show/ep1/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+
+	got := playlist.InjectSubtitleRendition(HLSRendition{
+		Language:   "id",
+		Name:       "ID (Translated)",
+		URI:        "https://translator.local/api/v1/subtitles/5/playlist.m3u8",
+		Autoselect: true,
+	})
+
+	want := `#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",LANGUAGE="id",NAME="ID (Translated)",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI="https://translator.local/api/v1/subtitles/5/playlist.m3u8"`
+	if !strings.Contains(got, want) {
+		t.Fatalf("expected injected media line, got: %q", got)
+	}
+
+	lines := strings.Split(got, "\n")
+	if lines[3] != want {
+		t.Fatalf("expected injected line after existing media lines, got %q", lines[3])
+	}
+}
+
+func TestInjectSubtitleRendition_AddsGroupToVariantsWithoutSubtitles(t *testing.T) {
+	master := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2000000\nhigh.m3u8\n"
+	playlist, err := ParseHLSMasterPlaylist(master, "https://cdn.example.com/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+
+	got := playlist.InjectSubtitleRendition(HLSRendition{Language: "id", Name: "ID (Translated)", URI: "https://translator.local/p.m3u8"})
+
+	if strings.Count(got, `SUBTITLES="subs"`) != 2 {
+		t.Fatalf("expected both variants to reference the subtitle group, got: %q", got)
+	}
+
+	if !strings.HasPrefix(strings.Split(got, "\n")[1], "#EXT-X-MEDIA:TYPE=SUBTITLES") {
+		t.Fatalf("expected media line before first variant, got: %q", got)
+	}
+}
+
+func TestInjectSubtitleRendition_QuotesWithoutEscapes(t *testing.T) {
+	playlist, err := ParseHLSMasterPlaylist(testMasterPlaylist, "https://cdn.example.com/show/ep1/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+
+	got := playlist.InjectSubtitleRendition(HLSRendition{
+		Language: "id",
+		Name:     "ID \"Fan\" \\ Cut\nEdition",
+		URI:      "https://translator.local/p.m3u8",
+	})
+
+	want := `NAME="ID Fan \ CutEdition",`
+	if !strings.Contains(got, want) {
+		t.Fatalf("expected %s in the injected media line, got: %q", want, got)
+	}
+
+	reparsed, err := ParseHLSMasterPlaylist(got, "https://cdn.example.com/show/ep1/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+	rendition, ok := reparsed.SubtitleRendition("id")
+	if !ok || rendition.Name != `ID Fan \ CutEdition` || rendition.URI != "https://translator.local/p.m3u8" {
+		t.Fatalf("expected the injected rendition to parse back, got %#v", rendition)
+	}
+}
+
+func TestParseHLSMasterPlaylist_ResolvesSessionURIs(t *testing.T) {
+	master := "#EXTM3U\n" +
+		"#EXT-X-SESSION-KEY:METHOD=AES-128,URI=\"keys/session.key\"\n" +
+		"#EXT-X-SESSION-DATA:DATA-ID=\"com.example.title\",URI=\"meta/title.json\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow.m3u8\n"
+	playlist, err := ParseHLSMasterPlaylist(master, "https://cdn.example.com/show/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+
+	joined := strings.Join(playlist.Lines, "\n")
+	for _, want := range []string{
+		`#EXT-X-SESSION-KEY:METHOD=AES-128,URI="https://cdn.example.com/show/keys/session.key"`,
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.title",URI="https://cdn.example.com/show/meta/title.json"`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in playlist, got: %q", want, joined)
+		}
+	}
+}
+
+func TestInjectSubtitleRendition_KeepsVariantSubtitleGroups(t *testing.T) {
+	master := "#EXTM3U\n" +
+		"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs-hd\",LANGUAGE=\"en\",NAME=\"English\",DEFAULT=YES,URI=\"hd/en.m3u8\"\n" +
+		"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs-sd\",LANGUAGE=\"en\",NAME=\"English\",DEFAULT=YES,URI=\"sd/en.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000,SUBTITLES=\"subs-hd\"\nhigh.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,SUBTITLES=\"subs-sd\"\nlow.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=200000\naudio-only.m3u8\n"
+	playlist, err := ParseHLSMasterPlaylist(master, "https://cdn.example.com/master.m3u8")
+	if err != nil {
+		t.Fatalf("ParseHLSMasterPlaylist returned error: %v", err)
+	}
+
+	got := playlist.InjectSubtitleRendition(HLSRendition{
+		GroupID:  "subs-hd",
+		Language: "id",
+		Name:     "ID (Translated)",
+		URI:      "https://translator.local/p.m3u8",
+		Default:  true,
+	})
+
+	for _, want := range []string{
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000,SUBTITLES=\"subs-hd\"\n",
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,SUBTITLES=\"subs-sd\"\n",
+		"#EXT-X-STREAM-INF:BANDWIDTH=200000,SUBTITLES=\"subs-hd\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected variant %q, got: %q", want, got)
+		}
+	}
+
+	for _, group := range []string{"subs-hd", "subs-sd"} {
+		track := `#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="` + group + `",LANGUAGE="id"`
+		if strings.Count(got, track) != 1 {
+			t.Fatalf("expected the translated track once in group %q, got: %q", group, got)
+		}
+	}
+
+	if strings.Count(got, "DEFAULT=YES") != 2 {
+		t.Fatalf("expected only the translated tracks to stay default, got: %q", got)
+	}
+}
+
+func TestMergeVTTSegments_KeepsTimestampMapAndDropsDuplicates(t *testing.T) {
+	segments := []string{
+		"WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		"WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n\n00:00:01.000 --> 00:00:02.000\nHello\n\n00:00:07.000 --> 00:00:09.500\nWorld\n",
+	}
+
+	got := mergeVTTSegments(segments)
+	want := "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n\n00:00:01.000 --> 00:00:02.000\nHello\n\n00:00:07.000 --> 00:00:09.500\nWorld\n"
+
+	if got != want {
+		t.Fatalf("unexpected merged segments:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestBuildHLSSubtitlePlaylist_UsesLastCueEnd(t *testing.T) {
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHalo\n\n01:02.250 --> 01:04.500\nDunia\n"
+	got := BuildHLSSubtitlePlaylist("https://translator.local/storage/subtitles/abc.vtt", content)
+
+	if !strings.Contains(got, "#EXT-X-TARGETDURATION:65\n") {
+		t.Fatalf("expected rounded target duration, got: %q", got)
+	}
+
+	if !strings.Contains(got, "#EXTINF:64.500,\nhttps://translator.local/storage/subtitles/abc.vtt\n") {
+		t.Fatalf("expected single VTT segment, got: %q", got)
+	}
+}
@@ -264,3 +264,16 @@ func TestLooksUntranslatedEnglish(t *testing.T) {
 		})
 	}
 }
+
+func TestPostProcessSubtitleContent_KeepsBilingualOriginalLine(t *testing.T) {
+	input := "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\n<c.original>If you need some help, you know where to find me.</c>\nKalau butuh bantuan, kamu tahu harus cari aku di mana.\n"
+	got := PostProcessSubtitleContent(input, "id")
+
+	if !strings.Contains(got, "<c.original>If you need some help, you know where to find me.</c>") {
+		t.Fatalf("expected original line to stay untranslated, got: %q", got)
+	}
+
+	if !strings.Contains(got, "Kalau butuh bantuan") {
+		t.Fatalf("expected translated line to remain, got: %q", got)
+	}
+}
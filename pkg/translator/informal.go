@@ -5,6 +5,21 @@ import (
 	"strings"
 )
 
+// Translation registers reported by Register.
+const (
+	RegisterDefault  = "default"
+	RegisterInformal = "informal"
+)
+
+// Register names the style translations into targetLang are written in: Indonesian is rewritten to informal
+// speech, other languages keep the engine's style.
+func Register(targetLang string) string {
+	if targetLang == "id" {
+		return RegisterInformal
+	}
+	return RegisterDefault
+}
+
 // FormalizeToInformal converts formal Indonesian text to informal style
 func FormalizeToInformal(text string) string {
 	if text == "" {
@@ -0,0 +1,376 @@
+package translator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lint rules.
+const (
+	RuleMalformedTimestamp = "malformed_timestamp"
+	RuleNonPositiveTiming  = "non_positive_duration"
+	RuleOutOfOrder         = "out_of_order"
+	RuleOverlap            = "overlap"
+	RuleMalformedTag       = "malformed_tag"
+	RuleUnclosedTag        = "unclosed_tag"
+	RuleLineTooLong        = "line_too_long"
+	RuleTooFast            = "too_fast"
+	RuleEmptyCue           = "empty_cue"
+)
+
+// Finding severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	defaultLintMaxLineLength = 42
+	defaultLintMaxCPS        = 20
+)
+
+// LintOptions sets the limits used by Lint and whether safe issues are fixed.
+type LintOptions struct {
+	MaxLineLength int     `json:"max_line_length,omitempty"`
+	MaxCPS        float64 `json:"max_cps,omitempty"`
+	Fix           bool    `json:"fix,omitempty"`
+}
+
+// Finding is one issue found in a subtitle. Index is the 1-based cue position in the source.
+type Finding struct {
+	Index    int           `json:"index"`
+	ID       string        `json:"id,omitempty"`
+	Start    time.Duration `json:"start"`
+	End      time.Duration `json:"end"`
+	Rule     string        `json:"rule"`
+	Severity string        `json:"severity"`
+	Message  string        `json:"message"`
+	// Fixable issues are repaired by Lint when LintOptions.Fix is set; Fixed reports that it happened.
+	Fixable bool `json:"fixable"`
+	Fixed   bool `json:"fixed,omitempty"`
+}
+
+// LintResult holds the findings and, when fixes were applied, the fixed content.
+type LintResult struct {
+	Findings []Finding `json:"findings"`
+	Fixed    int       `json:"fixed"`
+	Content  string    `json:"content,omitempty"`
+}
+
+// HasErrors reports whether any finding has error severity.
+func (r *LintResult) HasErrors() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRule reports whether any finding is for the given rule.
+func (r *LintResult) HasRule(rule string) bool {
+	for _, f := range r.Findings {
+		if f.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks VTT or ASS content for timing, markup and readability issues.
+// With opts.Fix, empty cues are removed, broken and unclosed tags repaired, cues sorted by start time and overlaps
+// trimmed; the result is returned in the source format. Content with malformed timestamps is never rewritten.
+func Lint(content, format string, opts LintOptions) (*LintResult, error) {
+	if opts.MaxLineLength <= 0 {
+		opts.MaxLineLength = defaultLintMaxLineLength
+	}
+	if opts.MaxCPS <= 0 {
+		opts.MaxCPS = defaultLintMaxCPS
+	}
+
+	doc, index, result, err := parseForLint(content, format)
+	if err != nil {
+		return nil, err
+	}
+
+	isVTT := doc.Format == formatVTT
+	var prev *Cue
+	for i, cue := range doc.Cues {
+		at := func(rule, severity string, fixable bool, message string, args ...interface{}) {
+			result.Findings = append(result.Findings, Finding{
+				Index:    index[i],
+				ID:       cue.ID,
+				Start:    cue.Start,
+				End:      cue.End,
+				Rule:     rule,
+				Severity: severity,
+				Message:  fmt.Sprintf(message, args...),
+				Fixable:  fixable,
+			})
+		}
+
+		if strings.TrimSpace(cue.PlainText()) == "" {
+			at(RuleEmptyCue, SeverityWarning, true, "cue has no text")
+		}
+		if cue.End <= cue.Start {
+			at(RuleNonPositiveTiming, SeverityError, false, "cue ends at %s but starts at %s",
+				formatVTTTimestamp(cue.End), formatVTTTimestamp(cue.Start))
+		}
+		if prev != nil {
+			if cue.Start < prev.Start {
+				at(RuleOutOfOrder, SeverityWarning, true, "cue starts before the previous cue")
+			} else if cue.Start < prev.End {
+				at(RuleOverlap, SeverityWarning, cue.Start > prev.Start, "cue overlaps the previous cue by %s",
+					prev.End-cue.Start)
+			}
+		}
+		prev = cue
+
+		if isVTT {
+			for _, line := range cue.Lines {
+				if text := line.String(); normalizeFormattingTags(text) != text {
+					at(RuleMalformedTag, SeverityWarning, true, "broken formatting tag in %q", text)
+				}
+			}
+			if unclosed, stray := unbalancedTags(cue.Lines); len(unclosed) > 0 || len(stray) > 0 {
+				at(RuleUnclosedTag, SeverityWarning, true, "unclosed tags %v, unmatched closing tags %v", unclosed, stray)
+			}
+		}
+
+		for _, line := range cue.Lines {
+			if n := cueTextLen(line.Plain()); n > opts.MaxLineLength {
+				at(RuleLineTooLong, SeverityWarning, false, "line is %d columns wide, limit is %d", n, opts.MaxLineLength)
+			}
+		}
+		if cps := cueCPS(cue); cps > opts.MaxCPS {
+			at(RuleTooFast, SeverityWarning, false, "reading speed %.1f CPS, limit is %.1f", cps, opts.MaxCPS)
+		}
+	}
+
+	if opts.Fix && !result.HasRule(RuleMalformedTimestamp) {
+		result.Fixed = fixLintFindings(doc, result.Findings)
+		if result.Fixed > 0 {
+			if isVTT {
+				result.Content = FormatVTT(doc)
+			} else {
+				result.Content = FormatASS(doc)
+			}
+		}
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool { return result.Findings[i].Index < result.Findings[j].Index })
+	return result, nil
+}
+
+// parseForLint parses content, reporting cues with unparsable timings as findings instead of failing.
+// index maps each parsed cue to its 1-based position among all cues of the source.
+func parseForLint(content, format string) (*Document, []int, *LintResult, error) {
+	result := &LintResult{Findings: []Finding{}}
+	content = strings.ReplaceAll(strings.TrimPrefix(content, utf8BOM), "\r\n", "\n")
+
+	malformed := func(position int, line string, err error) {
+		result.Findings = append(result.Findings, Finding{
+			Index:    position,
+			Rule:     RuleMalformedTimestamp,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("cannot parse timing %q: %v", line, err),
+		})
+	}
+
+	var index []int
+	var kept []string
+	position := 0
+
+	switch format {
+	case formatVTT:
+		for _, block := range splitVTTBlocks(content) {
+			timing := -1
+			for i, line := range block {
+				if strings.Contains(line, "-->") {
+					timing = i
+					break
+				}
+			}
+			if timing == -1 {
+				kept = append(kept, strings.Join(block, "\n"))
+				continue
+			}
+
+			position++
+			if !vttTimestampRe.MatchString(strings.TrimSpace(block[timing])) {
+				malformed(position, block[timing], fmt.Errorf("not a cue timing line"))
+				continue
+			}
+			if _, _, err := parseVTTCue(block); err != nil {
+				malformed(position, block[timing], err)
+				continue
+			}
+			index = append(index, position)
+			kept = append(kept, strings.Join(block, "\n"))
+		}
+		doc, err := ParseVTT(strings.Join(kept, "\n\n"))
+		return doc, index, result, err
+
+	case formatASS:
+		var eventFormat []string
+		inEvents := false
+		for _, line := range strings.Split(content, "\n") {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+				inEvents = strings.EqualFold(trimmed, "[Events]")
+			}
+			key, value, _ := strings.Cut(trimmed, ":")
+			switch {
+			case !inEvents:
+			case key == "Format":
+				eventFormat = nil
+				for _, field := range strings.Split(value, ",") {
+					eventFormat = append(eventFormat, strings.TrimSpace(field))
+				}
+			case key == "Dialogue":
+				position++
+				format := eventFormat
+				if len(format) == 0 {
+					format = defaultASSEventFormat
+				}
+				if _, err := parseASSDialogue(strings.TrimSpace(value), format); err != nil {
+					malformed(position, line, err)
+					continue
+				}
+				index = append(index, position)
+			}
+			kept = append(kept, line)
+		}
+		doc, err := ParseASS(strings.Join(kept, "\n"))
+		return doc, index, result, err
+	}
+
+	return nil, nil, nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// unbalancedTags lists the markup tags of a cue that are opened but never closed, and closing tags with no opener.
+// Voice and language spans may run to the end of the cue, so an open <v> or <lang> is balanced.
+func unbalancedTags(lines []Line) (unclosed, stray []string) {
+	var open []string
+	for _, line := range lines {
+		for _, run := range line {
+			if !run.Tag {
+				continue
+			}
+			tag := classifyMarkupTag(run.Text)
+			switch tag.kind {
+			case markupOpen:
+				open = append(open, tag.name)
+			case markupClose:
+				matched := false
+				for i := len(open) - 1; i >= 0; i-- {
+					if open[i] == tag.name {
+						open = append(open[:i], open[i+1:]...)
+						matched = true
+						break
+					}
+				}
+				if !matched {
+					stray = append(stray, tag.name)
+				}
+			}
+		}
+	}
+	for _, name := range open {
+		if !closesAtCueEnd(name) {
+			unclosed = append(unclosed, name)
+		}
+	}
+	return unclosed, stray
+}
+
+// closesAtCueEnd reports whether a tag may be left open for the end of the cue to close, as WebVTT allows for
+// <v> and <lang>.
+func closesAtCueEnd(name string) bool {
+	return name == "v" || name == "lang"
+}
+
+// fixLintFindings applies the safe fixes for the fixable findings and marks them fixed.
+func fixLintFindings(doc *Document, findings []Finding) int {
+	fixed := 0
+	for i := range findings {
+		if findings[i].Fixable {
+			findings[i].Fixed = true
+			fixed++
+		}
+	}
+	if fixed == 0 {
+		return 0
+	}
+
+	kept := doc.Cues[:0]
+	for _, cue := range doc.Cues {
+		if strings.TrimSpace(cue.PlainText()) == "" {
+			continue
+		}
+		if doc.Format == formatVTT {
+			fixCueTags(cue)
+		}
+		kept = append(kept, cue)
+	}
+	doc.Cues = kept
+
+	sort.SliceStable(doc.Cues, func(i, j int) bool { return doc.Cues[i].Start < doc.Cues[j].Start })
+	for i := 0; i+1 < len(doc.Cues); i++ {
+		cue, next := doc.Cues[i], doc.Cues[i+1]
+		if cue.End > next.Start && next.Start > cue.Start {
+			cue.End = next.Start
+		}
+	}
+
+	return fixed
+}
+
+// fixCueTags repairs broken formatting tags, drops unmatched closing tags and closes tags left open at the end of the
+// cue, apart from <v> and <lang>, which the end of the cue closes.
+func fixCueTags(cue *Cue) {
+	for i, line := range cue.Lines {
+		cue.Lines[i] = ParseVTTLine(normalizeFormattingTags(line.String()))
+	}
+
+	var open []markupTag
+	for i, line := range cue.Lines {
+		fixedLine := line[:0]
+		for _, run := range line {
+			if run.Tag {
+				tag := classifyMarkupTag(run.Text)
+				switch tag.kind {
+				case markupOpen:
+					open = append(open, tag)
+				case markupClose:
+					matched := false
+					for j := len(open) - 1; j >= 0; j-- {
+						if open[j].name == tag.name {
+							open = append(open[:j], open[j+1:]...)
+							matched = true
+							break
+						}
+					}
+					if !matched {
+						continue
+					}
+				}
+			}
+			fixedLine = append(fixedLine, run)
+		}
+		cue.Lines[i] = fixedLine
+	}
+
+	if len(open) == 0 || len(cue.Lines) == 0 {
+		return
+	}
+	last := len(cue.Lines) - 1
+	for i := len(open) - 1; i >= 0; i-- {
+		if closesAtCueEnd(open[i].name) {
+			continue
+		}
+		cue.Lines[last] = append(cue.Lines[last], Run{Text: "</" + open[i].name + ">", Tag: true})
+	}
+}
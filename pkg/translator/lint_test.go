@@ -0,0 +1,162 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintRules(findings []Finding) map[int][]string {
+	rules := map[int][]string{}
+	for _, f := range findings {
+		rules[f.Index] = append(rules[f.Index], f.Rule)
+	}
+	return rules
+}
+
+func TestLint_ReportsIssuesWithCueIndex(t *testing.T) {
+	content := strings.Join([]string{
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:03.000",
+		"<i>Hello",
+		"",
+		"00:00:02.500 --> 00:00:04.000",
+		"Overlapping",
+		"",
+		"00:00:05.000 --> 00:00:05.000",
+		"Zero",
+		"",
+		"00:01:99.000 --> 00:02:00.000",
+		"Bad timing",
+		"",
+		"00:00:04.500 --> 00:00:04.800",
+		"Way too much text for such a short cue",
+		"",
+		"00:00:06.000 --> 00:00:07.000",
+		"",
+	}, "\n")
+
+	result, err := Lint(content, "vtt", LintOptions{MaxLineLength: 30})
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+
+	got := lintRules(result.Findings)
+	want := map[int][]string{
+		1: {RuleUnclosedTag},
+		2: {RuleOverlap},
+		3: {RuleNonPositiveTiming},
+		4: {RuleMalformedTimestamp},
+		5: {RuleOutOfOrder, RuleLineTooLong, RuleTooFast},
+	}
+	for index, rules := range want {
+		if strings.Join(got[index], ",") != strings.Join(rules, ",") {
+			t.Fatalf("cue %d: got rules %v, want %v", index, got[index], rules)
+		}
+	}
+	if !result.HasErrors() {
+		t.Fatalf("expected error severity findings")
+	}
+	if result.Content != "" {
+		t.Fatalf("expected no fixed content without Fix")
+	}
+}
+
+func TestLint_FixesSafeIssues(t *testing.T) {
+	content := strings.Join([]string{
+		"WEBVTT",
+		"",
+		"00:00:04.000 --> 00:00:05.000",
+		"Later i>line",
+		"",
+		"00:00:01.000 --> 00:00:03.000",
+		"<i>Hello</b>",
+		"",
+		"00:00:02.500 --> 00:00:03.500",
+		"Overlapping",
+		"",
+		"00:00:06.000 --> 00:00:07.000",
+		"<i></i>",
+	}, "\n")
+
+	result, err := Lint(content, "vtt", LintOptions{Fix: true})
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+
+	want := "WEBVTT\n\n" +
+		"00:00:01.000 --> 00:00:02.500\n<i>Hello</i>\n\n" +
+		"00:00:02.500 --> 00:00:03.500\nOverlapping\n\n" +
+		"00:00:04.000 --> 00:00:05.000\nLater <i>line</i>\n"
+	if result.Content != want {
+		t.Fatalf("unexpected fixed content:\n got %q\nwant %q", result.Content, want)
+	}
+	if result.Fixed != 5 {
+		t.Fatalf("expected 5 fixed findings, got %d: %+v", result.Fixed, result.Findings)
+	}
+}
+
+func TestLint_MalformedTimestampBlocksFix(t *testing.T) {
+	content := "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n\n00:61:00.000 --> 00:62:00.000\nBad\n"
+
+	result, err := Lint(content, "vtt", LintOptions{Fix: true})
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+	if result.Fixed != 0 || result.Content != "" {
+		t.Fatalf("expected no fixes with malformed timestamps, got %+v", result)
+	}
+}
+
+func TestLint_ASSDialogue(t *testing.T) {
+	content := strings.Join([]string{
+		"[Script Info]",
+		"",
+		"[V4+ Styles]",
+		"Format: Name, Fontname",
+		"",
+		"[Events]",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text",
+		"Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Hello",
+		"Dialogue: 0,0:00:x.00,0:00:03.00,Default,,0,0,0,,Broken",
+		"Dialogue: 0,0:00:04.00,0:00:03.00,Default,,0,0,0,,Backwards",
+	}, "\n")
+
+	result, err := Lint(content, "ass", LintOptions{})
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+
+	got := lintRules(result.Findings)
+	if strings.Join(got[2], ",") != RuleMalformedTimestamp || strings.Join(got[3], ",") != RuleNonPositiveTiming {
+		t.Fatalf("unexpected ASS findings: %+v", result.Findings)
+	}
+}
+
+func TestLint_VoiceAndLanguageSpansMayStayOpen(t *testing.T) {
+	content := strings.Join([]string{
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"<v Mia>Hello there",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"<lang ja>こんにちは",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"<v Mia><i>Still open",
+	}, "\n")
+
+	result, err := Lint(content, "vtt", LintOptions{Fix: true})
+	if err != nil {
+		t.Fatalf("Lint returned error: %v", err)
+	}
+
+	got := lintRules(result.Findings)
+	if len(got[1]) != 0 || len(got[2]) != 0 || strings.Join(got[3], ",") != RuleUnclosedTag {
+		t.Fatalf("expected only the open <i> to be reported, got %v", got)
+	}
+	if !strings.Contains(result.Content, "<v Mia>Hello there\n") || !strings.Contains(result.Content, "<v Mia><i>Still open</i>\n") {
+		t.Fatalf("expected voice spans left open and <i> closed, got %q", result.Content)
+	}
+}
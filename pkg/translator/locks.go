@@ -0,0 +1,192 @@
+package translator
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lock conflict reasons, reported in Report.Conflicts.
+const (
+	ConflictSourceChanged = "source_changed"
+	ConflictSourceRemoved = "source_removed"
+)
+
+// CueLock keeps a human-corrected cue out of re-translation. It is matched to a source cue by cue id, then by
+// timestamps.
+type CueLock struct {
+	ID    string        `json:"id,omitempty"`
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	// Text is the corrected cue text written in place of a translation.
+	Text string `json:"text"`
+	// Source is the source cue text the correction was made against, recorded by the first refresh after the edit.
+	// A lock without one that no source cue matches is a cue the editor added.
+	Source string `json:"source,omitempty"`
+}
+
+// LockEditedCues returns locks updated for a manual edit from before to after: edited and added cues are locked
+// with their new text, locked cues that were only retimed keep their lock at the new timing, and locks on removed
+// cues are released.
+func LockEditedCues(before, after *Document, locks []CueLock) []CueLock {
+	lockOf := matchCueLocks(before, locks)
+
+	updated := []CueLock{}
+	for _, step := range alignCues(before.Cues, after.Cues) {
+		switch step.kind {
+		case "", CueRetimed:
+			if i, ok := lockOf[before.Cues[step.from]]; ok {
+				lock := locks[i]
+				cue := after.Cues[step.to]
+				lock.ID, lock.Start, lock.End = cue.ID, cue.Start, cue.End
+				updated = append(updated, lock)
+			}
+		case CueModified, CueAdded:
+			// An edit resolves any earlier conflict, so the next refresh records the source again
+			if cue := after.Cues[step.to]; strings.TrimSpace(cue.PlainText()) != "" {
+				updated = append(updated, CueLock{ID: cue.ID, Start: cue.Start, End: cue.End, Text: cue.Text()})
+			}
+		}
+	}
+	return updated
+}
+
+// matchCueLocks pairs locks with the cues of doc, each cue taking at most one lock. A lock matches the cue with its
+// id, else the cue with its exact timestamps, else the cue covering most of it when that is over half of either.
+func matchCueLocks(doc *Document, locks []CueLock) map[*Cue]int {
+	matched := make(map[*Cue]int, len(locks))
+	pending := make([]int, 0, len(locks))
+	for i := range locks {
+		pending = append(pending, i)
+	}
+
+	pass := func(match func(cue *Cue, lock CueLock) bool) {
+		rest := pending[:0]
+		for _, i := range pending {
+			found := false
+			for _, cue := range doc.Cues {
+				if _, taken := matched[cue]; !taken && match(cue, locks[i]) {
+					matched[cue] = i
+					found = true
+					break
+				}
+			}
+			if !found {
+				rest = append(rest, i)
+			}
+		}
+		pending = rest
+	}
+
+	pass(func(cue *Cue, lock CueLock) bool { return lock.ID != "" && cue.ID == lock.ID })
+	pass(func(cue *Cue, lock CueLock) bool { return cue.Start == lock.Start && cue.End == lock.End })
+	pass(func(cue *Cue, lock CueLock) bool {
+		overlap := minDuration(cue.End, lock.End) - maxDuration(cue.Start, lock.Start)
+		return overlap > 0 && (2*overlap > cue.End-cue.Start || 2*overlap > lock.End-lock.Start)
+	})
+	return matched
+}
+
+// applyCueLocks matches opts.Locks against the source document and returns the locked cues with their corrected
+// text. Locks on cues the editor added become new cues; locks whose source cue changed or disappeared are reported
+// as conflicts. report.Locks receives the locks to keep for the next refresh.
+func applyCueLocks(doc *Document, locks []CueLock, index map[*Cue]int, report *Report) map[*Cue]string {
+	if len(locks) == 0 {
+		return nil
+	}
+
+	locked := make(map[*Cue]string, len(locks))
+	matched := make(map[int]bool, len(locks))
+	for cue, i := range matchCueLocks(doc, locks) {
+		matched[i] = true
+		lock := locks[i]
+		source := strings.TrimSpace(cue.PlainText())
+		switch {
+		case lock.Source == "":
+			lock.Source = source
+		case lock.Source != source:
+			report.Conflicts = append(report.Conflicts, newCueNote(index[cue], cue, ConflictSourceChanged))
+		}
+		lock.ID, lock.Start, lock.End = cue.ID, cue.Start, cue.End
+
+		locked[cue] = lock.Text
+		report.Locked = append(report.Locked, newCueNote(index[cue], cue, ""))
+		report.Locks = append(report.Locks, lock)
+	}
+
+	added := false
+	for i, lock := range locks {
+		if matched[i] {
+			continue
+		}
+		if lock.Source != "" {
+			report.Conflicts = append(report.Conflicts, CueNote{ID: lock.ID, Start: lock.Start, End: lock.End, Reason: ConflictSourceRemoved})
+			continue
+		}
+		cue := &Cue{ID: lock.ID, Start: lock.Start, End: lock.End}
+		cue.SetText(lock.Text)
+		doc.Cues = append(doc.Cues, cue)
+		locked[cue] = lock.Text
+		report.Locks = append(report.Locks, lock)
+		added = true
+	}
+	if added {
+		sort.SliceStable(doc.Cues, func(i, j int) bool { return doc.Cues[i].Start < doc.Cues[j].Start })
+	}
+
+	sort.SliceStable(report.Locks, func(i, j int) bool { return report.Locks[i].Start < report.Locks[j].Start })
+	return locked
+}
+
+// unlockedBatches leaves locked cues out of translation.
+func unlockedBatches(batches []vttCueBatch, locked map[*Cue]string) []vttCueBatch {
+	if len(locked) == 0 {
+		return batches
+	}
+	kept := batches[:0]
+	for _, batch := range batches {
+		if _, ok := locked[batch.cue]; !ok {
+			kept = append(kept, batch)
+		}
+	}
+	return kept
+}
+
+// restoreLockedCues writes the corrected text of locked cues.
+func restoreLockedCues(locked map[*Cue]string) {
+	for cue, text := range locked {
+		cue.SetText(text)
+	}
+}
+
+// mergeCueSets combines two sets of cues held out of processing.
+func mergeCueSets(a, b map[*Cue]string) map[*Cue]string {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	merged := make(map[*Cue]string, len(a)+len(b))
+	for cue, v := range a {
+		merged[cue] = v
+	}
+	for cue, v := range b {
+		merged[cue] = v
+	}
+	return merged
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
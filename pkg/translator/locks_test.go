@@ -0,0 +1,102 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockEditedCues_LocksEditedAndAddedCues(t *testing.T) {
+	before := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Halo!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Apa kabar?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Sampai jumpa",
+	)
+	after := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.500 --> 00:00:02.500",
+		"Halo!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Gimana kabarnya?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Sampai jumpa",
+		"",
+		"00:00:07.000 --> 00:00:08.000",
+		"Dadah!",
+	)
+	locks := []CueLock{{Start: time.Second, End: 2 * time.Second, Text: "Halo!", Source: "Hello!"}}
+
+	got := LockEditedCues(before, after, locks)
+
+	want := []CueLock{
+		{Start: 1500 * time.Millisecond, End: 2500 * time.Millisecond, Text: "Halo!", Source: "Hello!"},
+		{Start: 3 * time.Second, End: 4 * time.Second, Text: "Gimana kabarnya?"},
+		{Start: 7 * time.Second, End: 8 * time.Second, Text: "Dadah!"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d locks, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("lock %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTranslateDocument_KeepsLockedCues(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Hello!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"How are you doing?",
+	)
+	locks := []CueLock{
+		{Start: time.Second, End: 2 * time.Second, Text: "Halo!"},
+		{Start: 3 * time.Second, End: 4 * time.Second, Text: "Gimana kabarnya?", Source: "How are you?"},
+		{Start: 5 * time.Second, End: 6 * time.Second, Text: "Dadah!"},
+		{Start: 8 * time.Second, End: 9 * time.Second, Text: "Sampai jumpa", Source: "See you"},
+	}
+
+	// Every source cue is locked, so nothing is sent to the engine.
+	report, err := TranslateDocument(doc, "id", "en", Options{Locks: locks})
+	if err != nil {
+		t.Fatalf("TranslateDocument returned error: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Halo!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Gimana kabarnya?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Dadah!",
+		"",
+	}, "\n")
+	if got := FormatVTT(doc); got != want {
+		t.Fatalf("unexpected output:\n got %q\nwant %q", got, want)
+	}
+
+	if len(report.Conflicts) != 2 || report.Conflicts[0].Reason != ConflictSourceRemoved || report.Conflicts[1].Reason != ConflictSourceChanged {
+		t.Fatalf("expected a removed and a changed source conflict, got %+v", report.Conflicts)
+	}
+	if len(report.Locks) != 3 || report.Locks[0].Source != "Hello!" || report.Locks[1].Source != "How are you?" || report.Locks[2].Source != "" {
+		t.Fatalf("unexpected locks to store: %+v", report.Locks)
+	}
+}
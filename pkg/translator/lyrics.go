@@ -0,0 +1,124 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Handling of song lyric cues.
+const (
+	LyricsTranslate = "translate"
+	LyricsOriginal  = "original"
+	LyricsRomanized = "romanized"
+)
+
+// Lyric detection reasons, reported in Report.Lyrics.
+const (
+	LyricReasonMusicNotes  = "music_notes"
+	LyricReasonKaraoke     = "karaoke"
+	LyricReasonSongStyle   = "song_style"
+	LyricReasonItalicBlock = "italic_block"
+)
+
+const (
+	// minItalicLyricRun is how many fully italic cues in a row are taken for a song rather than narration.
+	minItalicLyricRun = 3
+	// karaokeField marks ASS cues carrying \k karaoke timing, which ToVTT drops with the other override tags.
+	karaokeField = "Karaoke"
+)
+
+var (
+	// songStyleRe matches ASS style names used for opening, ending and insert songs, such as OP, ED-Romaji or Song_JP.
+	songStyleRe = regexp.MustCompile(`(?i)(^|[^a-z])(op|ed|opening|ending|songs?|karaoke|lyrics?|insert)([^a-z]|$)`)
+	// assKaraokeRe matches ASS karaoke override tags: \k, \K, \kf and \ko.
+	assKaraokeRe = regexp.MustCompile(`\\[kK][fo]?\d`)
+	// vttTimestampTagRe matches a WebVTT karaoke timestamp tag such as <00:01.500>.
+	vttTimestampTagRe = regexp.MustCompile(`^<(\d+:)?\d{2}:\d{2}\.\d{3}>$`)
+)
+
+// detectLyricCues finds cues that carry song lyrics rather than dialogue, with the reason each was picked.
+func detectLyricCues(doc *Document) map[*Cue]string {
+	lyrics := make(map[*Cue]string)
+	var italicRun []*Cue
+	flushItalics := func() {
+		if len(italicRun) >= minItalicLyricRun {
+			for _, cue := range italicRun {
+				if _, ok := lyrics[cue]; !ok {
+					lyrics[cue] = LyricReasonItalicBlock
+				}
+			}
+		}
+		italicRun = italicRun[:0]
+	}
+
+	for _, cue := range doc.Cues {
+		switch {
+		case songStyleRe.MatchString(cue.Style):
+			lyrics[cue] = LyricReasonSongStyle
+		case hasKaraokeTiming(cue):
+			lyrics[cue] = LyricReasonKaraoke
+		case hasMusicNotes(cue):
+			lyrics[cue] = LyricReasonMusicNotes
+		}
+
+		if isItalicLyricLine(cue) {
+			italicRun = append(italicRun, cue)
+			continue
+		}
+		flushItalics()
+	}
+	flushItalics()
+
+	return lyrics
+}
+
+func hasKaraokeTiming(cue *Cue) bool {
+	if cue.Fields[karaokeField] != "" {
+		return true
+	}
+	for _, line := range cue.Lines {
+		for _, run := range line {
+			if run.Tag && (vttTimestampTagRe.MatchString(run.Text) || assKaraokeRe.MatchString(run.Text)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasMusicNotes reports whether a cue is sung: it opens with a music note and has words. Bracketed descriptions
+// such as [♪ upbeat music ♪] do not open with a note and are left to SDH handling.
+func hasMusicNotes(cue *Cue) bool {
+	text := strings.TrimLeft(strings.TrimSpace(cue.PlainText()), "- ")
+	if !strings.HasPrefix(text, "♪") && !strings.HasPrefix(text, "♫") {
+		return false
+	}
+	words := strings.Trim(strings.NewReplacer("♪", " ", "♫", " ").Replace(text), " \n#")
+	return words != ""
+}
+
+// isItalicLyricLine reports whether every line of a cue is wrapped in <i> and the cue does not end a sentence
+// with a full stop, as narration does.
+func isItalicLyricLine(cue *Cue) bool {
+	if len(cue.Lines) == 0 {
+		return false
+	}
+	text := strings.TrimSpace(cue.Text())
+	if !strings.HasPrefix(text, "<i>") || !strings.HasSuffix(text, "</i>") {
+		return false
+	}
+	if inner := text[len("<i>") : len(text)-len("</i>")]; strings.Contains(inner, "</i>") {
+		return false
+	}
+	plain := strings.TrimSpace(cue.PlainText())
+	return plain != "" && !strings.HasSuffix(plain, ".")
+}
+
+// lyricNotes reports the detected lyric cues.
+func lyricNotes(lyrics map[*Cue]string, index map[*Cue]int) []CueNote {
+	notes := make([]CueNote, 0, len(lyrics))
+	for cue, reason := range lyrics {
+		notes = append(notes, newCueNote(index[cue], cue, reason))
+	}
+	return notes
+}
@@ -0,0 +1,174 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLyricCues_ReasonsByRule(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"♪ Under the falling stars ♪",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"<00:03.000>Sing <00:03.500>along",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"[♪ upbeat music ♪]",
+		"",
+		"00:00:07.000 --> 00:00:08.000",
+		"<i>We run through the night</i>",
+		"",
+		"00:00:08.500 --> 00:00:09.500",
+		"<i>Chasing the light</i>",
+		"",
+		"00:00:10.000 --> 00:00:11.000",
+		"<i>Never looking back</i>",
+		"",
+		"00:00:12.000 --> 00:00:13.000",
+		"<i>He left town.</i>",
+		"",
+		"00:00:14.000 --> 00:00:15.000",
+		"<i>Years ago.</i>",
+	)
+
+	lyrics := detectLyricCues(doc)
+
+	want := map[int]string{
+		0: LyricReasonMusicNotes,
+		1: LyricReasonKaraoke,
+		3: LyricReasonItalicBlock,
+		4: LyricReasonItalicBlock,
+		5: LyricReasonItalicBlock,
+	}
+	if len(lyrics) != len(want) {
+		t.Fatalf("expected %d lyric cues, got %d: %v", len(want), len(lyrics), lyrics)
+	}
+	for i, reason := range want {
+		if got := lyrics[doc.Cues[i]]; got != reason {
+			t.Fatalf("cue %d: got reason %q want %q", i+1, got, reason)
+		}
+	}
+}
+
+func TestToVTT_KeepsSongStyleAndKaraoke(t *testing.T) {
+	content := strings.Join([]string{
+		"[Script Info]",
+		"ScriptType: v4.00+",
+		"",
+		"[Events]",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text",
+		"Dialogue: 0,0:00:01.00,0:00:03.00,ED-Romaji,,0,0,0,,Kimi no koe",
+		"Dialogue: 0,0:00:04.00,0:00:06.00,Default,,0,0,0,,{\\k20}Ha{\\k30}ru",
+		"Dialogue: 0,0:00:07.00,0:00:09.00,Default,,0,0,0,,Where are you going?",
+		"",
+	}, "\n")
+
+	doc, err := ParseASS(content)
+	if err != nil {
+		t.Fatalf("ParseASS returned error: %v", err)
+	}
+	vtt := doc.ToVTT()
+
+	lyrics := detectLyricCues(vtt)
+	if len(lyrics) != 2 || lyrics[vtt.Cues[0]] != LyricReasonSongStyle || lyrics[vtt.Cues[1]] != LyricReasonKaraoke {
+		t.Fatalf("unexpected lyric cues: %v", lyrics)
+	}
+	if strings.Contains(FormatVTT(vtt), karaokeField) {
+		t.Fatalf("karaoke marker leaked into the output:\n%s", FormatVTT(vtt))
+	}
+}
+
+func TestCollectVTTCueBatches_LyricsOptions(t *testing.T) {
+	lines := []string{
+		"00:00:01.000 --> 00:00:02.000",
+		"♪ Walking down the road",
+		"",
+		"00:00:02.100 --> 00:00:03.000",
+		"♪ under the open sky",
+		"",
+		"00:00:04.000 --> 00:00:05.000",
+		"Did you hear that",
+	}
+
+	doc := mustParseVTT(t, lines...)
+	batches := collectVTTCueBatches(doc, nil, Options{}.Normalized(), detectLyricCues(doc))
+	if groups := groupSentenceCues(batches); len(groups) != 3 {
+		t.Fatalf("expected lyric cues to be translated one by one, got %d groups", len(groups))
+	}
+
+	doc = mustParseVTT(t, lines...)
+	opts := Options{Lyrics: LyricsOriginal, SDH: SDHKeep}.Normalized()
+	batches = collectVTTCueBatches(doc, nil, opts, detectLyricCues(doc))
+	if len(batches) != 1 || batches[0].originalText != "Did you hear that" {
+		t.Fatalf("expected only dialogue to be translated, got %+v", batches)
+	}
+
+	doc = mustParseVTT(t, lines...)
+	opts = Options{Lyrics: LyricsRomanized, SDH: SDHKeep}.Normalized()
+	batches = collectVTTCueBatches(doc, nil, opts, detectLyricCues(doc))
+	if len(batches) != 3 || !batches[0].lyric || batches[0].markup != nil {
+		t.Fatalf("expected romanized lyrics to be translated without SDH masking, got %+v", batches)
+	}
+}
+
+func TestSDH_LeavesLyricsToTranslate(t *testing.T) {
+	lines := []string{
+		"00:00:01.000 --> 00:00:02.000",
+		"♪ Walking down the road ♪",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"♪ music ♪",
+		"[door creaks]",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"JOHN: Did you hear that?",
+	}
+
+	doc := mustParseVTT(t, lines...)
+	opts := Options{SDH: SDHStrip, Lyrics: LyricsTranslate}.Normalized()
+	lyrics := detectLyricCues(doc)
+	report := &Report{}
+	stripSDHAnnotations(doc, cueIndex(doc), report, mergeCueSets(lyrics, nil))
+	if len(report.Dropped) != 0 {
+		t.Fatalf("expected no lyric cue to be dropped as sdh_only, got %+v", report.Dropped)
+	}
+	batches := collectVTTCueBatches(doc, nil, opts, lyrics)
+	if len(batches) != 3 || batches[0].sourceText != "♪ Walking down the road ♪" || batches[2].sourceText != "Did you hear that?" {
+		t.Fatalf("expected sung lines kept for translation and dialogue stripped, got %+v", batches)
+	}
+
+	doc = mustParseVTT(t, lines...)
+	opts = Options{SDH: SDHKeep, Lyrics: LyricsTranslate}.Normalized()
+	batches = collectVTTCueBatches(doc, nil, opts, detectLyricCues(doc))
+	if len(batches) != 3 || !batches[0].lyric || batches[0].markup != nil || batches[0].sourceText != "♪ Walking down the road ♪" {
+		t.Fatalf("expected the sung line to be sent for translation unmasked, got %+v", batches)
+	}
+	if batches[2].lyric || batches[2].markup == nil {
+		t.Fatalf("expected dialogue annotations to stay masked, got %+v", batches[2])
+	}
+}
+
+func TestAddRomanizedLine_SitsAboveTranslation(t *testing.T) {
+	cue := &Cue{}
+	cue.SetLines([]string{"<c.original>君の名は</c>", "Namamu"})
+
+	addRomanizedLine(cue, "Kimi no na wa", Options{Output: OutputBilingual}.Normalized())
+
+	want := "<c.original>君の名は</c>\n<c.romanized>Kimi no na wa</c>\nNamamu"
+	if got := cue.Text(); got != want {
+		t.Fatalf("unexpected cue text:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestParseTransliteration(t *testing.T) {
+	body := []byte(`[[[null,null,null,"Kimi no na wa"]],null,"ja"]`)
+
+	got, err := parseTransliteration(body)
+	if err != nil {
+		t.Fatalf("parseTransliteration returned error: %v", err)
+	}
+	if got != "Kimi no na wa" {
+		t.Fatalf("unexpected transliteration: %q", got)
+	}
+}
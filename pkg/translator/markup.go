@@ -0,0 +1,233 @@
+package translator
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Inline markup is swapped for ⟦N⟧ placeholders before translation; the engine leaves these brackets alone.
+var (
+	markupPlaceholderRe      = regexp.MustCompile(`⟦\s*(\d+)\s*⟧`)
+	vttKaraokeTimestampRe    = regexp.MustCompile(`^<\d{2}(?::\d{2}){1,2}\.\d{3}>$`)
+	spaceAfterPlaceholderRe  = regexp.MustCompile(`(⟦\d+⟧)[ \t]+`)
+	spaceBeforePlaceholderRe = regexp.MustCompile(`[ \t]+(⟦\d+⟧)`)
+)
+
+type markupTagKind int
+
+const (
+	markupOpen markupTagKind = iota
+	markupClose
+	// markupAtom covers karaoke timestamps and whole <rt>...</rt> annotations, which are kept but not translated.
+	markupAtom
+)
+
+type markupTag struct {
+	text string
+	kind markupTagKind
+	name string
+	// pair is the index of the matching open/close tag, or -1.
+	pair int
+	// annotation marks an SDH annotation kept untranslated; unlike other atoms it survives a failed restore.
+	annotation bool
+}
+
+// cueMarkup holds the tags replaced by placeholders in one cue's source text.
+type cueMarkup struct {
+	tags []markupTag
+}
+
+func markupPlaceholder(i int) string {
+	return "⟦" + strconv.Itoa(i) + "⟧"
+}
+
+// maskCueMarkup joins cue lines into one sentence, replacing every tag run with a placeholder.
+// With keepSDH, hearing-impaired annotations are masked as well. It returns nil markup when nothing was masked.
+func maskCueMarkup(lines []Line, keepSDH bool) (string, *cueMarkup) {
+	markup := &cueMarkup{}
+	var parts []string
+	var open []int
+	var ruby *strings.Builder
+
+	for _, line := range lines {
+		var b strings.Builder
+		lineStart := true
+		for _, run := range line {
+			if ruby != nil {
+				ruby.WriteString(run.Text)
+				if run.Tag && markupTagName(run.Text) == "rt" && strings.HasPrefix(run.Text, "</") {
+					b.WriteString(markup.add(markupTag{text: ruby.String(), kind: markupAtom, pair: -1}))
+					ruby = nil
+				}
+				continue
+			}
+
+			if !run.Tag {
+				text := run.Text
+				if keepSDH {
+					text = maskSDHAnnotations(text, lineStart, markup)
+				}
+				b.WriteString(text)
+				lineStart = false
+				continue
+			}
+
+			tag := classifyMarkupTag(run.Text)
+			switch {
+			case tag.kind == markupOpen && tag.name == "rt":
+				ruby = &strings.Builder{}
+				ruby.WriteString(run.Text)
+				continue
+			case tag.kind == markupOpen:
+				open = append(open, len(markup.tags))
+			case tag.kind == markupClose:
+				for i := len(open) - 1; i >= 0; i-- {
+					if markup.tags[open[i]].name == tag.name {
+						tag.pair = open[i]
+						markup.tags[open[i]].pair = len(markup.tags)
+						open = append(open[:i], open[i+1:]...)
+						break
+					}
+				}
+			}
+			b.WriteString(markup.add(tag))
+		}
+		if ruby != nil {
+			// An unterminated <rt> is kept verbatim up to the end of the line.
+			b.WriteString(markup.add(markupTag{text: ruby.String(), kind: markupAtom, pair: -1}))
+			ruby = nil
+		}
+
+		if text := strings.TrimSpace(b.String()); text != "" && !isDigitOnly(text) {
+			parts = append(parts, text)
+		}
+	}
+
+	if len(markup.tags) == 0 {
+		return strings.Join(parts, " "), nil
+	}
+	return strings.Join(parts, " "), markup
+}
+
+func (m *cueMarkup) add(tag markupTag) string {
+	m.tags = append(m.tags, tag)
+	return markupPlaceholder(len(m.tags) - 1)
+}
+
+func classifyMarkupTag(text string) markupTag {
+	tag := markupTag{text: text, pair: -1, name: markupTagName(text)}
+	switch {
+	case vttKaraokeTimestampRe.MatchString(text):
+		tag.kind = markupAtom
+	case strings.HasPrefix(text, "</"):
+		tag.kind = markupClose
+	default:
+		tag.kind = markupOpen
+	}
+	return tag
+}
+
+// markupTagName returns the element name of a tag: "c" for <c.yellow>, "v" for <v Bob>, "i" for </i>.
+func markupTagName(text string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(text, "<"), "/")
+	if idx := strings.IndexAny(name, ". \t>"); idx != -1 {
+		name = name[:idx]
+	}
+	return strings.ToLower(name)
+}
+
+// restore puts the tags back into translated text. When the placeholders did not survive translation
+// intact, the open tags wrap the whole cue instead and untranslatable atoms such as karaoke timestamps are dropped.
+func (m *cueMarkup) restore(text string) string {
+	text = markupPlaceholderRe.ReplaceAllString(text, "⟦$1⟧")
+	if m == nil {
+		return markupPlaceholderRe.ReplaceAllString(text, "")
+	}
+
+	if restored, ok := m.restoreInPlace(text); ok {
+		return restored
+	}
+	return m.wrapWhole(strings.TrimSpace(markupPlaceholderRe.ReplaceAllString(text, "")))
+}
+
+func (m *cueMarkup) restoreInPlace(text string) (string, bool) {
+	positions := make(map[int]int, len(m.tags))
+	for _, loc := range markupPlaceholderRe.FindAllStringSubmatchIndex(text, -1) {
+		idx, err := strconv.Atoi(text[loc[2]:loc[3]])
+		if err != nil || idx >= len(m.tags) {
+			return "", false
+		}
+		if _, dup := positions[idx]; dup {
+			return "", false
+		}
+		positions[idx] = loc[0]
+	}
+	if len(positions) != len(m.tags) {
+		return "", false
+	}
+	for i, tag := range m.tags {
+		if tag.kind == markupOpen && tag.pair != -1 && positions[i] > positions[tag.pair] {
+			return "", false
+		}
+	}
+
+	// Tidy spacing the engine puts inside tags: "<i> text </i>" becomes "<i>text</i>".
+	text = spaceAfterPlaceholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		if m.placeholderKind(match) == markupOpen {
+			return strings.TrimSpace(match)
+		}
+		return match
+	})
+	text = spaceBeforePlaceholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		if m.placeholderKind(match) == markupClose {
+			return strings.TrimSpace(match)
+		}
+		return match
+	})
+
+	return markupPlaceholderRe.ReplaceAllStringFunc(text, func(placeholder string) string {
+		idx, _ := strconv.Atoi(markupPlaceholderRe.FindStringSubmatch(placeholder)[1])
+		return m.tags[idx].text
+	}), true
+}
+
+func (m *cueMarkup) placeholderKind(match string) markupTagKind {
+	idx, _ := strconv.Atoi(markupPlaceholderRe.FindStringSubmatch(match)[1])
+	return m.tags[idx].kind
+}
+
+func (m *cueMarkup) wrapWhole(text string) string {
+	var prefix strings.Builder
+	var closes []int
+	var annotations []string
+	for _, tag := range m.tags {
+		if tag.annotation {
+			annotations = append(annotations, tag.text)
+			continue
+		}
+		if tag.kind != markupOpen {
+			continue
+		}
+		prefix.WriteString(tag.text)
+		if tag.pair != -1 {
+			closes = append(closes, tag.pair)
+		}
+	}
+
+	// Close in reverse order of opening so the tags stay nested.
+	var suffix strings.Builder
+	for i := len(closes) - 1; i >= 0; i-- {
+		suffix.WriteString(m.tags[closes[i]].text)
+	}
+
+	if len(annotations) > 0 {
+		text = strings.Join(annotations, " ") + " " + text
+	}
+	return prefix.String() + text + suffix.String()
+}
+
+// cueTextLen is the display width of cue text in columns, ignoring markup placeholders.
+func cueTextLen(text string) int {
+	return displayWidth(markupPlaceholderRe.ReplaceAllString(text, ""))
+}
@@ -0,0 +1,104 @@
+package translator
+
+import "testing"
+
+func maskedTestCue(t *testing.T, text string) (string, *cueMarkup) {
+	t.Helper()
+
+	cue := &Cue{}
+	cue.SetText(text)
+	return maskCueMarkup(cue.Lines, false)
+}
+
+func TestCueMarkup_RestoresTagsByKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		wantMasked string
+		translated string
+		want       string
+	}{
+		{
+			name:       "voice",
+			source:     "<v Bob>Hello there</v>",
+			wantMasked: "⟦0⟧Hello there⟦1⟧",
+			translated: "⟦0⟧ Halo ⟦1⟧",
+			want:       "<v Bob>Halo</v>",
+		},
+		{
+			name:       "class",
+			source:     "Run <c.yellow>now</c>!",
+			wantMasked: "Run ⟦0⟧now⟦1⟧!",
+			translated: "Lari ⟦0⟧ sekarang ⟦1⟧!",
+			want:       "Lari <c.yellow>sekarang</c>!",
+		},
+		{
+			name:       "ruby",
+			source:     "<ruby>漢<rt>kan</rt></ruby> is here",
+			wantMasked: "⟦0⟧漢⟦1⟧⟦2⟧ is here",
+			translated: "⟦0⟧漢⟦1⟧⟦2⟧ ada di sini",
+			want:       "<ruby>漢<rt>kan</rt></ruby> ada di sini",
+		},
+		{
+			name:       "karaoke",
+			source:     "<00:01.500>Sing <00:02.000>along",
+			wantMasked: "⟦0⟧Sing ⟦1⟧along",
+			translated: "⟦0⟧Bernyanyi ⟦ 1 ⟧bersama",
+			want:       "<00:01.500>Bernyanyi <00:02.000>bersama",
+		},
+		{
+			name:       "tags across lines",
+			source:     "<i>first line\nsecond line</i>",
+			wantMasked: "⟦0⟧first line second line⟦1⟧",
+			translated: "⟦0⟧baris pertama\nbaris kedua⟦1⟧",
+			want:       "<i>baris pertama\nbaris kedua</i>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masked, markup := maskedTestCue(t, tt.source)
+			if masked != tt.wantMasked {
+				t.Fatalf("unexpected masked text: got %q want %q", masked, tt.wantMasked)
+			}
+
+			if got := markup.restore(tt.translated); got != tt.want {
+				t.Fatalf("unexpected restored text: got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCueMarkup_WrapsWholeCueWhenPlaceholdersAreLost(t *testing.T) {
+	_, markup := maskedTestCue(t, "<v Bob>Hello <i>there</i> <00:01.000>friend")
+
+	// Placeholder 2 is missing and 1 is duplicated, so exact placement is impossible.
+	got := markup.restore("⟦0⟧Halo ⟦1⟧teman ⟦1⟧di sana")
+	want := "<v Bob><i>Halo teman di sana</i>"
+	if got != want {
+		t.Fatalf("unexpected fallback: got %q want %q", got, want)
+	}
+}
+
+func TestCueMarkup_FallsBackWhenCloseBeforeOpen(t *testing.T) {
+	_, markup := maskedTestCue(t, "<b>Stop</b> it")
+
+	got := markup.restore("⟦1⟧Hentikan⟦0⟧ itu")
+	if got != "<b>Hentikan itu</b>" {
+		t.Fatalf("unexpected fallback: %q", got)
+	}
+}
+
+func TestTranslatedCueLines_KeepsMarkupThroughWrapping(t *testing.T) {
+	cue := &Cue{}
+	cue.SetText("<v Ana>It's an event the chief throws to thank her subordinates</v>")
+	batch, ok := buildCueBatch(cue, false)
+	if !ok {
+		t.Fatalf("expected cue batch")
+	}
+
+	lines := translatedCueLines(batch, "⟦0⟧Ini adalah acara yang diadakan ketua untuk berterima kasih kepada bawahannya⟦1⟧", "id")
+	if len(lines) != 2 || lines[0] != "<v Ana>Ini adalah acara yang diadakan ketua" || lines[1] != "untuk berterima kasih kepada bawahannya</v>" {
+		t.Fatalf("unexpected wrapped lines: %#v", lines)
+	}
+}
@@ -0,0 +1,886 @@
+package translator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EBML / Matroska element IDs used by the subtitle extractor.
+const (
+	ebmlHeaderID      = 0x1A45DFA3
+	ebmlDocTypeID     = 0x4282
+	mkvSegmentID      = 0x18538067
+	mkvInfoID         = 0x1549A966
+	mkvTimecodeScale  = 0x2AD7B1
+	mkvTracksID       = 0x1654AE6B
+	mkvTrackEntryID   = 0xAE
+	mkvTrackNumberID  = 0xD7
+	mkvTrackTypeID    = 0x83
+	mkvCodecID        = 0x86
+	mkvCodecPrivateID = 0x63A2
+	mkvLanguageID     = 0x22B59C
+	mkvLanguageIETFID = 0x22B59D
+	mkvNameID         = 0x536E
+	mkvFlagDefaultID  = 0x88
+	mkvFlagForcedID   = 0x55AA
+	mkvEncodingsID    = 0x6D80
+	mkvEncodingID     = 0x6240
+	mkvCompressionID  = 0x5034
+	mkvCompAlgoID     = 0x4254
+	mkvCompSettingsID = 0x4255
+	mkvClusterID      = 0x1F43B675
+	mkvTimecodeID     = 0xE7
+	mkvSimpleBlockID  = 0xA3
+	mkvBlockGroupID   = 0xA0
+	mkvBlockID        = 0xA1
+	mkvBlockDuration  = 0x9B
+	mkvCuesID         = 0x1C53BB6B
+	mkvChaptersID     = 0x1043A770
+	mkvAttachmentsID  = 0x1941A469
+	mkvTagsID         = 0x1254C367
+	mkvSeekHeadID     = 0x114D9B74
+	mkvSeekID         = 0x4DBB
+	mkvSeekIDID       = 0x53AB
+	mkvSeekPositionID = 0x53AC
+	mkvCuePointID     = 0xBB
+	mkvCueTimeID      = 0xB3
+	mkvCueTrackPosID  = 0xB7
+	mkvCueTrackID     = 0xF7
+	mkvCueClusterPos  = 0xF1
+	mkvCueRelativePos = 0xF0
+)
+
+const (
+	mkvTrackTypeSubtitle   = 0x11
+	mkvDefaultTimecodeUnit = 1000000
+	mkvMaxElementBytes     = 16 << 20
+	mkvDefaultCueDuration  = 2 * time.Second
+	mkvCompAlgoZlib        = 0
+	mkvCompAlgoHeaderStrip = 3
+)
+
+var errUnknownSize = errors.New("unknown element size")
+
+// MKVTrack describes a text subtitle track muxed inside a Matroska file.
+type MKVTrack struct {
+	Number   uint64 `json:"number"`
+	CodecID  string `json:"codec_id"`
+	Format   string `json:"format"`
+	Language string `json:"language"`
+	Name     string `json:"name"`
+	Default  bool   `json:"default"`
+	Forced   bool   `json:"forced"`
+
+	codecPrivate []byte
+	compAlgo     int
+	compSettings []byte
+	compressed   bool
+}
+
+// MKVSource gives random access to a Matroska file, either uploaded or fetched over HTTP.
+type MKVSource struct {
+	io.ReaderAt
+	Size int64
+}
+
+type mkvBlock struct {
+	start    time.Duration
+	duration time.Duration
+	data     []byte
+}
+
+type mkvFile struct {
+	r             io.ReaderAt
+	size          int64
+	segmentStart  int64
+	segmentEnd    int64
+	timecodeScale int64
+	tracks        []MKVTrack
+	// cuesPos is the offset of the Cues element listed in the SeekHead, or 0 when there is none.
+	cuesPos int64
+}
+
+// mkvCuePosition locates an indexed block: its cluster relative to the segment, and the block relative to the
+// cluster payload, or -1 when the index does not say.
+type mkvCuePosition struct {
+	cluster  int64
+	relative int64
+}
+
+// ListMKVSubtitleTracks returns the text subtitle tracks of a Matroska file.
+func ListMKVSubtitleTracks(src MKVSource) ([]MKVTrack, error) {
+	file, err := openMKV(src)
+	if err != nil {
+		return nil, err
+	}
+	return file.tracks, nil
+}
+
+// ExtractMKVSubtitle extracts a subtitle track as an ASS or VTT document and returns the content with its format.
+func ExtractMKVSubtitle(src MKVSource, trackNumber uint64) (string, string, error) {
+	file, err := openMKV(src)
+	if err != nil {
+		return "", "", err
+	}
+
+	var track *MKVTrack
+	for i := range file.tracks {
+		if file.tracks[i].Number == trackNumber {
+			track = &file.tracks[i]
+			break
+		}
+	}
+	if track == nil {
+		return "", "", fmt.Errorf("subtitle track %d not found", trackNumber)
+	}
+
+	blocks, err := file.readBlocks(track)
+	if err != nil {
+		return "", "", err
+	}
+
+	if track.Format == "ass" {
+		return buildASSFromMKV(track, blocks), "ass", nil
+	}
+	return buildVTTFromMKV(blocks), "vtt", nil
+}
+
+// TranslateMKVSubtitle extracts a subtitle track and translates it through the ASS/VTT pipeline.
+func TranslateMKVSubtitle(src MKVSource, trackNumber uint64, targetLang, sourceLang string) (string, error) {
+	content, _, err := TranslateMKVSubtitleWithOptions(src, trackNumber, targetLang, sourceLang, Options{})
+	return content, err
+}
+
+// TranslateMKVSubtitleWithOptions is TranslateMKVSubtitle with translation options and a report.
+func TranslateMKVSubtitleWithOptions(src MKVSource, trackNumber uint64, targetLang, sourceLang string, opts Options) (string, *Report, error) {
+	content, format, err := ExtractMKVSubtitle(src, trackNumber)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if format == "ass" {
+		return TranslateASSToVTTWithOptions(content, targetLang, sourceLang, opts)
+	}
+	return TranslateVTTWithOptions(content, targetLang, sourceLang, opts)
+}
+
+func openMKV(src MKVSource) (*mkvFile, error) {
+	file := &mkvFile{r: src.ReaderAt, size: src.Size, timecodeScale: mkvDefaultTimecodeUnit}
+
+	id, dataOff, dataSize, err := file.readHeader(0)
+	if err != nil || id != ebmlHeaderID {
+		return nil, fmt.Errorf("not a Matroska file")
+	}
+
+	docType := ""
+	if err := file.walk(dataOff, dataOff+dataSize, func(id uint64, off, size int64) (bool, error) {
+		if id == ebmlDocTypeID {
+			b, err := file.read(off, size)
+			if err != nil {
+				return true, err
+			}
+			docType = string(bytes.TrimRight(b, "\x00"))
+			return true, nil
+		}
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+	if docType != "matroska" && docType != "webm" {
+		return nil, fmt.Errorf("unsupported EBML document type %q", docType)
+	}
+
+	id, segOff, segSize, err := file.readHeader(dataOff + dataSize)
+	if err != nil || id != mkvSegmentID {
+		return nil, fmt.Errorf("matroska segment not found")
+	}
+	file.segmentStart = segOff
+	file.segmentEnd = segOff + segSize
+	if segSize < 0 || file.segmentEnd > file.size {
+		file.segmentEnd = file.size
+	}
+
+	tracksFound := false
+	err = file.walk(file.segmentStart, file.segmentEnd, func(id uint64, off, size int64) (bool, error) {
+		switch id {
+		case mkvSeekHeadID:
+			return false, file.parseSeekHead(off, size)
+		case mkvInfoID:
+			return false, file.parseInfo(off, size)
+		case mkvTracksID:
+			tracksFound = true
+			return true, file.parseTracks(off, size)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !tracksFound {
+		return nil, fmt.Errorf("matroska file has no track list")
+	}
+
+	return file, nil
+}
+
+func (f *mkvFile) parseInfo(off, size int64) error {
+	return f.walk(off, off+size, func(id uint64, off, size int64) (bool, error) {
+		if id == mkvTimecodeScale {
+			b, err := f.read(off, size)
+			if err != nil {
+				return true, err
+			}
+			if scale := int64(readEBMLUint(b)); scale > 0 {
+				f.timecodeScale = scale
+			}
+		}
+		return false, nil
+	})
+}
+
+// parseSeekHead records where the Cues element is, since muxers usually write it after the clusters.
+func (f *mkvFile) parseSeekHead(off, size int64) error {
+	raw, err := f.read(off, size)
+	if err != nil {
+		return err
+	}
+
+	walkEBMLBytes(raw, func(id uint64, seek []byte) {
+		if id != mkvSeekID {
+			return
+		}
+		var target uint64
+		position := int64(-1)
+		walkEBMLBytes(seek, func(id uint64, data []byte) {
+			switch id {
+			case mkvSeekIDID:
+				target = readEBMLUint(data)
+			case mkvSeekPositionID:
+				position = int64(readEBMLUint(data))
+			}
+		})
+		if target == mkvCuesID && position >= 0 {
+			f.cuesPos = f.segmentStart + position
+		}
+	})
+	return nil
+}
+
+func (f *mkvFile) parseTracks(off, size int64) error {
+	return f.walk(off, off+size, func(id uint64, off, size int64) (bool, error) {
+		if id != mkvTrackEntryID {
+			return false, nil
+		}
+
+		raw, err := f.read(off, size)
+		if err != nil {
+			return true, err
+		}
+
+		track, trackType := parseMKVTrackEntry(raw)
+		if trackType != mkvTrackTypeSubtitle || track.Format == "" {
+			return false, nil
+		}
+		f.tracks = append(f.tracks, track)
+		return false, nil
+	})
+}
+
+func parseMKVTrackEntry(raw []byte) (MKVTrack, uint64) {
+	track := MKVTrack{Language: "eng", Default: true}
+	var trackType uint64
+
+	walkEBMLBytes(raw, func(id uint64, data []byte) {
+		switch id {
+		case mkvTrackNumberID:
+			track.Number = readEBMLUint(data)
+		case mkvTrackTypeID:
+			trackType = readEBMLUint(data)
+		case mkvCodecID:
+			track.CodecID = string(bytes.TrimRight(data, "\x00"))
+		case mkvCodecPrivateID:
+			track.codecPrivate = append([]byte(nil), data...)
+		case mkvLanguageID:
+			track.Language = string(bytes.TrimRight(data, "\x00"))
+		case mkvLanguageIETFID:
+			track.Language = string(bytes.TrimRight(data, "\x00"))
+		case mkvNameID:
+			track.Name = string(bytes.TrimRight(data, "\x00"))
+		case mkvFlagDefaultID:
+			track.Default = readEBMLUint(data) == 1
+		case mkvFlagForcedID:
+			track.Forced = readEBMLUint(data) == 1
+		case mkvEncodingsID:
+			walkEBMLBytes(data, func(id uint64, encoding []byte) {
+				if id != mkvEncodingID {
+					return
+				}
+				walkEBMLBytes(encoding, func(id uint64, compression []byte) {
+					if id != mkvCompressionID {
+						return
+					}
+					track.compressed = true
+					walkEBMLBytes(compression, func(id uint64, value []byte) {
+						switch id {
+						case mkvCompAlgoID:
+							track.compAlgo = int(readEBMLUint(value))
+						case mkvCompSettingsID:
+							track.compSettings = append([]byte(nil), value...)
+						}
+					})
+				})
+			})
+		}
+	})
+
+	switch track.CodecID {
+	case "S_TEXT/ASS", "S_TEXT/SSA", "S_ASS", "S_SSA":
+		track.Format = "ass"
+	case "S_TEXT/UTF8":
+		track.Format = "srt"
+	case "S_TEXT/WEBVTT", "D_WEBVTT/SUBTITLES", "D_WEBVTT/CAPTIONS":
+		track.Format = "vtt"
+	}
+
+	return track, trackType
+}
+
+// readBlocks collects the track's blocks in start order. When the Cues element indexes the track, only the
+// indexed clusters are read; otherwise every cluster of the segment is scanned.
+func (f *mkvFile) readBlocks(track *MKVTrack) ([]mkvBlock, error) {
+	blocks, ok, err := f.readCuedBlocks(track)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if blocks, err = f.scanBlocks(track); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].start < blocks[j].start })
+	for i := range blocks {
+		if blocks[i].duration > 0 {
+			continue
+		}
+		blocks[i].duration = mkvDefaultCueDuration
+		if i+1 < len(blocks) && blocks[i+1].start > blocks[i].start {
+			blocks[i].duration = blocks[i+1].start - blocks[i].start
+		}
+	}
+
+	return blocks, nil
+}
+
+// readCuedBlocks reads the blocks the cue index lists for the track. ok is false when the file has no index
+// entries for the track or the index does not point at clusters, and the segment has to be scanned instead.
+func (f *mkvFile) readCuedBlocks(track *MKVTrack) ([]mkvBlock, bool, error) {
+	positions, err := f.cuePositions(track)
+	if err != nil || len(positions) == 0 {
+		return nil, false, err
+	}
+
+	// Clusters with an entry that lacks a relative position are read whole.
+	clusters := make([]int64, 0, len(positions))
+	relatives := make(map[int64][]int64)
+	for _, p := range positions {
+		list, seen := relatives[p.cluster]
+		if !seen {
+			clusters = append(clusters, p.cluster)
+		}
+		if p.relative < 0 || (seen && list == nil) {
+			relatives[p.cluster] = nil
+			continue
+		}
+		relatives[p.cluster] = append(list, p.relative)
+	}
+
+	var blocks []mkvBlock
+	for _, cluster := range clusters {
+		id, off, size, err := f.readHeader(f.segmentStart + cluster)
+		if err != nil {
+			return nil, false, err
+		}
+		if id != mkvClusterID {
+			return nil, false, nil
+		}
+
+		if relatives[cluster] == nil {
+			if _, err := f.readCluster(track, off, size, &blocks); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		timecode, err := f.clusterTimecode(off, size)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, relative := range relatives[cluster] {
+			id, dataOff, dataSize, err := f.readHeader(off + relative)
+			if err != nil {
+				return nil, false, err
+			}
+
+			var block mkvBlock
+			var found bool
+			switch id {
+			case mkvSimpleBlockID:
+				block, found, err = f.readBlock(track, dataOff, dataSize, timecode)
+			case mkvBlockGroupID:
+				block, found, err = f.readBlockGroup(track, dataOff, dataSize, timecode)
+			default:
+				return nil, false, nil
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			if found {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+
+	return blocks, true, nil
+}
+
+// cuePositions returns the distinct index entries of the Cues element for the track.
+func (f *mkvFile) cuePositions(track *MKVTrack) ([]mkvCuePosition, error) {
+	if f.cuesPos <= 0 {
+		return nil, nil
+	}
+	id, off, size, err := f.readHeader(f.cuesPos)
+	if err != nil || id != mkvCuesID {
+		return nil, nil
+	}
+	raw, err := f.read(off, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var positions []mkvCuePosition
+	seen := make(map[mkvCuePosition]bool)
+	walkEBMLBytes(raw, func(id uint64, point []byte) {
+		if id != mkvCuePointID {
+			return
+		}
+		walkEBMLBytes(point, func(id uint64, trackPos []byte) {
+			if id != mkvCueTrackPosID {
+				return
+			}
+			var number uint64
+			position := mkvCuePosition{cluster: -1, relative: -1}
+			walkEBMLBytes(trackPos, func(id uint64, data []byte) {
+				switch id {
+				case mkvCueTrackID:
+					number = readEBMLUint(data)
+				case mkvCueClusterPos:
+					position.cluster = int64(readEBMLUint(data))
+				case mkvCueRelativePos:
+					position.relative = int64(readEBMLUint(data))
+				}
+			})
+			if number == track.Number && position.cluster >= 0 && !seen[position] {
+				seen[position] = true
+				positions = append(positions, position)
+			}
+		})
+	})
+	return positions, nil
+}
+
+// clusterTimecode reads the Timecode element that opens a cluster.
+func (f *mkvFile) clusterTimecode(off, size int64) (int64, error) {
+	end := off + size
+	if size < 0 {
+		end = f.segmentEnd
+	}
+
+	var timecode int64
+	err := f.walk(off, end, func(id uint64, off, size int64) (bool, error) {
+		switch id {
+		case mkvTimecodeID:
+			b, err := f.read(off, size)
+			if err != nil {
+				return true, err
+			}
+			timecode = int64(readEBMLUint(b))
+			return true, nil
+		case mkvSimpleBlockID, mkvBlockGroupID:
+			return true, nil
+		}
+		return false, nil
+	})
+	return timecode, err
+}
+
+// scanBlocks walks every cluster of the segment for the track's blocks.
+func (f *mkvFile) scanBlocks(track *MKVTrack) ([]mkvBlock, error) {
+	var blocks []mkvBlock
+
+	pos := f.segmentStart
+	for pos < f.segmentEnd {
+		id, off, size, err := f.readHeader(pos)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if id != mkvClusterID {
+			if size < 0 {
+				break
+			}
+			pos = off + size
+			continue
+		}
+
+		pos, err = f.readCluster(track, off, size, &blocks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return blocks, nil
+}
+
+// readCluster collects the track's blocks and returns the offset right after the cluster.
+func (f *mkvFile) readCluster(track *MKVTrack, off, size int64, blocks *[]mkvBlock) (int64, error) {
+	end := off + size
+	if size < 0 {
+		end = f.segmentEnd
+	}
+
+	var clusterTimecode int64
+	pos := off
+	for pos < end {
+		id, dataOff, dataSize, err := f.readHeader(pos)
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		switch id {
+		case mkvClusterID, mkvCuesID, mkvTagsID, mkvChaptersID, mkvAttachmentsID, mkvSeekHeadID, mkvInfoID, mkvTracksID:
+			// An unknown-size cluster ends where the next top-level element begins.
+			return pos, nil
+		case mkvTimecodeID:
+			b, err := f.read(dataOff, dataSize)
+			if err != nil {
+				return 0, err
+			}
+			clusterTimecode = int64(readEBMLUint(b))
+		case mkvSimpleBlockID:
+			block, ok, err := f.readBlock(track, dataOff, dataSize, clusterTimecode)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				*blocks = append(*blocks, block)
+			}
+		case mkvBlockGroupID:
+			block, ok, err := f.readBlockGroup(track, dataOff, dataSize, clusterTimecode)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				*blocks = append(*blocks, block)
+			}
+		}
+
+		if dataSize < 0 {
+			return end, nil
+		}
+		pos = dataOff + dataSize
+	}
+
+	return end, nil
+}
+
+func (f *mkvFile) readBlockGroup(track *MKVTrack, off, size, clusterTimecode int64) (mkvBlock, bool, error) {
+	var block mkvBlock
+	var found bool
+	var duration int64 = -1
+
+	err := f.walk(off, off+size, func(id uint64, off, size int64) (bool, error) {
+		switch id {
+		case mkvBlockID:
+			b, ok, err := f.readBlock(track, off, size, clusterTimecode)
+			if err != nil {
+				return true, err
+			}
+			block, found = b, ok
+			if !ok {
+				return true, nil
+			}
+		case mkvBlockDuration:
+			b, err := f.read(off, size)
+			if err != nil {
+				return true, err
+			}
+			duration = int64(readEBMLUint(b))
+		}
+		return false, nil
+	})
+	if err != nil || !found {
+		return mkvBlock{}, false, err
+	}
+
+	if duration >= 0 {
+		block.duration = time.Duration(duration * f.timecodeScale)
+	}
+	return block, true, nil
+}
+
+// readBlock reads only the block header first so blocks of other tracks are skipped without downloading their payload.
+func (f *mkvFile) readBlock(track *MKVTrack, off, size, clusterTimecode int64) (mkvBlock, bool, error) {
+	headLen := size
+	if headLen > 12 {
+		headLen = 12
+	}
+	head, err := f.read(off, headLen)
+	if err != nil {
+		return mkvBlock{}, false, err
+	}
+
+	number, n := readVint(head, true)
+	if n == 0 || number != track.Number || len(head) < n+3 {
+		return mkvBlock{}, false, nil
+	}
+
+	relative := int16(binary.BigEndian.Uint16(head[n : n+2]))
+	flags := head[n+2]
+	if flags&0x06 != 0 {
+		// Laced subtitle blocks are not produced by common muxers.
+		return mkvBlock{}, false, nil
+	}
+
+	raw, err := f.read(off+int64(n+3), size-int64(n+3))
+	if err != nil {
+		return mkvBlock{}, false, err
+	}
+
+	data, err := decodeMKVPayload(track, raw)
+	if err != nil {
+		return mkvBlock{}, false, err
+	}
+
+	return mkvBlock{
+		start: time.Duration((clusterTimecode + int64(relative)) * f.timecodeScale),
+		data:  data,
+	}, true, nil
+}
+
+func decodeMKVPayload(track *MKVTrack, raw []byte) ([]byte, error) {
+	if !track.compressed {
+		return raw, nil
+	}
+
+	switch track.compAlgo {
+	case mkvCompAlgoZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress subtitle block: %w", err)
+		}
+		defer zr.Close()
+		return io.ReadAll(io.LimitReader(zr, mkvMaxElementBytes))
+	case mkvCompAlgoHeaderStrip:
+		return append(append([]byte(nil), track.compSettings...), raw...), nil
+	default:
+		return nil, fmt.Errorf("unsupported subtitle compression algorithm %d", track.compAlgo)
+	}
+}
+
+func buildASSFromMKV(track *MKVTrack, blocks []mkvBlock) string {
+	header := strings.TrimRight(strings.ReplaceAll(string(track.codecPrivate), "\r\n", "\n"), "\n")
+	if !strings.Contains(header, "[Events]") {
+		header += "\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text"
+	}
+
+	type dialogue struct {
+		order int
+		line  string
+	}
+	dialogues := make([]dialogue, 0, len(blocks))
+
+	for _, block := range blocks {
+		// Block payload: ReadOrder, Layer, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+		fields := strings.SplitN(string(block.data), ",", 9)
+		if len(fields) < 9 {
+			continue
+		}
+
+		order, _ := strconv.Atoi(strings.TrimSpace(fields[0]))
+		layer := strings.TrimSpace(fields[1])
+		if _, err := strconv.Atoi(layer); err != nil {
+			layer = "0"
+		}
+
+		line := fmt.Sprintf("Dialogue: %s,%s,%s,%s", layer, formatASSTimestamp(block.start), formatASSTimestamp(block.start+block.duration), strings.Join(fields[2:], ","))
+		dialogues = append(dialogues, dialogue{order: order, line: line})
+	}
+
+	sort.SliceStable(dialogues, func(i, j int) bool { return dialogues[i].order < dialogues[j].order })
+
+	lines := make([]string, 0, len(dialogues)+1)
+	lines = append(lines, header)
+	for _, d := range dialogues {
+		lines = append(lines, d.line)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func buildVTTFromMKV(blocks []mkvBlock) string {
+	doc := &Document{Format: formatVTT}
+	for i, block := range blocks {
+		text := strings.TrimSpace(strings.ReplaceAll(string(block.data), "\r\n", "\n"))
+		if text == "" {
+			continue
+		}
+
+		cue := &Cue{ID: strconv.Itoa(i + 1), Start: block.start, End: block.start + block.duration}
+		cue.SetText(text)
+		doc.Cues = append(doc.Cues, cue)
+	}
+	return FormatVTT(doc)
+}
+
+// walk iterates over the child elements in [start, end) until fn asks to stop.
+func (f *mkvFile) walk(start, end int64, fn func(id uint64, off, size int64) (bool, error)) error {
+	pos := start
+	for pos < end {
+		id, dataOff, dataSize, err := f.readHeader(pos)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		stop, err := fn(id, dataOff, dataSize)
+		if err != nil {
+			return err
+		}
+		if stop || dataSize < 0 {
+			return nil
+		}
+		pos = dataOff + dataSize
+	}
+	return nil
+}
+
+// readHeader returns the element ID, payload offset and payload size (-1 when unknown) at off.
+func (f *mkvFile) readHeader(off int64) (uint64, int64, int64, error) {
+	if off >= f.size {
+		return 0, 0, 0, io.EOF
+	}
+
+	n := int64(12)
+	if off+n > f.size {
+		n = f.size - off
+	}
+	head, err := f.read(off, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	id, idLen := readVint(head, false)
+	if idLen == 0 {
+		return 0, 0, 0, fmt.Errorf("invalid EBML element ID at offset %d", off)
+	}
+	size, sizeLen := readVint(head[idLen:], true)
+	if sizeLen == 0 {
+		return 0, 0, 0, fmt.Errorf("invalid EBML element size at offset %d", off)
+	}
+
+	dataOff := off + int64(idLen+sizeLen)
+	if size == (uint64(1)<<(7*uint(sizeLen)))-1 {
+		return id, dataOff, -1, nil
+	}
+	return id, dataOff, int64(size), nil
+}
+
+func (f *mkvFile) read(off, n int64) ([]byte, error) {
+	if n < 0 {
+		return nil, errUnknownSize
+	}
+	if n > mkvMaxElementBytes {
+		return nil, fmt.Errorf("matroska element too large: %d bytes", n)
+	}
+	if off+n > f.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	buf := make([]byte, n)
+	if _, err := f.r.ReadAt(buf, off); err != nil && !(err == io.EOF && n > 0) {
+		return nil, fmt.Errorf("failed to read matroska data: %w", err)
+	}
+	return buf, nil
+}
+
+// readVint decodes an EBML variable-length integer; IDs keep their length marker, sizes drop it.
+func readVint(b []byte, stripMarker bool) (uint64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+
+	length := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || len(b) < length {
+		return 0, 0
+	}
+
+	value := uint64(b[0])
+	if stripMarker {
+		value &= uint64(0xFF >> uint(length))
+	}
+	for i := 1; i < length; i++ {
+		value = value<<8 | uint64(b[i])
+	}
+	return value, length
+}
+
+func readEBMLUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func walkEBMLBytes(b []byte, fn func(id uint64, data []byte)) {
+	pos := 0
+	for pos < len(b) {
+		id, idLen := readVint(b[pos:], false)
+		if idLen == 0 {
+			return
+		}
+		size, sizeLen := readVint(b[pos+idLen:], true)
+		if sizeLen == 0 {
+			return
+		}
+
+		start := pos + idLen + sizeLen
+		end := start + int(size)
+		if end > len(b) || end < start {
+			return
+		}
+
+		fn(id, b[start:end])
+		pos = end
+	}
+}
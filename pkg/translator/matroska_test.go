@@ -0,0 +1,284 @@
+package translator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ebmlElement(id uint64, payload ...[]byte) []byte {
+	var data []byte
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+
+	var idBytes []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		b := byte(id >> uint(shift))
+		if b != 0 || len(idBytes) > 0 {
+			idBytes = append(idBytes, b)
+		}
+	}
+
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+
+	return append(append(idBytes, size...), data...)
+}
+
+func ebmlUint(id uint64, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return ebmlElement(id, bytes.TrimLeft(b, "\x00"))
+}
+
+func ebmlString(id uint64, s string) []byte {
+	return ebmlElement(id, []byte(s))
+}
+
+func mkvBlockPayload(track byte, relative int16, data string) []byte {
+	head := []byte{0x80 | track, 0, 0, 0x80}
+	binary.BigEndian.PutUint16(head[1:3], uint16(relative))
+	return append(head, []byte(data)...)
+}
+
+func buildTestMKV(t *testing.T) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("Compressed line"))
+	zw.Close()
+
+	assHeader := "[Script Info]\nScriptType: v4.00+\n\n[V4+ Styles]\nFormat: Name, Fontname\nStyle: Default,Arial\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text"
+
+	tracks := ebmlElement(mkvTracksID,
+		ebmlElement(mkvTrackEntryID, ebmlUint(mkvTrackNumberID, 1), ebmlUint(mkvTrackTypeID, 1), ebmlString(mkvCodecID, "V_MPEG4/ISO/AVC")),
+		ebmlElement(mkvTrackEntryID,
+			ebmlUint(mkvTrackNumberID, 2),
+			ebmlUint(mkvTrackTypeID, mkvTrackTypeSubtitle),
+			ebmlString(mkvCodecID, "S_TEXT/ASS"),
+			ebmlElement(mkvCodecPrivateID, []byte(assHeader)),
+			ebmlString(mkvLanguageID, "eng"),
+			ebmlString(mkvNameID, "Full Subs"),
+		),
+		ebmlElement(mkvTrackEntryID,
+			ebmlUint(mkvTrackNumberID, 3),
+			ebmlUint(mkvTrackTypeID, mkvTrackTypeSubtitle),
+			ebmlString(mkvCodecID, "S_TEXT/UTF8"),
+			ebmlString(mkvLanguageID, "jpn"),
+			ebmlUint(mkvFlagDefaultID, 0),
+			ebmlElement(mkvEncodingsID, ebmlElement(mkvEncodingID, ebmlElement(mkvCompressionID, ebmlUint(mkvCompAlgoID, 0)))),
+		),
+	)
+
+	cluster := ebmlElement(mkvClusterID,
+		ebmlUint(mkvTimecodeID, 1000),
+		ebmlElement(mkvSimpleBlockID, mkvBlockPayload(1, 0, strings.Repeat("v", 64))),
+		ebmlElement(mkvBlockGroupID,
+			ebmlElement(mkvBlockID, mkvBlockPayload(2, 500, "0,0,Default,,0,0,0,,Hello, {\\i1}world{\\i0}")),
+			ebmlUint(mkvBlockDuration, 1500),
+		),
+		ebmlElement(mkvBlockGroupID,
+			ebmlElement(mkvBlockID, append(mkvBlockPayload(3, 250, ""), compressed.Bytes()...)),
+			ebmlUint(mkvBlockDuration, 1000),
+		),
+	)
+
+	header := ebmlElement(ebmlHeaderID, ebmlString(ebmlDocTypeID, "matroska"))
+	segment := ebmlElement(mkvSegmentID,
+		ebmlElement(mkvInfoID, ebmlUint(mkvTimecodeScale, 1000000)),
+		tracks,
+		cluster,
+	)
+
+	return append(header, segment...)
+}
+
+func TestListMKVSubtitleTracks_ReturnsTextTracksOnly(t *testing.T) {
+	data := buildTestMKV(t)
+
+	tracks, err := ListMKVSubtitleTracks(MKVSource{ReaderAt: bytes.NewReader(data), Size: int64(len(data))})
+	if err != nil {
+		t.Fatalf("ListMKVSubtitleTracks returned error: %v", err)
+	}
+
+	if len(tracks) != 2 {
+		t.Fatalf("expected 2 subtitle tracks, got %#v", tracks)
+	}
+
+	if tracks[0].Number != 2 || tracks[0].Format != "ass" || tracks[0].Name != "Full Subs" || !tracks[0].Default {
+		t.Fatalf("unexpected ASS track: %#v", tracks[0])
+	}
+
+	if tracks[1].Number != 3 || tracks[1].Format != "srt" || tracks[1].Language != "jpn" || tracks[1].Default {
+		t.Fatalf("unexpected UTF8 track: %#v", tracks[1])
+	}
+}
+
+func TestExtractMKVSubtitle_BuildsASSDocument(t *testing.T) {
+	data := buildTestMKV(t)
+
+	content, format, err := ExtractMKVSubtitle(MKVSource{ReaderAt: bytes.NewReader(data), Size: int64(len(data))}, 2)
+	if err != nil {
+		t.Fatalf("ExtractMKVSubtitle returned error: %v", err)
+	}
+
+	if format != "ass" {
+		t.Fatalf("expected ass format, got %q", format)
+	}
+
+	want := "Dialogue: 0,0:00:01.50,0:00:03.00,Default,,0,0,0,,Hello, {\\i1}world{\\i0}"
+	if !strings.Contains(content, "[Events]") || !strings.Contains(content, want) {
+		t.Fatalf("unexpected ASS content: %q", content)
+	}
+}
+
+func TestExtractMKVSubtitle_DecompressesUTF8Track(t *testing.T) {
+	data := buildTestMKV(t)
+
+	content, format, err := ExtractMKVSubtitle(MKVSource{ReaderAt: bytes.NewReader(data), Size: int64(len(data))}, 3)
+	if err != nil {
+		t.Fatalf("ExtractMKVSubtitle returned error: %v", err)
+	}
+
+	want := "WEBVTT\n\n1\n00:00:01.250 --> 00:00:02.250\nCompressed line\n"
+	if format != "vtt" || content != want {
+		t.Fatalf("unexpected VTT content: format=%q content=%q", format, content)
+	}
+}
+
+func TestOpenRemoteMKV_UsesRangeRequests(t *testing.T) {
+	data := buildTestMKV(t)
+	ranged := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranged++
+		}
+		http.ServeContent(w, r, "episode.mkv", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	src, err := OpenRemoteMKV(server.URL+"/episode.mkv", "")
+	if err != nil {
+		t.Fatalf("OpenRemoteMKV returned error: %v", err)
+	}
+
+	if src.Size != int64(len(data)) {
+		t.Fatalf("expected size %d from Content-Range, got %d", len(data), src.Size)
+	}
+
+	tracks, err := ListMKVSubtitleTracks(src)
+	if err != nil {
+		t.Fatalf("ListMKVSubtitleTracks returned error: %v", err)
+	}
+
+	if len(tracks) != 2 || ranged == 0 {
+		t.Fatalf("expected tracks through range requests, got %d tracks and %d ranged requests", len(tracks), ranged)
+	}
+}
+
+// offsetRecorder records the offsets read from a ReaderAt.
+type offsetRecorder struct {
+	r       *bytes.Reader
+	offsets []int64
+}
+
+func (o *offsetRecorder) ReadAt(p []byte, off int64) (int, error) {
+	o.offsets = append(o.offsets, off)
+	return o.r.ReadAt(p, off)
+}
+
+func TestExtractMKVSubtitle_SeeksThroughCues(t *testing.T) {
+	fixed := func(id uint64, v uint64) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		return ebmlElement(id, b)
+	}
+	seekHead := func(cuesPos uint64) []byte {
+		return ebmlElement(mkvSeekHeadID, ebmlElement(mkvSeekID, ebmlUint(mkvSeekIDID, mkvCuesID), fixed(mkvSeekPositionID, cuesPos)))
+	}
+
+	info := ebmlElement(mkvInfoID, ebmlUint(mkvTimecodeScale, 1000000))
+	tracks := ebmlElement(mkvTracksID,
+		ebmlElement(mkvTrackEntryID, ebmlUint(mkvTrackNumberID, 1), ebmlUint(mkvTrackTypeID, 1), ebmlString(mkvCodecID, "V_MPEG4/ISO/AVC")),
+		ebmlElement(mkvTrackEntryID, ebmlUint(mkvTrackNumberID, 2), ebmlUint(mkvTrackTypeID, mkvTrackTypeSubtitle), ebmlString(mkvCodecID, "S_TEXT/UTF8")),
+	)
+	video := ebmlElement(mkvClusterID,
+		ebmlUint(mkvTimecodeID, 0),
+		ebmlElement(mkvSimpleBlockID, mkvBlockPayload(1, 0, strings.Repeat("v", 4096))),
+		ebmlElement(mkvSimpleBlockID, mkvBlockPayload(1, 40, strings.Repeat("v", 4096))),
+	)
+	timecode := ebmlUint(mkvTimecodeID, 2000)
+	videoBlock := ebmlElement(mkvSimpleBlockID, mkvBlockPayload(1, 0, strings.Repeat("v", 1024)))
+	subtitle := ebmlElement(mkvClusterID, timecode, videoBlock,
+		ebmlElement(mkvBlockGroupID,
+			ebmlElement(mkvBlockID, mkvBlockPayload(2, 500, "Indexed line")),
+			ebmlUint(mkvBlockDuration, 1000),
+		),
+	)
+
+	head := len(seekHead(0)) + len(info) + len(tracks)
+	subtitlePos := uint64(head + len(video))
+	cues := ebmlElement(mkvCuesID, ebmlElement(mkvCuePointID,
+		ebmlUint(mkvCueTimeID, 2500),
+		ebmlElement(mkvCueTrackPosID,
+			ebmlUint(mkvCueTrackID, 2),
+			ebmlUint(mkvCueClusterPos, subtitlePos),
+			ebmlUint(mkvCueRelativePos, uint64(len(timecode)+len(videoBlock))),
+		),
+	))
+	cuesPos := subtitlePos + uint64(len(subtitle))
+
+	header := ebmlElement(ebmlHeaderID, ebmlString(ebmlDocTypeID, "matroska"))
+	segment := ebmlElement(mkvSegmentID, seekHead(cuesPos), info, tracks, video, subtitle, cues)
+	data := append(header, segment...)
+
+	recorder := &offsetRecorder{r: bytes.NewReader(data)}
+	content, _, err := ExtractMKVSubtitle(MKVSource{ReaderAt: recorder, Size: int64(len(data))}, 2)
+	if err != nil {
+		t.Fatalf("ExtractMKVSubtitle returned error: %v", err)
+	}
+
+	want := "WEBVTT\n\n1\n00:00:02.500 --> 00:00:03.500\nIndexed line\n"
+	if content != want {
+		t.Fatalf("unexpected VTT content: %q", content)
+	}
+
+	segmentData := int64(len(data) - len(segment) + 12)
+	videoStart := segmentData + int64(head)
+	for _, off := range recorder.offsets {
+		if off >= videoStart && off < videoStart+int64(len(video)) {
+			t.Fatalf("expected the unindexed video cluster to be skipped, read offset %d", off)
+		}
+	}
+}
+
+func TestOpenRemoteMKV_CapsRangeReads(t *testing.T) {
+	data := bytes.Repeat([]byte{0}, 3*remoteChunkSize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "episode.mkv", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	src, err := OpenRemoteMKV(server.URL+"/episode.mkv", "")
+	if err != nil {
+		t.Fatalf("OpenRemoteMKV returned error: %v", err)
+	}
+	src.ReaderAt.(*rangeReader).limit = 2 * remoteChunkSize
+
+	buf := make([]byte, 16)
+	if _, err := src.ReadAt(buf, remoteChunkSize); err != nil {
+		t.Fatalf("expected a read within the limit to succeed: %v", err)
+	}
+	if _, err := src.ReadAt(buf, 2*remoteChunkSize); err == nil || !strings.Contains(err.Error(), "exceed") {
+		t.Fatalf("expected a read past the limit to fail, got %v", err)
+	}
+}
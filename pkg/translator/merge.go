@@ -0,0 +1,205 @@
+package translator
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A sentence is followed across at most maxMergedCues cues separated by gaps of up to maxMergeGap.
+const (
+	maxMergedCues = 3
+	maxMergeGap   = 1500 * time.Millisecond
+)
+
+const sentenceTerminals = ".!?…。！？♪"
+
+// maxPunctSnap is how many characters a split between characters may move to land after punctuation.
+const maxPunctSnap = 3
+
+// groupSentenceCues groups consecutive cue batches that carry one sentence, so each group is translated as a unit.
+func groupSentenceCues(batches []vttCueBatch) [][]vttCueBatch {
+	var groups [][]vttCueBatch
+	for i, batch := range batches {
+		if n := len(groups); n > 0 && i > 0 {
+			last := groups[n-1]
+			if len(last) < maxMergedCues && continuesSentence(batches[i-1], batch) {
+				groups[n-1] = append(last, batch)
+				continue
+			}
+		}
+		groups = append(groups, []vttCueBatch{batch})
+	}
+	return groups
+}
+
+// continuesSentence reports whether next picks up the sentence left open by cur.
+// Cues with inline markup are never merged, since their placeholders are numbered per cue, and neither are
+// lyric cues, whose lines are sung one by one.
+func continuesSentence(cur, next vttCueBatch) bool {
+	if cur.markup != nil || next.markup != nil || cur.lyric || next.lyric {
+		return false
+	}
+
+	gap := next.cue.Start - cur.cue.End
+	if gap < 0 || gap > maxMergeGap {
+		return false
+	}
+
+	text := strings.TrimRight(strings.TrimSpace(cur.originalText), "\"'”’)]")
+	if text == "" {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(text)
+	if strings.ContainsRune(sentenceTerminals, last) {
+		return false
+	}
+
+	// A leading dash or bracket starts a new speaker or sound annotation.
+	nextText := strings.TrimSpace(next.originalText)
+	return nextText != "" && !strings.HasPrefix(nextText, "-") && !strings.HasPrefix(nextText, "[") && !strings.HasPrefix(nextText, "(")
+}
+
+// sentenceGroupText joins the source text of a group for translation.
+func sentenceGroupText(group []vttCueBatch) string {
+	parts := make([]string, 0, len(group))
+	for _, batch := range group {
+		parts = append(parts, batch.sourceText)
+	}
+	return strings.Join(parts, " ")
+}
+
+// redistributeTranslation splits translated text into len(group) parts, sized in proportion to each cue's
+// original text length. It splits at word boundaries, and between characters when the translation has fewer words
+// than the group has cues, as with Japanese, Chinese or Thai. It reports false when even that would leave a cue
+// empty, and the group's cues are then translated one by one.
+func redistributeTranslation(translated string, group []vttCueBatch) ([]string, bool) {
+	words := strings.Fields(SingleLine(translated))
+	if len(group) == 1 {
+		return []string{strings.Join(words, " ")}, true
+	}
+
+	weights := make([]int, len(group))
+	for i, batch := range group {
+		weights[i] = utf8.RuneCountInString(batch.originalText)
+	}
+	if len(words) >= len(group) {
+		return splitUnits(words, " ", weights), true
+	}
+
+	clusters := graphemeClusters(strings.Join(words, " "))
+	if len(clusters) < len(group) {
+		return nil, false
+	}
+	parts := splitUnits(clusters, "", weights)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts, true
+}
+
+// splitUnits joins units with sep into len(weights) non-empty parts sized in proportion to weights.
+// A part never starts with closing punctuation.
+func splitUnits(units []string, sep string, weights []int) []string {
+	totalWeight := 0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		totalWeight = 1
+	}
+	totalChars := utf8.RuneCountInString(strings.Join(units, sep))
+	unitLen := func(unit string) int {
+		return utf8.RuneCountInString(unit) + len(sep)
+	}
+
+	parts := make([]string, len(weights))
+	pos := 0
+	consumed := 0
+	cumulative := 0
+	for i := range weights {
+		if i == len(weights)-1 {
+			parts[i] = strings.Join(units[pos:], sep)
+			break
+		}
+
+		cumulative += weights[i]
+		target := totalChars * cumulative / totalWeight
+		// Leave at least one unit for every remaining cue.
+		maxEnd := len(units) - (len(weights) - 1 - i)
+
+		end := pos
+		for end < maxEnd {
+			length := unitLen(units[end])
+			// Stop before a unit that would overshoot the target by more than half its length.
+			if end > pos && consumed+length/2 > target {
+				break
+			}
+			consumed += length
+			end++
+		}
+		for end < maxEnd && isClosingPunct(units[end]) {
+			consumed += unitLen(units[end])
+			end++
+		}
+		// Between characters, a nearby break after punctuation reads better than the exact proportion.
+		if sep == "" {
+			if snapped := punctBreakNear(units, pos, end, maxEnd); snapped != end {
+				for ; end < snapped; end++ {
+					consumed += unitLen(units[end])
+				}
+				for ; end > snapped; end-- {
+					consumed -= unitLen(units[end-1])
+				}
+			}
+		}
+
+		parts[i] = strings.Join(units[pos:end], sep)
+		pos = end
+	}
+
+	return parts
+}
+
+// punctBreakNear returns the break closest to end, within maxPunctSnap units, that follows closing punctuation,
+// or end when there is none.
+func punctBreakNear(units []string, pos, end, maxEnd int) int {
+	for distance := 0; distance <= maxPunctSnap; distance++ {
+		for _, k := range []int{end - distance, end + distance} {
+			if k > pos && k <= maxEnd && isClosingPunct(units[k-1]) {
+				return k
+			}
+		}
+	}
+	return end
+}
+
+// graphemeClusters splits text into user-perceived characters: combining marks, joiners and variation selectors
+// stay with the character they modify, and spaces with the character before them.
+func graphemeClusters(text string) []string {
+	var clusters []string
+	joined := false
+	for i, r := range text {
+		size := utf8.RuneLen(r)
+		attached := unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) || unicode.Is(unicode.Variation_Selector, r) ||
+			r == '\u200d' || unicode.IsSpace(r) || joined
+		if attached && len(clusters) > 0 {
+			clusters[len(clusters)-1] += text[i : i+size]
+		} else {
+			clusters = append(clusters, text[i:i+size])
+		}
+		joined = r == '\u200d'
+	}
+	return clusters
+}
+
+// isClosingPunct reports whether unit is made of punctuation that ends a phrase, such as "、", "。" or ")".
+func isClosingPunct(unit string) bool {
+	for _, r := range strings.TrimSpace(unit) {
+		if !unicode.In(r, unicode.Pe, unicode.Pf, unicode.Po) || strings.ContainsRune("¡¿\"'#&*@", r) {
+			return false
+		}
+	}
+	return strings.TrimSpace(unit) != ""
+}
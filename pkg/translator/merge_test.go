@@ -0,0 +1,135 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupSentenceCues_MergesOpenSentencesWithinGap(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"If you really want to know",
+		"",
+		"00:00:02.200 --> 00:00:03.500",
+		"what happened that night,",
+		"",
+		"00:00:03.600 --> 00:00:05.000",
+		"ask your brother.",
+		"",
+		"00:00:05.100 --> 00:00:06.000",
+		"Fine",
+		"",
+		"00:00:09.000 --> 00:00:10.000",
+		"I will.",
+		"",
+		"00:00:10.100 --> 00:00:11.000",
+		"<i>Wait for",
+		"",
+		"00:00:11.100 --> 00:00:12.000",
+		"me</i>",
+	)
+
+	groups := groupSentenceCues(collectVTTCueBatches(doc, nil, Options{}, nil))
+
+	sizes := make([]int, 0, len(groups))
+	for _, group := range groups {
+		sizes = append(sizes, len(group))
+	}
+	// "Fine" has no terminal punctuation but the gap to "I will." is too long; markup cues stay alone.
+	want := []int{3, 1, 1, 1, 1}
+	if len(sizes) != len(want) {
+		t.Fatalf("unexpected group sizes: %v", sizes)
+	}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Fatalf("unexpected group sizes: got %v want %v", sizes, want)
+		}
+	}
+
+	if got := sentenceGroupText(groups[0]); got != "If you really want to know what happened that night, ask your brother." {
+		t.Fatalf("unexpected merged text: %q", got)
+	}
+}
+
+func TestGroupSentenceCues_DashStartsNewSpeaker(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"Where are you going",
+		"",
+		"00:00:02.100 --> 00:00:03.000",
+		"- Home.",
+	)
+
+	if groups := groupSentenceCues(collectVTTCueBatches(doc, nil, Options{}, nil)); len(groups) != 2 {
+		t.Fatalf("expected dash to start a new group, got %d groups", len(groups))
+	}
+}
+
+func TestRedistributeTranslation_FollowsOriginalLengths(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"If you really want to know",
+		"",
+		"00:00:02.200 --> 00:00:03.500",
+		"what happened that night,",
+		"",
+		"00:00:03.600 --> 00:00:05.000",
+		"ask your brother.",
+	)
+	group := collectVTTCueBatches(doc, nil, Options{}, nil)
+
+	parts, _ := redistributeTranslation("Kalau kamu benar-benar ingin tahu apa yang terjadi malam itu, tanyakan pada kakakmu.", group)
+
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %#v", parts)
+	}
+	if strings.Join(parts, " ") != "Kalau kamu benar-benar ingin tahu apa yang terjadi malam itu, tanyakan pada kakakmu." {
+		t.Fatalf("redistribution must keep every word in order: %#v", parts)
+	}
+	if parts[0] != "Kalau kamu benar-benar ingin tahu" || parts[2] != "tanyakan pada kakakmu." {
+		t.Fatalf("unexpected split points: %#v", parts)
+	}
+}
+
+func TestRedistributeTranslation_LeavesAWordForEveryCue(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"A very long opening part of the sentence that",
+		"",
+		"00:00:02.200 --> 00:00:03.500",
+		"ends",
+	)
+
+	parts, _ := redistributeTranslation("Satu dua", collectVTTCueBatches(doc, nil, Options{}, nil))
+	if parts[0] != "Satu" || parts[1] != "dua" {
+		t.Fatalf("expected one word per cue, got %#v", parts)
+	}
+}
+
+func TestRedistributeTranslation_SplitsJapaneseByCharacter(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"If you really want to know",
+		"",
+		"00:00:02.200 --> 00:00:03.500",
+		"ask your brother.",
+	)
+	group := collectVTTCueBatches(doc, nil, Options{}, nil)
+
+	parts, ok := redistributeTranslation("本当に知りたいなら、お兄さんに聞いて。", group)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %#v", parts)
+	}
+	if parts[0] == "" || parts[1] == "" || parts[0]+parts[1] != "本当に知りたいなら、お兄さんに聞いて。" {
+		t.Fatalf("redistribution must keep every character in order: %#v", parts)
+	}
+	if parts[0] != "本当に知りたいなら、" {
+		t.Fatalf("unexpected split point: %#v", parts)
+	}
+
+	if _, ok := redistributeTranslation("え", group); ok {
+		t.Fatalf("expected a translation shorter than the group to be refused")
+	}
+}
@@ -0,0 +1,169 @@
+package translator
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output modes for translated subtitles.
+const (
+	OutputTranslated = "translated"
+	OutputBilingual  = "bilingual"
+	OutputRomanized  = "romanized"
+)
+
+// Placement of the romanized line in romanized output.
+const (
+	RomanizedUnderTranslation = "translation"
+	RomanizedUnderOriginal    = "original"
+)
+
+// Line order for bilingual output.
+const (
+	BilingualOriginalFirst    = "original_first"
+	BilingualTranslationFirst = "translation_first"
+)
+
+// Handling of cues over the line or word limit.
+const (
+	LongCuesDrop  = "drop"
+	LongCuesSplit = "split"
+)
+
+const defaultOriginalClass = "original"
+
+// Options controls how translated cue text is written back to the subtitle.
+type Options struct {
+	Output         string `json:"output,omitempty"`
+	BilingualOrder string `json:"bilingual_order,omitempty"`
+	OriginalClass  string `json:"original_class,omitempty"`
+	// RomanizedUnder places the romanized line of romanized output under the translation (default) or under the
+	// original line, which is then included as in bilingual output.
+	RomanizedUnder string `json:"romanized_under,omitempty"`
+	LongCues       string `json:"long_cues,omitempty"`
+	// SDH selects how hearing-impaired annotations are handled: translate (default), keep or strip.
+	SDH string `json:"sdh,omitempty"`
+	// Lyrics selects how song lyric cues are handled: translate (default), original or romanized.
+	Lyrics string `json:"lyrics,omitempty"`
+	// MaxCPS enables reading-speed enforcement at that many characters per second; zero disables it.
+	MaxCPS float64 `json:"max_cps,omitempty"`
+	// MaxExtensionMS caps how far an end time may be pushed into the following gap (default 1500).
+	MaxExtensionMS int `json:"max_extension_ms,omitempty"`
+	// Locks are human-corrected cues kept as they are on refresh. They do not select a variant and are not part
+	// of the cache key.
+	Locks []CueLock `json:"-"`
+	// Reuse are earlier translations of cues whose source did not change, kept on refresh. Like Locks, they are not
+	// part of the cache key.
+	Reuse []CueLock `json:"-"`
+}
+
+// Normalized fills defaults so equal requests produce equal options.
+func (o Options) Normalized() Options {
+	o.Output = strings.ToLower(strings.TrimSpace(o.Output))
+	if o.Output == "" {
+		o.Output = OutputTranslated
+	}
+	o.LongCues = strings.ToLower(strings.TrimSpace(o.LongCues))
+	if o.LongCues == "" {
+		o.LongCues = LongCuesDrop
+	}
+	o.SDH = strings.ToLower(strings.TrimSpace(o.SDH))
+	if o.SDH == "" {
+		o.SDH = SDHTranslate
+	}
+	o.Lyrics = strings.ToLower(strings.TrimSpace(o.Lyrics))
+	if o.Lyrics == "" {
+		o.Lyrics = LyricsTranslate
+	}
+	if o.MaxCPS <= 0 {
+		o.MaxCPS = 0
+		o.MaxExtensionMS = 0
+	} else if o.MaxExtensionMS <= 0 {
+		o.MaxExtensionMS = int(defaultMaxExtension / time.Millisecond)
+	}
+
+	if o.Output == OutputRomanized {
+		o.RomanizedUnder = strings.ToLower(strings.TrimSpace(o.RomanizedUnder))
+		if o.RomanizedUnder == "" {
+			o.RomanizedUnder = RomanizedUnderTranslation
+		}
+	} else {
+		o.RomanizedUnder = ""
+	}
+
+	if o.Output == OutputBilingual {
+		o.BilingualOrder = strings.ToLower(strings.TrimSpace(o.BilingualOrder))
+		if o.BilingualOrder == "" {
+			o.BilingualOrder = BilingualOriginalFirst
+		}
+	} else {
+		o.BilingualOrder = ""
+	}
+
+	if o.Output != OutputBilingual && o.RomanizedUnder != RomanizedUnderOriginal {
+		o.OriginalClass = ""
+		return o
+	}
+	o.OriginalClass = strings.TrimSpace(o.OriginalClass)
+	if o.OriginalClass == "" {
+		o.OriginalClass = defaultOriginalClass
+	}
+	return o
+}
+
+// Variant names the stored variant produced by these options.
+func (o Options) Variant() string {
+	return o.Normalized().Output
+}
+
+// CacheKey identifies non-default options; it is empty for plain translations so existing cache keys stay valid.
+func (o Options) CacheKey() string {
+	n := o.Normalized()
+
+	var parts []string
+	switch n.Output {
+	case OutputBilingual:
+		parts = append(parts, n.Output, n.BilingualOrder, n.OriginalClass)
+	case OutputRomanized:
+		parts = append(parts, n.Output, "romanized_under="+n.RomanizedUnder)
+		if n.OriginalClass != "" {
+			parts = append(parts, n.OriginalClass)
+		}
+	}
+	if n.LongCues != LongCuesDrop {
+		parts = append(parts, "long_cues="+n.LongCues)
+	}
+	if n.SDH != SDHTranslate {
+		parts = append(parts, "sdh="+n.SDH)
+	}
+	if n.Lyrics != LyricsTranslate {
+		parts = append(parts, "lyrics="+n.Lyrics)
+	}
+	if n.MaxCPS > 0 {
+		parts = append(parts, "max_cps="+strconv.FormatFloat(n.MaxCPS, 'f', -1, 64), "max_extension_ms="+strconv.Itoa(n.MaxExtensionMS))
+	}
+	return strings.Join(parts, "|")
+}
+
+// bilingualCueLines combines original and translated lines, wrapping the original in a styling class.
+func bilingualCueLines(originalText string, translatedLines []string, opts Options) []string {
+	opts = opts.Normalized()
+
+	original := strings.TrimSpace(SingleLine(originalText))
+	if original == "" {
+		return translatedLines
+	}
+	originalLine := "<c." + opts.OriginalClass + ">" + original + "</c>"
+
+	if opts.BilingualOrder == BilingualTranslationFirst {
+		return append(append([]string{}, translatedLines...), originalLine)
+	}
+	return append([]string{originalLine}, translatedLines...)
+}
+
+// isClassSpanLine reports whether a whole line is wrapped in a WebVTT class span such as <c.original>...</c>.
+func isClassSpanLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "<c.") && strings.HasSuffix(trimmed, "</c>")
+}
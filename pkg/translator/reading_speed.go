@@ -0,0 +1,211 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxExtension = 1500 * time.Millisecond
+	// minCueGap is kept free before the next cue when an end time is extended.
+	minCueGap = 80 * time.Millisecond
+)
+
+// languageProfile lists what the condensation pass may remove or shorten for a language. Fillers are hesitation
+// sounds dropped wherever they stand; discourse fillers are ordinary words that only count as filler when set off
+// by commas, at the start of a line or between two commas.
+type languageProfile struct {
+	fillers          []string
+	discourseFillers []string
+	synonyms         [][2]string
+}
+
+var languageProfiles = map[string]languageProfile{
+	"id": {
+		fillers:          []string{"eh", "em", "hmm", "anu"},
+		discourseFillers: []string{"sebenarnya", "tentu saja"},
+		synonyms: [][2]string{
+			{"tetapi", "tapi"},
+			{"bagaimana", "gimana"},
+			{"mengapa", "kenapa"},
+			{"sudah", "udah"},
+			{"saja", "aja"},
+			{"begitu", "gitu"},
+			{"seperti", "kayak"},
+			{"memang", "emang"},
+			{"benar-benar", "sungguh"},
+			{"sekarang juga", "sekarang"},
+		},
+	},
+	"en": {
+		fillers:          []string{"um", "uh"},
+		discourseFillers: []string{"well", "you know", "I mean", "like"},
+		synonyms: [][2]string{
+			{"going to", "gonna"},
+			{"want to", "wanna"},
+			{"do not", "don't"},
+			{"cannot", "can't"},
+			{"I am", "I'm"},
+			{"it is", "it's"},
+			{"that is", "that's"},
+		},
+	},
+}
+
+type condenseRule struct {
+	re          *regexp.Regexp
+	replacement string
+	// lineStart rules only apply to the text that opens a line.
+	lineStart bool
+}
+
+var condenseRules = compileCondenseRules()
+
+func compileCondenseRules() map[string][]condenseRule {
+	rules := make(map[string][]condenseRule, len(languageProfiles))
+	for lang, profile := range languageProfiles {
+		for _, filler := range profile.fillers {
+			// Fillers go together with the comma and space that set them off.
+			re := regexp.MustCompile(`(?i)(^|[\s,])` + regexp.QuoteMeta(filler) + `(,\s*|\s+|$)`)
+			rules[lang] = append(rules[lang], condenseRule{re: re, replacement: "$1"})
+		}
+		for _, filler := range profile.discourseFillers {
+			quoted := regexp.QuoteMeta(filler)
+			rules[lang] = append(rules[lang],
+				condenseRule{re: regexp.MustCompile(`(?i)^\s*` + quoted + `,\s*`), lineStart: true},
+				condenseRule{re: regexp.MustCompile(`(?i),\s*` + quoted + `,`)},
+			)
+		}
+		for _, pair := range profile.synonyms {
+			re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(pair[0]) + `\b`)
+			rules[lang] = append(rules[lang], condenseRule{re: re, replacement: pair[1]})
+		}
+	}
+	return rules
+}
+
+// cueCPS is the reading speed of a cue in characters per second. Markup, line breaks and the original line
+// of bilingual cues are not counted.
+func cueCPS(cue *Cue) float64 {
+	seconds := cue.Duration().Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(cueReadingChars(cue)) / seconds
+}
+
+func cueReadingChars(cue *Cue) int {
+	chars := 0
+	for _, line := range cue.Lines {
+		if isClassSpanLine(line.String()) {
+			continue
+		}
+		chars += utf8.RuneCountInString(strings.TrimSpace(line.Plain()))
+	}
+	return chars
+}
+
+// enforceReadingSpeed brings cues under opts.MaxCPS: first by extending end times into the gap before
+// the next cue, then by condensing the text. Cues still too fast are reported.
+func enforceReadingSpeed(doc *Document, targetLang string, opts Options, index map[*Cue]int, report *Report) {
+	maxExtension := time.Duration(opts.MaxExtensionMS) * time.Millisecond
+
+	for i, cue := range doc.Cues {
+		if cueCPS(cue) <= opts.MaxCPS {
+			continue
+		}
+
+		needed := time.Duration(float64(cueReadingChars(cue)) / opts.MaxCPS * float64(time.Second))
+		limit := cue.End + maxExtension
+		if i+1 < len(doc.Cues) {
+			if next := doc.Cues[i+1].Start - minCueGap; next < limit {
+				limit = next
+			}
+		}
+		if end := cue.Start + needed.Round(time.Millisecond); end > cue.End {
+			if end > limit {
+				end = limit
+			}
+			if end > cue.End {
+				cue.End = end
+			}
+		}
+
+		if cueCPS(cue) > opts.MaxCPS {
+			condenseCue(cue, targetLang)
+		}
+
+		if cps := cueCPS(cue); cps > opts.MaxCPS {
+			note := newCueNote(index[cue], cue, ReasonTooFast)
+			note.CPS = float64(int(cps*10+0.5)) / 10
+			report.TooFast = append(report.TooFast, note)
+		}
+	}
+}
+
+// condenseCue drops fillers and applies shorter synonyms from the target language profile to the
+// text runs of a cue, leaving markup and bilingual original lines untouched.
+func condenseCue(cue *Cue, targetLang string) {
+	rules := condenseRules[primaryLanguage(targetLang)]
+	if len(rules) == 0 {
+		return
+	}
+
+	for _, line := range cue.Lines {
+		if isClassSpanLine(line.String()) {
+			continue
+		}
+		startsUpper := startsWithUpper(line.Plain())
+		lineStart := true
+		for i, run := range line {
+			if run.Tag {
+				continue
+			}
+			text := run.Text
+			for _, rule := range rules {
+				if rule.lineStart && !lineStart {
+					continue
+				}
+				text = rule.re.ReplaceAllStringFunc(text, func(match string) string {
+					replaced := rule.re.ReplaceAllString(match, rule.replacement)
+					return matchLeadingCase(match, replaced)
+				})
+			}
+			line[i].Text = horizontalWhitespaceRe.ReplaceAllString(text, " ")
+			lineStart = lineStart && strings.TrimSpace(line[i].Text) == ""
+		}
+
+		// A line that began with a capitalised filler keeps its capital on the new first word.
+		if len(line) > 0 && !line[0].Tag {
+			line[0].Text = strings.TrimLeft(line[0].Text, " ,")
+			if startsUpper {
+				line[0].Text = capitalizeFirst(line[0].Text)
+			}
+		}
+	}
+}
+
+// matchLeadingCase keeps a capitalised source word capitalised after replacement.
+func matchLeadingCase(original, replaced string) string {
+	if !startsWithUpper(original) {
+		return replaced
+	}
+	prefix := replaced[:len(replaced)-len(strings.TrimLeft(replaced, " ,"))]
+	return prefix + capitalizeFirst(strings.TrimLeft(replaced, " ,"))
+}
+
+func startsWithUpper(s string) bool {
+	first, _ := utf8.DecodeRuneInString(strings.TrimLeft(s, " ,"))
+	return unicode.IsUpper(first)
+}
+
+func capitalizeFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,109 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnforceReadingSpeed_ExtendsIntoGap(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"Twenty characters!!!",
+		"",
+		"00:00:04.000 --> 00:00:05.000",
+		"Next",
+	)
+	report := &Report{}
+
+	enforceReadingSpeed(doc, "en", Options{MaxCPS: 10, MaxExtensionMS: 1500}.Normalized(), cueIndex(doc), report)
+
+	if got := doc.Cues[0].End; got != 3*time.Second {
+		t.Fatalf("expected end extended to 3s, got %v", got)
+	}
+	if len(report.TooFast) != 0 {
+		t.Fatalf("expected no too-fast cues, got %+v", report.TooFast)
+	}
+}
+
+func TestEnforceReadingSpeed_ExtensionStopsBeforeNextCue(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"Twenty characters!!!",
+		"",
+		"00:00:02.500 --> 00:00:05.000",
+		"Next",
+	)
+	report := &Report{}
+
+	enforceReadingSpeed(doc, "xx", Options{MaxCPS: 10}.Normalized(), cueIndex(doc), report)
+
+	if got := doc.Cues[0].End; got != 2500*time.Millisecond-minCueGap {
+		t.Fatalf("expected end to stop before the next cue, got %v", got)
+	}
+	if len(report.TooFast) != 1 || report.TooFast[0].Index != 1 || report.TooFast[0].Reason != ReasonTooFast {
+		t.Fatalf("expected the first cue reported as too fast, got %+v", report.TooFast)
+	}
+	if report.TooFast[0].CPS != 14.1 {
+		t.Fatalf("expected remaining CPS 14.1, got %v", report.TooFast[0].CPS)
+	}
+}
+
+func TestEnforceReadingSpeed_CondensesText(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:03.000",
+		"Sebenarnya, aku sudah bilang",
+		"tetapi kamu begitu keras kepala.",
+		"",
+		"00:00:03.100 --> 00:00:05.000",
+		"Next",
+	)
+	report := &Report{}
+
+	enforceReadingSpeed(doc, "id", Options{MaxCPS: 25, MaxExtensionMS: 500}.Normalized(), cueIndex(doc), report)
+
+	want := "Aku udah bilang\ntapi kamu gitu keras kepala."
+	if got := doc.Cues[0].Text(); got != want {
+		t.Fatalf("unexpected condensed text:\n got %q\nwant %q", got, want)
+	}
+	if len(report.TooFast) != 0 {
+		t.Fatalf("expected condensed cue under the limit, got %+v", report.TooFast)
+	}
+}
+
+func TestCondenseCue_KeepsMarkupAndOriginalLine(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"<c.original>Well, I am going to go.</c>",
+		"<i>Well, I am going to go.</i>",
+	)
+
+	condenseCue(doc.Cues[0], "en")
+
+	want := "<c.original>Well, I am going to go.</c>\n<i>I'm gonna go.</i>"
+	if got := doc.Cues[0].Text(); got != want {
+		t.Fatalf("unexpected condensed text:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestCondenseCue_DropsDiscourseFillersOnlyBetweenCommas(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want string
+	}{
+		{"en", "I like you as well.", "I like you as well."},
+		{"en", "Well, it was, like, huge.", "It was huge."},
+		{"en", "I mean it, um, really.", "I mean it, really."},
+		{"en", "<i>So</i> well, fine.", "<i>So</i> well, fine."},
+		{"id", "Aku sebenarnya tidak tahu.", "Aku sebenarnya tidak tahu."},
+		{"id", "Tentu saja, aku datang, sebenarnya, kemarin.", "Aku datang kemarin."},
+	}
+
+	for _, tt := range tests {
+		doc := mustParseVTT(t, "00:00:01.000 --> 00:00:02.000", tt.text)
+		condenseCue(doc.Cues[0], tt.lang)
+		if got := doc.Cues[0].Text(); got != tt.want {
+			t.Fatalf("%s %q: got %q want %q", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package translator
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// remoteFileClient allows long downloads when a server ignores Range requests.
+var remoteFileClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+const (
+	remoteChunkSize       = 256 << 10
+	remoteCacheChunks     = 64
+	maxRemoteDownloadSize = 512 << 20
+)
+
+// rangeReader reads a remote file through HTTP Range requests, caching recently used chunks.
+// It stops once limit bytes have been downloaded, as a file without a cue index is read cluster by cluster.
+type rangeReader struct {
+	url     string
+	referer string
+	size    int64
+	limit   int64
+
+	mu      sync.Mutex
+	chunks  map[int64]*list.Element
+	lru     *list.List
+	fetched int64
+}
+
+type rangeChunk struct {
+	index int64
+	data  []byte
+}
+
+// OpenRemoteMKV opens a remote Matroska file. Servers supporting Range requests are read lazily in 256 KB chunks:
+// headers and the track list, then the clusters the cue index lists for the subtitle track. Files without a cue
+// index are scanned cluster by cluster, which touches most of the file. Either way at most 512 MB is downloaded.
+func OpenRemoteMKV(url, referer string) (MKVSource, error) {
+	reader := &rangeReader{
+		url:     url,
+		referer: referer,
+		limit:   maxRemoteDownloadSize,
+		chunks:  make(map[int64]*list.Element),
+		lru:     list.New(),
+	}
+
+	resp, err := reader.request(0, remoteChunkSize-1)
+	if err != nil {
+		return MKVSource{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		size, err := parseContentRangeSize(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return MKVSource{}, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return MKVSource{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		reader.size = size
+		reader.fetched = int64(len(data))
+		reader.store(0, data)
+		return MKVSource{ReaderAt: reader, Size: size}, nil
+	case http.StatusOK:
+		// No range support: fall back to downloading the whole file.
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteDownloadSize+1))
+		if err != nil {
+			return MKVSource{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		if len(data) > maxRemoteDownloadSize {
+			return MKVSource{}, fmt.Errorf("remote file exceeds %d bytes and server does not support range requests", maxRemoteDownloadSize)
+		}
+		return MKVSource{ReaderAt: bytes.NewReader(data), Size: int64(len(data))}, nil
+	default:
+		return MKVSource{}, fmt.Errorf("failed to fetch file: status %d", resp.StatusCode)
+	}
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		chunk, err := r.chunk(pos / remoteChunkSize)
+		if err != nil {
+			return n, err
+		}
+
+		start := int(pos % remoteChunkSize)
+		if start >= len(chunk) {
+			return n, io.ErrUnexpectedEOF
+		}
+		n += copy(p[n:], chunk[start:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *rangeReader) chunk(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if el, ok := r.chunks[index]; ok {
+		r.lru.MoveToFront(el)
+		data := el.Value.(*rangeChunk).data
+		r.mu.Unlock()
+		return data, nil
+	}
+	fetched := r.fetched
+	r.mu.Unlock()
+
+	start := index * remoteChunkSize
+	end := start + remoteChunkSize - 1
+	if end >= r.size {
+		end = r.size - 1
+	}
+	if fetched+end-start+1 > r.limit {
+		return nil, fmt.Errorf("reading the remote file would exceed %d bytes", r.limit)
+	}
+
+	resp, err := r.request(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("range request failed: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read range response: %w", err)
+	}
+
+	r.mu.Lock()
+	r.fetched += int64(len(data))
+	r.mu.Unlock()
+
+	r.store(index, data)
+	return data, nil
+}
+
+func (r *rangeReader) store(index int64, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.chunks[index] = r.lru.PushFront(&rangeChunk{index: index, data: data})
+	for r.lru.Len() > remoteCacheChunks {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.chunks, oldest.Value.(*rangeChunk).index)
+	}
+}
+
+func (r *rangeReader) request(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:146.0) Gecko/20100101 Firefox/146.0")
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if r.referer != "" {
+		req.Header.Set("Referer", r.referer)
+	}
+
+	resp, err := remoteFileClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	return resp, nil
+}
+
+func parseContentRangeSize(header string) (int64, error) {
+	idx := strings.LastIndex(header, "/")
+	if idx == -1 || header[idx+1:] == "*" {
+		return 0, fmt.Errorf("server did not report file size in Content-Range")
+	}
+	size, err := strconv.ParseInt(header[idx+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	return size, nil
+}
@@ -0,0 +1,60 @@
+package translator
+
+import (
+	"sort"
+	"time"
+)
+
+// Report describes what the translation pipeline changed beyond plain text replacement.
+type Report struct {
+	Split   []CueNote `json:"split,omitempty"`
+	Dropped []CueNote `json:"dropped,omitempty"`
+	TooFast []CueNote `json:"too_fast,omitempty"`
+	// Lyrics lists the cues detected as song lyrics, with the detection rule as Reason.
+	Lyrics []CueNote `json:"lyrics,omitempty"`
+	// Locked lists the human-corrected cues kept instead of translated.
+	Locked []CueNote `json:"locked,omitempty"`
+	// Conflicts lists locked cues whose source cue changed or disappeared since the correction.
+	Conflicts []CueNote `json:"conflicts,omitempty"`
+	// Locks are the cue locks as matched on this source, to be stored for the next refresh.
+	Locks []CueLock `json:"-"`
+	// Reused is the number of cues whose earlier translation was kept because their source did not change.
+	Reused int `json:"reused,omitempty"`
+}
+
+// CueNote identifies one source cue (1-based Index in the parsed document) and what happened to it.
+type CueNote struct {
+	Index  int           `json:"index"`
+	ID     string        `json:"id,omitempty"`
+	Start  time.Duration `json:"start"`
+	End    time.Duration `json:"end"`
+	Reason string        `json:"reason,omitempty"`
+	// Parts is the number of cues a split cue became.
+	Parts int `json:"parts,omitempty"`
+	// CPS is the reading speed, in characters per second, a cue was left with.
+	CPS float64 `json:"cps,omitempty"`
+}
+
+// Report reasons.
+const (
+	ReasonTooLong          = "too_long"
+	ReasonEmptyTranslation = "empty_translation"
+	ReasonTooFast          = "too_fast"
+	ReasonSDHOnly          = "sdh_only"
+)
+
+func newCueNote(index int, cue *Cue, reason string) CueNote {
+	return CueNote{Index: index, ID: cue.ID, Start: cue.Start, End: cue.End, Reason: reason}
+}
+
+// Empty reports whether nothing was split, dropped, left reading too fast, detected as lyrics, locked or reused.
+func (r *Report) Empty() bool {
+	return r == nil || (len(r.Split) == 0 && len(r.Dropped) == 0 && len(r.TooFast) == 0 && len(r.Lyrics) == 0 &&
+		len(r.Locked) == 0 && len(r.Conflicts) == 0 && r.Reused == 0)
+}
+
+func (r *Report) sort() {
+	for _, notes := range [][]CueNote{r.Split, r.Dropped, r.TooFast, r.Lyrics, r.Locked, r.Conflicts} {
+		sort.SliceStable(notes, func(i, j int) bool { return notes[i].Index < notes[j].Index })
+	}
+}
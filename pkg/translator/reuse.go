@@ -0,0 +1,72 @@
+package translator
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReuseTranslations carries the translations of unchanged source cues over to a changed source, so a refresh only
+// translates the cues that differ. before is the source translated earlier, translated the result, and after the
+// new source; the returned locks hold the earlier translations of the cues of after whose text did not change.
+//
+// A translated cue belongs to the source cue starting at the same time, and is only reused when no other
+// translated cue starts before the next source cue: split cues are translated again.
+func ReuseTranslations(before, after, translated *Document) []CueLock {
+	starts := make([]time.Duration, 0, len(before.Cues))
+	sources := make(map[time.Duration]int, len(before.Cues))
+	for _, cue := range before.Cues {
+		if sources[cue.Start] == 0 {
+			starts = append(starts, cue.Start)
+		}
+		sources[cue.Start]++
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	translations := make(map[time.Duration][]*Cue, len(starts))
+	for _, cue := range translated.Cues {
+		k := sort.Search(len(starts), func(k int) bool { return starts[k] > cue.Start }) - 1
+		if k >= 0 {
+			translations[starts[k]] = append(translations[starts[k]], cue)
+		}
+	}
+
+	reused := []CueLock{}
+	for _, step := range alignCues(before.Cues, after.Cues) {
+		if step.kind != "" && step.kind != CueRetimed {
+			continue
+		}
+		source := before.Cues[step.from]
+		cues := translations[source.Start]
+		if sources[source.Start] != 1 || len(cues) != 1 || cues[0].Start != source.Start {
+			continue
+		}
+		cue := after.Cues[step.to]
+		reused = append(reused, CueLock{
+			ID:     cue.ID,
+			Start:  cue.Start,
+			End:    cue.End,
+			Text:   cues[0].Text(),
+			Source: strings.TrimSpace(cue.PlainText()),
+		})
+	}
+	return reused
+}
+
+// reuseTranslations matches opts.Reuse against the source document and returns the matched cues with their earlier
+// translation. Cues already locked keep their lock.
+func reuseTranslations(doc *Document, reuse []CueLock, locked map[*Cue]string, report *Report) map[*Cue]string {
+	if len(reuse) == 0 {
+		return nil
+	}
+
+	reused := make(map[*Cue]string, len(reuse))
+	for cue, i := range matchCueLocks(doc, reuse) {
+		if _, ok := locked[cue]; ok {
+			continue
+		}
+		reused[cue] = reuse[i].Text
+	}
+	report.Reused = len(reused)
+	return reused
+}
@@ -0,0 +1,102 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReuseTranslations_KeepsUnchangedCues(t *testing.T) {
+	before := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Hello!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"How are you?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Fine, thanks",
+		"",
+		"00:00:07.000 --> 00:00:09.000",
+		"This long line was split in two",
+	)
+	translated := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.400",
+		"Halo!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"Apa kabar?",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"Baik, makasih",
+		"",
+		"00:00:07.000 --> 00:00:08.000",
+		"Baris panjang ini",
+		"",
+		"00:00:08.000 --> 00:00:09.000",
+		"dibagi dua",
+	)
+	after := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Hello!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"How are you doing?",
+		"",
+		"00:00:05.500 --> 00:00:06.500",
+		"Fine, thanks",
+		"",
+		"00:00:07.000 --> 00:00:09.000",
+		"This long line was split in two",
+	)
+
+	got := ReuseTranslations(before, after, translated)
+
+	// The modified cue and the split cue are translated again
+	want := []CueLock{
+		{Start: time.Second, End: 2 * time.Second, Text: "Halo!", Source: "Hello!"},
+		{Start: 5500 * time.Millisecond, End: 6500 * time.Millisecond, Text: "Baik, makasih", Source: "Fine, thanks"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d reused cues, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("reused cue %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTranslateDocument_ReusesTranslationsBehindLocks(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Hello!",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"How are you?",
+	)
+	reuse := []CueLock{
+		{Start: time.Second, End: 2 * time.Second, Text: "Halo!", Source: "Hello!"},
+		{Start: 3 * time.Second, End: 4 * time.Second, Text: "Apa kabar?", Source: "How are you?"},
+	}
+	locks := []CueLock{{Start: 3 * time.Second, End: 4 * time.Second, Text: "Gimana kabarnya?"}}
+
+	// Every source cue is reused or locked, so nothing is sent to the engine.
+	report, err := TranslateDocument(doc, "id", "en", Options{Locks: locks, Reuse: reuse})
+	if err != nil {
+		t.Fatalf("TranslateDocument returned error: %v", err)
+	}
+	if doc.Cues[0].Text() != "Halo!" || doc.Cues[1].Text() != "Gimana kabarnya?" {
+		t.Fatalf("expected the reused translation and the locked correction, got %q and %q", doc.Cues[0].Text(), doc.Cues[1].Text())
+	}
+	if report.Reused != 1 || len(report.Locked) != 1 {
+		t.Fatalf("expected one reused and one locked cue, got %+v", report)
+	}
+}
@@ -0,0 +1,73 @@
+package translator
+
+import "strings"
+
+const romanizedClass = "romanized"
+
+// romanizeCues transliterates the source text of cues to Latin script, only for lyric cues when lyricsOnly is set.
+// Cues whose text is already Latin, or that could not be transliterated, are left out.
+func romanizeCues(batches []vttCueBatch, sourceLang string, lyricsOnly bool) map[*Cue]string {
+	var picked []vttCueBatch
+	texts := make([]string, 0, len(batches))
+	for _, batch := range batches {
+		if lyricsOnly && !batch.lyric {
+			continue
+		}
+		picked = append(picked, batch)
+		texts = append(texts, batch.originalText)
+	}
+
+	romanized := make(map[*Cue]string, len(picked))
+	for i, text := range BatchTransliterate(texts, sourceLang) {
+		text = strings.TrimSpace(SingleLine(text))
+		if text == "" || text == strings.TrimSpace(picked[i].originalText) {
+			continue
+		}
+		romanized[picked[i].cue] = text
+	}
+	return romanized
+}
+
+func romanizedLine(romanized string) string {
+	return "<c." + romanizedClass + ">" + romanized + "</c>"
+}
+
+// applyRomanizedCue writes the translation with the romanized source under it, or the original line, the romanized
+// line and the translation when the romanized line goes under the original.
+func applyRomanizedCue(batch vttCueBatch, translated, romanized, targetLang string, opts Options) {
+	translatedLines := translatedCueLines(batch, translated, targetLang)
+	if len(translatedLines) == 0 {
+		batch.cue.SetLines(nil)
+		return
+	}
+
+	if opts.RomanizedUnder == RomanizedUnderOriginal {
+		lines := bilingualCueLines(batch.originalText, translatedLines, Options{Output: OutputBilingual, OriginalClass: opts.OriginalClass})
+		if romanized != "" && len(lines) > len(translatedLines) {
+			lines = append([]string{lines[0], romanizedLine(romanized)}, lines[1:]...)
+		}
+		batch.cue.SetLines(lines)
+		return
+	}
+
+	if romanized != "" {
+		translatedLines = append(translatedLines, romanizedLine(romanized))
+	}
+	batch.cue.SetLines(translatedLines)
+}
+
+// addRomanizedLine puts a romanized lyric directly above the translated lines of a cue.
+func addRomanizedLine(cue *Cue, romanized string, opts Options) {
+	if len(cue.Lines) == 0 {
+		return
+	}
+
+	at := 0
+	if opts.Output == OutputBilingual && opts.BilingualOrder == BilingualOriginalFirst {
+		at = 1
+	}
+	lines := make([]Line, 0, len(cue.Lines)+1)
+	lines = append(lines, cue.Lines[:at]...)
+	lines = append(lines, ParseVTTLine(romanizedLine(romanized)))
+	cue.Lines = append(lines, cue.Lines[at:]...)
+}
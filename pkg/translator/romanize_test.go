@@ -0,0 +1,35 @@
+package translator
+
+import "testing"
+
+func TestApplyRomanizedCue_UnderTranslation(t *testing.T) {
+	doc := mustParseVTT(t, "00:00:01.000 --> 00:00:02.000", "ありがとう")
+	batch := vttCueBatch{cue: doc.Cues[0], originalText: "ありがとう", sourceText: "ありがとう"}
+
+	applyRomanizedCue(batch, "Thank you", "arigatou", "en", Options{Output: OutputRomanized}.Normalized())
+
+	if got := doc.Cues[0].Text(); got != "Thank you\n<c.romanized>arigatou</c>" {
+		t.Fatalf("unexpected romanized cue: %q", got)
+	}
+}
+
+func TestApplyRomanizedCue_UnderOriginal(t *testing.T) {
+	doc := mustParseVTT(t, "00:00:01.000 --> 00:00:02.000", "안녕하세요")
+	batch := vttCueBatch{cue: doc.Cues[0], originalText: "안녕하세요", sourceText: "안녕하세요"}
+	opts := Options{Output: OutputRomanized, RomanizedUnder: RomanizedUnderOriginal}.Normalized()
+
+	applyRomanizedCue(batch, "Hello", "annyeonghaseyo", "en", opts)
+
+	want := "<c.original>안녕하세요</c>\n<c.romanized>annyeonghaseyo</c>\nHello"
+	if got := doc.Cues[0].Text(); got != want {
+		t.Fatalf("unexpected romanized cue:\n got %q\nwant %q", got, want)
+	}
+
+	doc = mustParseVTT(t, "00:00:01.000 --> 00:00:02.000", "Hello")
+	batch = vttCueBatch{cue: doc.Cues[0], originalText: "Hello", sourceText: "Hello"}
+	applyRomanizedCue(batch, "Halo", "", "id", opts)
+
+	if got := doc.Cues[0].Text(); got != "<c.original>Hello</c>\nHalo" {
+		t.Fatalf("expected no romanized line for Latin source, got %q", got)
+	}
+}
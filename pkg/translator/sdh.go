@@ -0,0 +1,85 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Handling of hearing-impaired (SDH) annotations.
+const (
+	SDHTranslate = "translate"
+	SDHKeep      = "keep"
+	SDHStrip     = "strip"
+)
+
+var (
+	// sdhSoundRe matches sound descriptions such as [door creaks] and (LAUGHS), and music spans such as ♪ music ♪.
+	// A note opening a line without a closing note marks the whole line.
+	sdhSoundRe = regexp.MustCompile(`\[[^\]\n]*\]|\([^)\n]*\)|[♪♫][^♪♫\n]*[♪♫]|^\s*[♪♫].*$`)
+	// sdhSpeakerRe matches an upper-case speaker label opening a line, after an optional dialogue dash.
+	sdhSpeakerRe = regexp.MustCompile(`^(\s*-\s*)?([A-Z][A-Z0-9'.\-]*(?: [A-Z0-9'.\-]+){0,2}):\s*`)
+)
+
+// startsWithSDHAnnotation reports whether text opens with a bracketed or parenthesised annotation.
+func startsWithSDHAnnotation(text string) bool {
+	trimmed := strings.TrimLeft(strings.TrimSpace(text), "- ")
+	loc := sdhSoundRe.FindStringIndex(trimmed)
+	return loc != nil && loc[0] == 0
+}
+
+// maskSDHAnnotations replaces the annotations in one text run with markup atoms so they are kept untranslated.
+// Speaker labels are only looked for when the run opens its line.
+func maskSDHAnnotations(text string, lineStart bool, markup *cueMarkup) string {
+	if lineStart {
+		if m := sdhSpeakerRe.FindStringSubmatchIndex(text); m != nil {
+			label := strings.TrimRight(text[m[4]:m[1]], " \t")
+			end := m[4] + len(label)
+			text = text[:m[4]] + markup.add(markupTag{text: label, kind: markupAtom, pair: -1, annotation: true}) + text[end:]
+		}
+	}
+	return sdhSoundRe.ReplaceAllStringFunc(text, func(span string) string {
+		return markup.add(markupTag{text: span, kind: markupAtom, pair: -1, annotation: true})
+	})
+}
+
+// stripSDHLine removes annotations from one line, returning false when nothing but a dialogue dash is left.
+func stripSDHLine(line Line) (Line, bool) {
+	text := line.String()
+	if m := sdhSpeakerRe.FindStringSubmatchIndex(text); m != nil {
+		text = text[:m[4]] + text[m[1]:]
+	}
+	text = sdhSoundRe.ReplaceAllString(text, "")
+	text = strings.TrimSpace(horizontalWhitespaceRe.ReplaceAllString(text, " "))
+
+	stripped := ParseVTTLine(text)
+	if plain := strings.Trim(stripped.Plain(), " -:"); plain == "" {
+		return nil, false
+	}
+	return stripped, true
+}
+
+// stripSDHAnnotations removes sound descriptions, music spans and speaker labels from every cue before translation.
+// Cues left without text are removed and reported. Cues in skip are left alone.
+func stripSDHAnnotations(doc *Document, index map[*Cue]int, report *Report, skip map[*Cue]string) {
+	kept := doc.Cues[:0]
+	for _, cue := range doc.Cues {
+		if _, ok := skip[cue]; ok {
+			kept = append(kept, cue)
+			continue
+		}
+		lines := cue.Lines[:0]
+		for _, line := range cue.Lines {
+			if stripped, ok := stripSDHLine(line); ok {
+				lines = append(lines, stripped)
+			}
+		}
+		cue.Lines = lines
+
+		if len(cue.Lines) == 0 {
+			report.Dropped = append(report.Dropped, newCueNote(index[cue], cue, ReasonSDHOnly))
+			continue
+		}
+		kept = append(kept, cue)
+	}
+	doc.Cues = kept
+}
@@ -0,0 +1,62 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripSDHAnnotations_RemovesAnnotationsAndEmptyCues(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:02.000",
+		"[door creaks]",
+		"",
+		"00:00:03.000 --> 00:00:04.000",
+		"JOHN: Who's there? (LAUGHS)",
+		"- MARY: Just me.",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"♪ music ♪",
+	)
+	report := &Report{}
+
+	stripSDHAnnotations(doc, cueIndex(doc), report, nil)
+
+	if len(doc.Cues) != 1 {
+		t.Fatalf("expected one cue left, got %d", len(doc.Cues))
+	}
+	if got := doc.Cues[0].Text(); got != "Who's there?\n- Just me." {
+		t.Fatalf("unexpected stripped text: %q", got)
+	}
+	if len(report.Dropped) != 2 || report.Dropped[0].Index != 1 || report.Dropped[1].Index != 3 || report.Dropped[0].Reason != ReasonSDHOnly {
+		t.Fatalf("unexpected dropped cues: %+v", report.Dropped)
+	}
+}
+
+func TestBuildCueBatch_KeepSDHMasksAnnotations(t *testing.T) {
+	cue := &Cue{}
+	cue.SetText("JOHN: [sighs] I'm tired.")
+
+	batch, ok := buildCueBatch(cue, true)
+	if !ok {
+		t.Fatalf("expected cue batch")
+	}
+	if batch.sourceText != "⟦0⟧ ⟦1⟧ I'm tired." {
+		t.Fatalf("unexpected masked text: %q", batch.sourceText)
+	}
+
+	lines := translatedCueLines(batch, strings.Replace(batch.sourceText, "I'm tired.", "Aku capek.", 1), "en")
+	if strings.Join(lines, "\n") != "JOHN: [sighs] Aku capek." {
+		t.Fatalf("unexpected restored text: %q", lines)
+	}
+
+	cue.SetText("(LAUGHS)")
+	if _, ok := buildCueBatch(cue, true); ok {
+		t.Fatalf("annotation-only cue should not be sent for translation")
+	}
+}
+
+func TestStripLeadingPunctuationPrefix_KeepsSoundDescriptions(t *testing.T) {
+	if got := stripLeadingPunctuationPrefix("(TERTAWA) Lucu sekali"); got != "(TERTAWA) Lucu sekali" {
+		t.Fatalf("unexpected line: %q", got)
+	}
+}
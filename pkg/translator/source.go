@@ -0,0 +1,141 @@
+package translator
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Source text encodings reported by NewSource.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// ErrSourceNotModified is returned by FetchSourceIfModified when the source did not change since it was fetched.
+var ErrSourceNotModified = errors.New("source not modified")
+
+// Source is a fetched subtitle as it was before translation, decoded to UTF-8.
+type Source struct {
+	Content string
+	// Format is the detected format, vtt or ass; HLS subtitle playlists are joined into vtt.
+	Format string
+	// Encoding is the encoding the source was fetched in.
+	Encoding string
+	// ETag and LastModified are the cache validators the source was served with, for conditional refetches.
+	ETag         string
+	LastModified string
+}
+
+// windows1252High maps bytes 0x80-0x9F of Windows-1252; the other bytes are Latin-1.
+var windows1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// FetchSource downloads a subtitle (or an HLS subtitle playlist) without translating it. format is used when the
+// content does not tell.
+func FetchSource(url, format, referer string) (*Source, error) {
+	return FetchSourceIfModified(url, format, referer, "", "")
+}
+
+// FetchSourceIfModified is FetchSource with a conditional request on the validators of an earlier fetch. It returns
+// ErrSourceNotModified when the server reports the source unchanged. Segments of an HLS playlist are always
+// fetched in full.
+func FetchSourceIfModified(url, format, referer, etag, lastModified string) (*Source, error) {
+	fetched, err := fetchSubtitleIfModified(url, referer, etag, lastModified)
+	if err != nil {
+		return nil, err
+	}
+
+	src := NewSource([]byte(fetched.body), format)
+	src.ETag, src.LastModified = fetched.etag, fetched.lastModified
+	if IsHLSPlaylist(src.Content) {
+		src.Content, err = joinHLSSubtitleSegments(src.Content, url, referer)
+		if err != nil {
+			return nil, err
+		}
+		src.Format = formatVTT
+	}
+	return src, nil
+}
+
+// NewSource decodes fetched subtitle bytes and detects their format.
+func NewSource(body []byte, format string) *Source {
+	content, encoding := decodeSubtitleText(body)
+	return &Source{Content: content, Format: detectSubtitleFormat(content, format), Encoding: encoding}
+}
+
+// Document parses the source as TranslateSource reads it: ASS dialogue becomes WebVTT cues.
+func (s *Source) Document() (*Document, error) {
+	if s.Format != formatASS {
+		return ParseVTT(s.Content)
+	}
+	doc, err := ParseASS(s.Content)
+	if err != nil {
+		return nil, err
+	}
+	return doc.ToVTT(), nil
+}
+
+// TranslateSource translates a source by its format into WebVTT.
+func TranslateSource(src *Source, targetLang, sourceLang string, opts Options) (string, *Report, error) {
+	if src.Format == formatASS {
+		return TranslateASSToVTTWithOptions(src.Content, targetLang, sourceLang, opts)
+	}
+	return TranslateVTTWithOptions(src.Content, targetLang, sourceLang, opts)
+}
+
+// decodeSubtitleText returns body as UTF-8 without a byte order mark. UTF-16 needs a byte order mark; text that
+// is not valid UTF-8 is read as Windows-1252.
+func decodeSubtitleText(body []byte) (string, string) {
+	switch {
+	case len(body) >= 2 && body[0] == 0xFF && body[1] == 0xFE:
+		return decodeUTF16(body[2:], false), EncodingUTF16LE
+	case len(body) >= 2 && body[0] == 0xFE && body[1] == 0xFF:
+		return decodeUTF16(body[2:], true), EncodingUTF16BE
+	case utf8.Valid(body):
+		return strings.TrimPrefix(string(body), utf8BOM), EncodingUTF8
+	}
+
+	var b strings.Builder
+	b.Grow(len(body))
+	for _, c := range body {
+		if c >= 0x80 && c <= 0x9F {
+			b.WriteRune(windows1252High[c-0x80])
+			continue
+		}
+		b.WriteRune(rune(c))
+	}
+	return b.String(), EncodingWindows1252
+}
+
+func decodeUTF16(body []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		} else {
+			units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// detectSubtitleFormat tells WebVTT from ASS/SSA by their headers, falling back to the requested format.
+func detectSubtitleFormat(content, format string) string {
+	head := strings.TrimSpace(content)
+	switch {
+	case strings.HasPrefix(head, "WEBVTT"):
+		return formatVTT
+	case strings.HasPrefix(head, "[Script Info]"), strings.Contains(head, "\n[Events]"):
+		return formatASS
+	}
+	if strings.EqualFold(format, formatASS) {
+		return formatASS
+	}
+	return formatVTT
+}
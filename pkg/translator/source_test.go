@@ -0,0 +1,72 @@
+package translator
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewSource_DecodesEncodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		content  string
+		encoding string
+	}{
+		{"utf-8 with bom", []byte("\xEF\xBB\xBFWEBVTT\n"), "WEBVTT\n", EncodingUTF8},
+		{"utf-16le", []byte{0xFF, 0xFE, 'W', 0, 'E', 0, 'B', 0, 'V', 0, 'T', 0, 'T', 0, '\n', 0}, "WEBVTT\n", EncodingUTF16LE},
+		{"utf-16be", []byte{0xFE, 0xFF, 0, 'W', 0, 'E', 0, 'B', 0, 'V', 0, 'T', 0, 'T', 0, '\n'}, "WEBVTT\n", EncodingUTF16BE},
+		{"windows-1252", []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n\x93Caf\xE9\x94 \x80\n"), "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n“Café” €\n", EncodingWindows1252},
+	}
+	for _, tt := range tests {
+		src := NewSource(tt.body, "vtt")
+		if src.Content != tt.content || src.Encoding != tt.encoding {
+			t.Fatalf("%s: got %q (%s), want %q (%s)", tt.name, src.Content, src.Encoding, tt.content, tt.encoding)
+		}
+	}
+}
+
+func TestNewSource_DetectsFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		want    string
+	}{
+		{"ass requested for vtt", "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHi\n", "ass", formatVTT},
+		{"vtt requested for ass", "[Script Info]\nTitle: x\n", "vtt", formatASS},
+		{"headerless falls back", "00:00:01.000 --> 00:00:02.000\nHi\n", "ass", formatASS},
+		{"unknown format", "00:00:01.000 --> 00:00:02.000\nHi\n", "", formatVTT},
+	}
+	for _, tt := range tests {
+		if got := NewSource([]byte(tt.content), tt.format).Format; got != tt.want {
+			t.Fatalf("%s: got format %q want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFetchSourceIfModified_UsesValidators(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Sat, 17 Oct 2026 10:00:00 GMT")
+		w.Write([]byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHi\n"))
+	}))
+	defer server.Close()
+
+	src, err := FetchSourceIfModified(server.URL, "vtt", "", "", "")
+	if err != nil {
+		t.Fatalf("FetchSourceIfModified returned error: %v", err)
+	}
+	if src.ETag != `"v1"` || src.LastModified != "Sat, 17 Oct 2026 10:00:00 GMT" {
+		t.Fatalf("expected the validators of the response, got %q %q", src.ETag, src.LastModified)
+	}
+
+	if _, err := FetchSourceIfModified(server.URL, "vtt", "", src.ETag, src.LastModified); !errors.Is(err, ErrSourceNotModified) {
+		t.Fatalf("expected ErrSourceNotModified, got %v", err)
+	}
+}
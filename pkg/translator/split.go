@@ -0,0 +1,269 @@
+package translator
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Split cues are packed to fit the translated cue layout: two lines of hardLineWords words.
+const (
+	maxSplitCueWords = hardLineWords * maxOutputLines
+	maxSplitCueChars = hardLineChars * maxOutputLines
+)
+
+// splitLongCue breaks an overlong cue into consecutive cues at sentence, then clause, then word
+// boundaries, sharing the original time span in proportion to text length. A cue that only had too
+// many short lines comes back as a single re-flowed cue. Inline markup follows its words; tags open across
+// a split are closed at the end of one part and reopened at the start of the next.
+func splitLongCue(cue *Cue) []*Cue {
+	text := strings.TrimSpace(SingleLine(cue.PlainText()))
+	if text == "" {
+		return nil
+	}
+
+	var chunks []string
+	for _, sentence := range splitAtBoundaries(text, ".!?…") {
+		if fitsSplitCue(sentence) {
+			chunks = appendPacked(chunks, sentence)
+			continue
+		}
+		for _, clause := range splitAtBoundaries(sentence, ",;:") {
+			if fitsSplitCue(clause) {
+				chunks = appendPacked(chunks, clause)
+				continue
+			}
+			for _, part := range splitWordsEvenly(clause) {
+				chunks = appendPacked(chunks, part)
+			}
+		}
+	}
+
+	total := 0
+	for _, chunk := range chunks {
+		total += len([]rune(chunk))
+	}
+	texts := distributeMarkup(cue.Lines, chunks)
+
+	parts := make([]*Cue, 0, len(chunks))
+	start := cue.Start
+	consumed := 0
+	for i, chunk := range chunks {
+		consumed += len([]rune(chunk))
+		end := cue.Start + time.Duration(int64(cue.Duration())*int64(consumed)/int64(total)).Truncate(time.Millisecond)
+		if i == len(chunks)-1 {
+			end = cue.End
+		}
+
+		part := &Cue{Start: start, End: end, Settings: cue.Settings, Style: cue.Style, Actor: cue.Actor}
+		if cue.ID != "" {
+			part.ID = cue.ID + "-" + strconv.Itoa(i+1)
+		}
+		if i == 0 {
+			part.Comments = cue.Comments
+		}
+		part.SetText(texts[i])
+		parts = append(parts, part)
+		start = end
+	}
+
+	return parts
+}
+
+func fitsSplitCue(text string) bool {
+	return len(strings.Fields(text)) <= maxSplitCueWords && len([]rune(text)) <= maxSplitCueChars
+}
+
+// appendPacked adds text to the last chunk when both still fit in one cue.
+func appendPacked(chunks []string, text string) []string {
+	if n := len(chunks); n > 0 {
+		joined := chunks[n-1] + " " + text
+		if fitsSplitCue(joined) {
+			chunks[n-1] = joined
+			return chunks
+		}
+	}
+	return append(chunks, text)
+}
+
+// splitAtBoundaries splits text after any of the given punctuation marks followed by a space.
+func splitAtBoundaries(text, marks string) []string {
+	var parts []string
+	words := strings.Fields(text)
+	start := 0
+	for i, word := range words {
+		trimmed := strings.TrimRight(word, "\"'”’)]")
+		if trimmed != "" && strings.ContainsRune(marks, []rune(trimmed)[len([]rune(trimmed))-1]) {
+			parts = append(parts, strings.Join(words[start:i+1], " "))
+			start = i + 1
+		}
+	}
+	if start < len(words) {
+		parts = append(parts, strings.Join(words[start:], " "))
+	}
+	return parts
+}
+
+// splitWordsEvenly cuts text into the fewest equal word runs that fit a split cue.
+func splitWordsEvenly(text string) []string {
+	words := strings.Fields(text)
+	n := (len(words) + maxSplitCueWords - 1) / maxSplitCueWords
+	for n < len(words) {
+		size := (len(words) + n - 1) / n
+		ok := true
+		for i := 0; i < len(words); i += size {
+			end := i + size
+			if end > len(words) {
+				end = len(words)
+			}
+			if !fitsSplitCue(strings.Join(words[i:end], " ")) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			break
+		}
+		n++
+	}
+
+	size := (len(words) + n - 1) / n
+	var parts []string
+	for i := 0; i < len(words); i += size {
+		end := i + size
+		if end > len(words) {
+			end = len(words)
+		}
+		parts = append(parts, strings.Join(words[i:end], " "))
+	}
+	return parts
+}
+
+// splitToken is a word piece or tag of cue text. space records whether whitespace came right before it, and
+// word whether it starts a new word of the plain text.
+type splitToken struct {
+	text  string
+	tag   bool
+	space bool
+	word  bool
+}
+
+// distributeMarkup lays the words and tags of lines out over chunks of their plain text. Opening tags and
+// timestamps go with the word after them and closing tags with the word before; tags still open at the end of
+// a chunk are closed there and reopened at the start of the next.
+func distributeMarkup(lines []Line, chunks []string) []string {
+	var tokens []splitToken
+	space, gap := false, true
+	for _, line := range lines {
+		space, gap = true, true
+		for _, run := range line {
+			if run.Tag {
+				tokens = append(tokens, splitToken{text: run.Text, tag: true, space: space})
+				space = false
+				continue
+			}
+			text := run.Text
+			for text != "" {
+				if trimmed := strings.TrimLeft(text, " \t"); trimmed != text {
+					space, gap = true, true
+					text = trimmed
+					continue
+				}
+				end := strings.IndexAny(text, " \t")
+				if end == -1 {
+					end = len(text)
+				}
+				tokens = append(tokens, splitToken{text: text[:end], space: space, word: gap})
+				space, gap = false, false
+				text = text[end:]
+			}
+		}
+	}
+
+	// Words are counted the way the chunks were cut from the plain text.
+	var wordChunk []int
+	for c, chunk := range chunks {
+		for range strings.Fields(chunk) {
+			wordChunk = append(wordChunk, c)
+		}
+	}
+	owner := make([]int, len(tokens))
+	word := -1
+	for i, token := range tokens {
+		if token.tag {
+			continue
+		}
+		if token.word && word < len(wordChunk)-1 {
+			word++
+		}
+		owner[i] = wordChunk[word]
+	}
+	for i, token := range tokens {
+		if !token.tag {
+			continue
+		}
+		if classifySplitTag(token.text) == markupClose {
+			owner[i] = 0
+			for j := i - 1; j >= 0; j-- {
+				if !tokens[j].tag {
+					owner[i] = owner[j]
+					break
+				}
+			}
+			continue
+		}
+		owner[i] = len(chunks) - 1
+		for j := i + 1; j < len(tokens); j++ {
+			if !tokens[j].tag {
+				owner[i] = owner[j]
+				break
+			}
+		}
+	}
+
+	texts := make([]string, len(chunks))
+	var open []string
+	pos := 0
+	for c := range chunks {
+		var b strings.Builder
+		for _, tag := range open {
+			b.WriteString(tag)
+		}
+		for first := true; pos < len(tokens) && owner[pos] <= c; pos++ {
+			token := tokens[pos]
+			if token.space && !first {
+				b.WriteString(" ")
+			}
+			b.WriteString(token.text)
+			first = false
+
+			switch classifySplitTag(token.text) {
+			case markupOpen:
+				open = append(open, token.text)
+			case markupClose:
+				name := markupTagName(token.text)
+				for j := len(open) - 1; j >= 0; j-- {
+					if markupTagName(open[j]) == name {
+						open = append(open[:j], open[j+1:]...)
+						break
+					}
+				}
+			}
+		}
+		if c < len(chunks)-1 {
+			for j := len(open) - 1; j >= 0; j-- {
+				b.WriteString("</" + markupTagName(open[j]) + ">")
+			}
+		}
+		texts[c] = b.String()
+	}
+	return texts
+}
+
+// classifySplitTag classifies a VTT tag; anything else, such as an ASS override block, is an atom.
+func classifySplitTag(text string) markupTagKind {
+	if !strings.HasPrefix(text, "<") {
+		return markupAtom
+	}
+	return classifyMarkupTag(text).kind
+}
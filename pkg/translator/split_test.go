@@ -0,0 +1,104 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitLongCue_SplitsAtSentencesWithProportionalTiming(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"rules",
+		"00:00:36.000 --> 00:00:46.000 line:20%",
+		"Class points earned by the top groups will be transferred. Points are divided equally among classes in a group, regardless of the member count. Unused points expire at the end of the term.",
+	)
+
+	parts := splitLongCue(doc.Cues[0])
+	if len(parts) < 2 {
+		t.Fatalf("expected cue to be split, got %d parts", len(parts))
+	}
+
+	if parts[0].Start != 36*time.Second || parts[len(parts)-1].End != 46*time.Second {
+		t.Fatalf("split parts must cover the original span: %v-%v", parts[0].Start, parts[len(parts)-1].End)
+	}
+
+	for i, part := range parts {
+		text := part.PlainText()
+		if !fitsSplitCue(text) {
+			t.Fatalf("part %d does not fit a cue: %q", i, text)
+		}
+		if i > 0 && part.Start != parts[i-1].End {
+			t.Fatalf("part %d is not contiguous with the previous part", i)
+		}
+		if part.Settings != "line:20%" || part.ID != "rules-"+string(rune('1'+i)) {
+			t.Fatalf("part %d lost cue settings or id: %#v", i, part)
+		}
+	}
+
+	if parts[0].PlainText() != "Class points earned by the top groups will be transferred." {
+		t.Fatalf("expected first part to end at the sentence boundary, got %q", parts[0].PlainText())
+	}
+
+	total := 0
+	for _, part := range parts {
+		total += len([]rune(part.PlainText()))
+	}
+	for i, part := range parts {
+		want := 10 * time.Second * time.Duration(len([]rune(part.PlainText()))) / time.Duration(total)
+		if diff := part.Duration() - want; diff > time.Millisecond || diff < -time.Millisecond {
+			t.Fatalf("part %d duration %v is not proportional to its text (want about %v)", i, part.Duration(), want)
+		}
+	}
+}
+
+func TestSplitLongCues_ReportsSplitCues(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:02.000",
+		"Short cue.",
+		"",
+		"00:00:03.000 --> 00:00:09.000",
+		"Deserted Island Survival",
+		"Days",
+		"July 19: Set out",
+		"July 20-August 3: Special test",
+	)
+
+	index := cueIndex(doc)
+	report := &Report{}
+	remaining := splitLongCues(doc, markLongCueBlocks(doc), index, report)
+
+	if len(remaining) != 0 {
+		t.Fatalf("expected every long cue to be split, %d remain", len(remaining))
+	}
+	if len(report.Split) != 1 || report.Split[0].Index != 2 || report.Split[0].Parts != len(doc.Cues)-1 {
+		t.Fatalf("unexpected split report: %#v (cues=%d)", report.Split, len(doc.Cues))
+	}
+	if markLongCueBlocks(doc)[doc.Cues[1]] {
+		t.Fatalf("split parts must not be long cues themselves")
+	}
+}
+
+func TestSplitLongCue_CarriesMarkupAcrossParts(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:01.000 --> 00:00:09.000",
+		"<v Mia>Class points earned by the top groups will be <i>transferred. Points are divided equally</i> among classes in a group, regardless of the member count.",
+	)
+
+	parts := splitLongCue(doc.Cues[0])
+	want := []string{
+		"<v Mia>Class points earned by the top groups will be <i>transferred.</i></v>",
+		"<v Mia><i>Points are divided equally</i> among classes in a group,</v>",
+		"<v Mia>regardless of the member count.",
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("expected %d parts, got %d", len(want), len(parts))
+	}
+	for i, part := range parts {
+		if got := part.Text(); got != want[i] {
+			t.Fatalf("part %d:\n got %q\nwant %q", i, got, want[i])
+		}
+	}
+}
@@ -96,7 +96,8 @@ func processSubtitleBlock(block []string) []string {
 			continue
 		}
 
-		if isSubtitleMetadataLine(trimmed) {
+		// Bilingual output keeps the original line untouched.
+		if isSubtitleMetadataLine(trimmed) || isClassSpanLine(trimmed) {
 			processed = append(processed, trimmed)
 			continue
 		}
@@ -163,6 +164,10 @@ func stripLeadingPunctuationPrefix(line string) string {
 	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "- [") || strings.HasPrefix(trimmed, "-[") {
 		return trimmed
 	}
+	// Sound descriptions kept from SDH subtitles, such as (LAUGHS), keep their opening parenthesis.
+	if startsWithSDHAnnotation(trimmed) {
+		return trimmed
+	}
 	trimmed = strings.TrimLeft(trimmed, ".,!?/+-=(){}|\\'\";:~*_`")
 	trimmed = strings.TrimLeft(trimmed, " ")
 	return strings.TrimSpace(trimmed)
@@ -238,7 +243,7 @@ func normalizeFormattingTags(line string) string {
 func maskFormattingTags(line string) (string, map[string]string) {
 	tags := map[string]string{}
 	idx := 0
-	masked := formattingTagRe.ReplaceAllStringFunc(line, func(tag string) string {
+	masked := vttTagRe.ReplaceAllStringFunc(line, func(tag string) string {
 		key := "__RANIME_TAG_" + strconv.Itoa(idx) + "__"
 		tags[key] = tag
 		idx++
@@ -0,0 +1,153 @@
+package translator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timing operations.
+const (
+	TimingShift     = "shift"
+	TimingScale     = "scale"
+	TimingFramerate = "framerate"
+)
+
+// SyncPoint maps a timestamp in the subtitle (From) to where it should be (To).
+type SyncPoint struct {
+	From time.Duration `json:"from"`
+	To   time.Duration `json:"to"`
+}
+
+// TimingAdjustment describes one timing fix applied to every cue of a document.
+type TimingAdjustment struct {
+	Operation string `json:"operation"`
+	// Offset is added to every timestamp (shift).
+	Offset time.Duration `json:"offset,omitempty"`
+	// Sync holds two sync points; timestamps are mapped linearly through them (scale).
+	Sync [2]SyncPoint `json:"sync"`
+	// FromFPS is the frame rate the subtitle was timed for, ToFPS the target video frame rate (framerate).
+	FromFPS float64 `json:"from_fps,omitempty"`
+	ToFPS   float64 `json:"to_fps,omitempty"`
+}
+
+// Validate checks that the adjustment has the parameters its operation needs.
+func (a TimingAdjustment) Validate() error {
+	switch a.Operation {
+	case TimingShift:
+		if a.Offset == 0 {
+			return errors.New("offset must not be zero")
+		}
+	case TimingScale:
+		if a.Sync[0].From == a.Sync[1].From {
+			return errors.New("sync points must have different source timestamps")
+		}
+		if (a.Sync[1].To-a.Sync[0].To <= 0) != (a.Sync[1].From-a.Sync[0].From <= 0) {
+			return errors.New("sync points must keep cue order")
+		}
+	case TimingFramerate:
+		if a.FromFPS <= 0 || a.ToFPS <= 0 {
+			return errors.New("frame rates must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown timing operation %q", a.Operation)
+	}
+	return nil
+}
+
+// Inverse is the adjustment that maps timestamps back to where they were before a.
+func (a TimingAdjustment) Inverse() TimingAdjustment {
+	inverse := a
+	switch a.Operation {
+	case TimingShift:
+		inverse.Offset = -a.Offset
+	case TimingScale:
+		for i, point := range a.Sync {
+			inverse.Sync[i] = SyncPoint{From: point.To, To: point.From}
+		}
+	case TimingFramerate:
+		inverse.FromFPS, inverse.ToFPS = a.ToFPS, a.FromFPS
+	}
+	return inverse
+}
+
+// ReplayTiming applies adjustments in order, as they were made.
+func (d *Document) ReplayTiming(adjustments []TimingAdjustment) error {
+	for _, adj := range adjustments {
+		if err := d.AdjustTiming(adj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevertTiming undoes adjustments made in order, mapping cues back to the timeline before the first one. Cues that
+// an adjustment pushed before zero stay clamped or removed.
+func (d *Document) RevertTiming(adjustments []TimingAdjustment) error {
+	for i := len(adjustments) - 1; i >= 0; i-- {
+		if err := d.AdjustTiming(adjustments[i].Inverse()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdjustTiming applies the adjustment to every cue, including karaoke timestamps inside cue text.
+// Cues pushed entirely before zero are removed and cues starting before zero are clamped to start at zero.
+func (d *Document) AdjustTiming(adj TimingAdjustment) error {
+	if err := adj.Validate(); err != nil {
+		return err
+	}
+
+	var mapTime func(time.Duration) time.Duration
+	switch adj.Operation {
+	case TimingShift:
+		mapTime = func(t time.Duration) time.Duration { return t + adj.Offset }
+	case TimingScale:
+		from0, to0 := adj.Sync[0].From, adj.Sync[0].To
+		ratio := float64(adj.Sync[1].To-to0) / float64(adj.Sync[1].From-from0)
+		mapTime = func(t time.Duration) time.Duration {
+			return to0 + time.Duration(float64(t-from0)*ratio)
+		}
+	case TimingFramerate:
+		ratio := adj.FromFPS / adj.ToFPS
+		mapTime = func(t time.Duration) time.Duration { return time.Duration(float64(t) * ratio) }
+	}
+
+	kept := d.Cues[:0]
+	for _, cue := range d.Cues {
+		start := mapTime(cue.Start).Round(time.Millisecond)
+		end := mapTime(cue.End).Round(time.Millisecond)
+		if end <= 0 {
+			continue
+		}
+		if start < 0 {
+			start = 0
+		}
+		cue.Start, cue.End = start, end
+		shiftKaraokeTimestamps(cue, mapTime)
+		kept = append(kept, cue)
+	}
+	d.Cues = kept
+
+	return nil
+}
+
+func shiftKaraokeTimestamps(cue *Cue, mapTime func(time.Duration) time.Duration) {
+	for _, line := range cue.Lines {
+		for i, run := range line {
+			if !run.Tag || !vttKaraokeTimestampRe.MatchString(run.Text) {
+				continue
+			}
+			ts, err := ParseTimestamp(run.Text[1 : len(run.Text)-1])
+			if err != nil {
+				continue
+			}
+			mapped := mapTime(ts).Round(time.Millisecond)
+			if mapped < cue.Start {
+				mapped = cue.Start
+			}
+			line[i].Text = "<" + formatVTTTimestamp(mapped) + ">"
+		}
+	}
+}
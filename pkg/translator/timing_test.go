@@ -0,0 +1,119 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdjustTiming_ShiftDropsCuesBeforeZero(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:01.000 --> 00:00:01.800",
+		"Gone",
+		"",
+		"00:00:01.500 --> 00:00:03.000",
+		"Clamped",
+		"",
+		"00:00:05.000 --> 00:00:06.000",
+		"<00:00:05.500>Kept",
+	)
+
+	if err := doc.AdjustTiming(TimingAdjustment{Operation: TimingShift, Offset: -2 * time.Second}); err != nil {
+		t.Fatalf("AdjustTiming returned error: %v", err)
+	}
+
+	want := "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nClamped\n\n00:00:03.000 --> 00:00:04.000\n<00:00:03.500>Kept\n"
+	if got := FormatVTT(doc); got != want {
+		t.Fatalf("unexpected shifted document:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestAdjustTiming_ScaleMapsBothSyncPoints(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:10.000 --> 00:00:12.000",
+		"First",
+		"",
+		"00:01:40.000 --> 00:01:42.000",
+		"Last",
+	)
+
+	err := doc.AdjustTiming(TimingAdjustment{
+		Operation: TimingScale,
+		Sync: [2]SyncPoint{
+			{From: 10 * time.Second, To: 11 * time.Second},
+			{From: 100 * time.Second, To: 104 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("AdjustTiming returned error: %v", err)
+	}
+
+	if doc.Cues[0].Start != 11*time.Second || doc.Cues[1].Start != 104*time.Second {
+		t.Fatalf("sync points not honoured: %v, %v", doc.Cues[0].Start, doc.Cues[1].Start)
+	}
+	// 93/90 stretch: 2s becomes 2.067s.
+	if doc.Cues[1].End != 104*time.Second+2067*time.Millisecond {
+		t.Fatalf("unexpected scaled end: %v", doc.Cues[1].End)
+	}
+}
+
+func TestAdjustTiming_FramerateConversion(t *testing.T) {
+	doc := mustParseVTT(t, "00:01:00.000 --> 00:01:02.000", "Hello")
+
+	if err := doc.AdjustTiming(TimingAdjustment{Operation: TimingFramerate, FromFPS: 25, ToFPS: 23.976}); err != nil {
+		t.Fatalf("AdjustTiming returned error: %v", err)
+	}
+
+	if doc.Cues[0].Start != 62563*time.Millisecond {
+		t.Fatalf("unexpected converted start: %v", doc.Cues[0].Start)
+	}
+}
+
+func TestTimingAdjustment_Validate(t *testing.T) {
+	invalid := []TimingAdjustment{
+		{Operation: "stretch"},
+		{Operation: TimingShift},
+		{Operation: TimingScale, Sync: [2]SyncPoint{{From: time.Second, To: time.Second}, {From: time.Second, To: 2 * time.Second}}},
+		{Operation: TimingScale, Sync: [2]SyncPoint{{From: time.Second, To: 5 * time.Second}, {From: 2 * time.Second, To: 3 * time.Second}}},
+		{Operation: TimingFramerate, FromFPS: 25},
+	}
+
+	for _, adj := range invalid {
+		if err := adj.Validate(); err == nil {
+			t.Fatalf("expected validation error for %#v", adj)
+		}
+	}
+}
+
+func TestRevertTiming_UndoesReplayedAdjustments(t *testing.T) {
+	doc := mustParseVTT(t,
+		"00:00:10.000 --> 00:00:12.000",
+		"First",
+		"",
+		"00:01:40.000 --> 00:01:42.000",
+		"Last",
+	)
+	adjustments := []TimingAdjustment{
+		{Operation: TimingShift, Offset: 1500 * time.Millisecond},
+		{Operation: TimingFramerate, FromFPS: 25, ToFPS: 24},
+		{Operation: TimingScale, Sync: [2]SyncPoint{{From: 10 * time.Second, To: 11 * time.Second}, {From: 100 * time.Second, To: 98 * time.Second}}},
+	}
+
+	if err := doc.ReplayTiming(adjustments); err != nil {
+		t.Fatalf("ReplayTiming returned error: %v", err)
+	}
+	if doc.Cues[0].Start == 10*time.Second {
+		t.Fatalf("expected the adjustments to move the cues")
+	}
+	if err := doc.RevertTiming(adjustments); err != nil {
+		t.Fatalf("RevertTiming returned error: %v", err)
+	}
+
+	// Rounding to milliseconds on every step may leave a millisecond either way
+	for i, want := range []time.Duration{10 * time.Second, 100 * time.Second} {
+		if diff := doc.Cues[i].Start - want; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Fatalf("expected cue %d back at %v, got %v", i, want, doc.Cues[i].Start)
+		}
+	}
+}
@@ -5,28 +5,64 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // FetchAndTranslate fetches a subtitle file from URL and translates it
 func FetchAndTranslate(url, format, targetLang, sourceLang, referer string) (string, error) {
+	content, _, err := FetchAndTranslateWithOptions(url, format, targetLang, sourceLang, referer, Options{})
+	return content, err
+}
+
+// FetchAndTranslateWithOptions is FetchAndTranslate with output options such as bilingual cues.
+func FetchAndTranslateWithOptions(url, format, targetLang, sourceLang, referer string, opts Options) (string, *Report, error) {
 	// Fetch subtitle content
+	src, err := FetchSource(url, format, referer)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return TranslateSource(src, targetLang, sourceLang, opts)
+}
+
+// FetchHLSMasterPlaylist downloads and parses an HLS master playlist.
+func FetchHLSMasterPlaylist(url, referer string) (*HLSMasterPlaylist, error) {
 	content, err := fetchSubtitle(url, referer)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Translate based on format
-	format = strings.ToLower(format)
-	if format == "ass" {
-		return TranslateASSToVTT(content, targetLang, sourceLang)
+	return ParseHLSMasterPlaylist(content, url)
+}
+
+// FetchDocument downloads a VTT or ASS subtitle (or an HLS subtitle playlist) and parses it without translating.
+func FetchDocument(url, format, referer string) (*Document, error) {
+	src, err := FetchSource(url, format, referer)
+	if err != nil {
+		return nil, err
 	}
 
-	return TranslateVTT(content, targetLang, sourceLang)
+	return ParseDocument(src.Content, src.Format)
 }
 
 func fetchSubtitle(url, referer string) (string, error) {
+	fetched, err := fetchSubtitleIfModified(url, referer, "", "")
+	if err != nil {
+		return "", err
+	}
+	return fetched.body, nil
+}
+
+// fetchedSubtitle is a fetched body with the cache validators it was served with.
+type fetchedSubtitle struct {
+	body         string
+	etag         string
+	lastModified string
+}
+
+// fetchSubtitleIfModified fetches url with a conditional request when etag or lastModified is set, and returns
+// ErrSourceNotModified when the server answers 304.
+func fetchSubtitleIfModified(url, referer, etag, lastModified string) (*fetchedSubtitle, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 		Transport: &http.Transport{
@@ -39,28 +75,41 @@ func fetchSubtitle(url, referer string) (string, error) {
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:146.0) Gecko/20100101 Firefox/146.0")
 	if referer != "" {
 		req.Header.Set("Referer", referer)
 	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch subtitle: %w", err)
+		return nil, fmt.Errorf("failed to fetch subtitle: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && (etag != "" || lastModified != "") {
+		return nil, ErrSourceNotModified
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch subtitle: status %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to fetch subtitle: status %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return string(body), nil
+	return &fetchedSubtitle{
+		body:         string(body),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
 }
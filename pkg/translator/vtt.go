@@ -23,109 +23,246 @@ var (
 )
 
 type vttCueBatch struct {
-	textLineIndices []int
-	originalText    string
+	cue          *Cue
+	originalText string
+	// sourceText is the text sent for translation, with inline markup masked as placeholders.
+	sourceText string
+	markup     *cueMarkup
+	// lyric is set for song lyric cues, which are translated on their own.
+	lyric bool
 }
 
 // TranslateVTT parses VTT subtitle, translates per-timestamp cue text, and returns translated VTT content.
 func TranslateVTT(content, targetLang, sourceLang string) (string, error) {
-	lines := strings.Split(content, "\n")
-	blockedLines := markLongCueBlocks(lines)
-	cues := collectVTTCueBatches(lines, blockedLines)
+	content, _, err := TranslateVTTWithOptions(content, targetLang, sourceLang, Options{})
+	return content, err
+}
+
+// TranslateVTTWithOptions is TranslateVTT with output options such as bilingual cues.
+// The report lists cues that were split or dropped.
+func TranslateVTTWithOptions(content, targetLang, sourceLang string, opts Options) (string, *Report, error) {
+	doc, err := ParseVTT(content)
+	if err != nil {
+		return "", nil, err
+	}
+
+	report, err := TranslateDocument(doc, targetLang, sourceLang, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return FormatVTT(doc), report, nil
+}
+
+// TranslateDocument translates the cue text of a document in place.
+// Overlong cues are dropped or split depending on opts.LongCues, and cues whose translation comes back empty are removed.
+// Hearing-impaired annotations are translated, kept untranslated or stripped depending on opts.SDH; lyric cues are
+// left out of that in every lyrics mode, as their music notes would otherwise take the sung line with them.
+// With opts.MaxCPS set, cues that read too fast are extended or condensed and reported when they stay over the limit.
+// Cues matching opts.Locks keep their corrected text and cues matching opts.Reuse their earlier translation; neither
+// is translated.
+func TranslateDocument(doc *Document, targetLang, sourceLang string, opts Options) (*Report, error) {
+	opts = opts.Normalized()
+	report := &Report{}
+
+	index := cueIndex(doc)
+	locked := applyCueLocks(doc, opts.Locks, index, report)
+	locked = mergeCueSets(reuseTranslations(doc, opts.Reuse, locked, report), locked)
+	lyrics := detectLyricCues(doc)
+	report.Lyrics = lyricNotes(lyrics, index)
+	if opts.SDH == SDHStrip {
+		stripSDHAnnotations(doc, index, report, mergeCueSets(lyrics, locked))
+	}
+	blocked := markLongCueBlocks(doc)
+	for cue := range locked {
+		delete(blocked, cue)
+	}
+	if opts.LongCues == LongCuesSplit {
+		blocked = splitLongCues(doc, blocked, index, report)
+	}
+	for cue := range blocked {
+		report.Dropped = append(report.Dropped, newCueNote(index[cue], cue, ReasonTooLong))
+	}
+
+	cues := unlockedBatches(collectVTTCueBatches(doc, blocked, opts, lyrics), locked)
+	restoreLockedCues(locked)
 	if len(cues) == 0 {
-		return strings.Join(lines, "\n"), nil
+		report.sort()
+		return report, nil
 	}
 
-	textValues := make([]string, 0, len(cues))
-	for _, cue := range cues {
-		textValues = append(textValues, cue.originalText)
+	// Sentences running across several cues are translated as one unit.
+	groups := groupSentenceCues(cues)
+	textValues := make([]string, 0, len(groups))
+	for _, group := range groups {
+		textValues = append(textValues, sentenceGroupText(group))
 	}
 
-	log.Printf("Starting translation of %d cue blocks...", len(textValues))
+	var romanized map[*Cue]string
+	switch {
+	case opts.Output == OutputRomanized:
+		romanized = romanizeCues(cues, sourceLang, false)
+	case opts.Lyrics == LyricsRomanized:
+		romanized = romanizeCues(cues, sourceLang, true)
+	}
+
+	log.Printf("Starting translation of %d cue blocks (%d sentence groups)...", len(cues), len(textValues))
 
 	// Translate all cue text blocks.
 	translated, err := BatchTranslate(textValues, targetLang, sourceLang)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	log.Printf("Translation completed successfully")
 
-	// Replace translated cue text back.
-	for idx, trans := range translated {
-		applyTranslatedCue(lines, cues[idx], trans, targetLang)
+	apply := func(batch vttCueBatch, trans string) {
+		switch opts.Output {
+		case OutputBilingual:
+			applyBilingualCue(batch, trans, targetLang, opts)
+		case OutputRomanized:
+			applyRomanizedCue(batch, trans, romanized[batch.cue], targetLang, opts)
+		default:
+			applyTranslatedCue(batch, trans, targetLang)
+		}
 	}
 
-	return strings.Join(lines, "\n"), nil
-}
-
-func collectVTTCueBatches(lines []string, blockedLines map[int]bool) []vttCueBatch {
-	for i := range lines {
-		if blockedLines[i] {
-			lines[i] = ""
-			continue
+	// Replace translated cue text back, splitting merged sentences over their cues.
+	var unmerged []vttCueBatch
+	for idx, trans := range translated {
+		group := groups[idx]
+		parts := []string{trans}
+		if len(group) > 1 {
+			var ok bool
+			if parts, ok = redistributeTranslation(trans, group); !ok {
+				unmerged = append(unmerged, group...)
+				continue
+			}
 		}
 
-		line := RemoveFontTags(lines[i])
-		if vttTimestampRe.MatchString(strings.TrimSpace(line)) {
-			line = normalizeTimestampLine(line)
+		for i, batch := range group {
+			apply(batch, parts[i])
 		}
-		lines[i] = line
 	}
 
-	cues := make([]vttCueBatch, 0, 32)
-	start := 0
-	for start < len(lines) {
-		for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
-			start++
+	// Translations too short to spread over their group are redone cue by cue.
+	if len(unmerged) > 0 {
+		textValues = textValues[:0]
+		for _, batch := range unmerged {
+			textValues = append(textValues, batch.sourceText)
 		}
-		if start >= len(lines) {
-			break
+		translated, err = BatchTranslate(textValues, targetLang, sourceLang)
+		if err != nil {
+			return nil, err
+		}
+		for i, batch := range unmerged {
+			apply(batch, translated[i])
+		}
+	}
+	if opts.Output != OutputRomanized {
+		for cue, text := range romanized {
+			addRomanizedLine(cue, text, opts)
 		}
+	}
 
-		end := start
-		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
-			end++
+	for _, cue := range doc.Cues {
+		if len(cue.Lines) == 0 {
+			report.Dropped = append(report.Dropped, newCueNote(index[cue], cue, ReasonEmptyTranslation))
 		}
+	}
+	removeEmptyCues(doc)
 
-		cue, ok := buildCueBatch(lines, start, end)
-		if ok {
+	if opts.MaxCPS > 0 {
+		enforceReadingSpeed(doc, targetLang, opts, index, report)
+		// Condensing never rewrites corrected text
+		restoreLockedCues(locked)
+	}
+
+	report.sort()
+	return report, nil
+}
+
+// splitLongCues replaces blocked cues with their split parts and returns the cues that could not be split.
+// Split parts are added to index under the position of the cue they came from.
+func splitLongCues(doc *Document, blocked map[*Cue]bool, index map[*Cue]int, report *Report) map[*Cue]bool {
+	remaining := make(map[*Cue]bool)
+	cues := make([]*Cue, 0, len(doc.Cues))
+	for _, cue := range doc.Cues {
+		if !blocked[cue] {
 			cues = append(cues, cue)
+			continue
 		}
 
-		start = end
+		parts := splitLongCue(cue)
+		if len(parts) == 0 {
+			remaining[cue] = true
+			cues = append(cues, cue)
+			continue
+		}
+
+		note := newCueNote(index[cue], cue, ReasonTooLong)
+		note.Parts = len(parts)
+		report.Split = append(report.Split, note)
+		for _, part := range parts {
+			index[part] = index[cue]
+		}
+		cues = append(cues, parts...)
 	}
+	doc.Cues = cues
+	return remaining
+}
 
-	return cues
+// cueIndex maps cues to their 1-based position in the source document.
+func cueIndex(doc *Document) map[*Cue]int {
+	index := make(map[*Cue]int, len(doc.Cues))
+	for i, cue := range doc.Cues {
+		index[cue] = i + 1
+	}
+	return index
 }
 
-func buildCueBatch(lines []string, start, end int) (vttCueBatch, bool) {
-	timestampLine := -1
-	for i := start; i < end; i++ {
-		if vttTimestampRe.MatchString(strings.TrimSpace(lines[i])) {
-			timestampLine = i
-			break
+// collectVTTCueBatches removes blocked cues from the document and returns the cues that carry translatable text.
+// With SDHKeep, hearing-impaired annotations are left out of the text sent for translation. Lyric cues are
+// marked and never masked, and left out entirely with LyricsOriginal.
+func collectVTTCueBatches(doc *Document, blocked map[*Cue]bool, opts Options, lyrics map[*Cue]string) []vttCueBatch {
+	kept := doc.Cues[:0]
+	for _, cue := range doc.Cues {
+		if blocked[cue] {
+			continue
 		}
+		cue.SetText(RemoveFontTags(cue.Text()))
+		kept = append(kept, cue)
 	}
+	doc.Cues = kept
 
-	if timestampLine == -1 {
-		return vttCueBatch{}, false
+	cues := make([]vttCueBatch, 0, len(doc.Cues))
+	for _, cue := range doc.Cues {
+		_, lyric := lyrics[cue]
+		if lyric && opts.Lyrics == LyricsOriginal {
+			continue
+		}
+		batch, ok := buildCueBatch(cue, opts.SDH == SDHKeep && !lyric)
+		if ok {
+			batch.lyric = lyric
+			cues = append(cues, batch)
+		}
 	}
 
-	textLineIndices := make([]int, 0, 4)
-	textParts := make([]string, 0, 4)
-	for i := timestampLine + 1; i < end; i++ {
-		trimmed := strings.TrimSpace(lines[i])
-		if trimmed == "" || isDigitOnly(trimmed) {
+	return cues
+}
+
+func buildCueBatch(cue *Cue, keepSDH bool) (vttCueBatch, bool) {
+	textParts := make([]string, 0, len(cue.Lines))
+	for _, line := range cue.Lines {
+		if isDigitOnly(strings.TrimSpace(line.String())) {
 			continue
 		}
 
-		clean := strings.TrimSpace(vttTagRe.ReplaceAllString(lines[i], ""))
+		clean := strings.TrimSpace(line.Plain())
 		if clean == "" {
 			continue
 		}
 
-		textLineIndices = append(textLineIndices, i)
 		textParts = append(textParts, clean)
 	}
 
@@ -133,50 +270,59 @@ func buildCueBatch(lines []string, start, end int) (vttCueBatch, bool) {
 		return vttCueBatch{}, false
 	}
 
+	// Send cue text as a single sentence for better translation quality.
+	sourceText, markup := maskCueMarkup(cue.Lines, keepSDH)
+	if keepSDH && strings.TrimSpace(markupPlaceholderRe.ReplaceAllString(sourceText, "")) == "" {
+		// Nothing but annotations: the cue is kept as it is and costs no engine quota.
+		return vttCueBatch{}, false
+	}
+
 	return vttCueBatch{
-		textLineIndices: textLineIndices,
-		// Send cue text as a single sentence for better translation quality.
+		cue:          cue,
 		originalText: strings.Join(textParts, " "),
+		sourceText:   sourceText,
+		markup:       markup,
 	}, true
 }
 
-func applyTranslatedCue(lines []string, cue vttCueBatch, translated, targetLang string) {
+func applyTranslatedCue(batch vttCueBatch, translated, targetLang string) {
+	batch.cue.SetLines(translatedCueLines(batch, translated, targetLang))
+}
+
+// applyBilingualCue writes the original cue text next to its translation.
+func applyBilingualCue(batch vttCueBatch, translated, targetLang string, opts Options) {
+	translatedLines := translatedCueLines(batch, translated, targetLang)
+	if len(translatedLines) == 0 {
+		batch.cue.SetLines(nil)
+		return
+	}
+	batch.cue.SetLines(bilingualCueLines(batch.originalText, translatedLines, opts))
+}
+
+func translatedCueLines(batch vttCueBatch, translated, targetLang string) []string {
 	translatedLines := splitCueTextLines(translated, targetLang)
 	translatedLines = capCueOutputLines(translatedLines, maxOutputLines)
 	if len(translatedLines) == 0 {
 		if strings.ToLower(targetLang) != "id" {
-			translatedLines = splitCueTextLines(cue.originalText, targetLang)
+			translatedLines = splitCueTextLines(batch.sourceText, targetLang)
 			translatedLines = capCueOutputLines(translatedLines, maxOutputLines)
 		}
 	}
-
 	if len(translatedLines) == 0 {
-		for _, lineIdx := range cue.textLineIndices {
-			lines[lineIdx] = ""
-		}
-		return
+		return nil
 	}
 
-	lineSlots := len(cue.textLineIndices)
-	if lineSlots == 0 {
-		return
-	}
+	return strings.Split(batch.markup.restore(strings.Join(translatedLines, "\n")), "\n")
+}
 
-	if len(translatedLines) <= lineSlots {
-		for i, lineIdx := range cue.textLineIndices {
-			if i < len(translatedLines) {
-				lines[lineIdx] = translatedLines[i]
-				continue
-			}
-			lines[lineIdx] = ""
+func removeEmptyCues(doc *Document) {
+	kept := doc.Cues[:0]
+	for _, cue := range doc.Cues {
+		if len(cue.Lines) > 0 {
+			kept = append(kept, cue)
 		}
-		return
 	}
-
-	for i := 0; i < lineSlots-1; i++ {
-		lines[cue.textLineIndices[i]] = translatedLines[i]
-	}
-	lines[cue.textLineIndices[lineSlots-1]] = strings.Join(translatedLines[lineSlots-1:], "\n")
+	doc.Cues = kept
 }
 
 func splitCueTextLines(text string, targetLang string) []string {
@@ -215,6 +361,16 @@ func splitCueTextLines(text string, targetLang string) []string {
 		return capCueOutputLines(wrapped, maxOutputLines)
 	}
 
+	if wrap, ok := scriptWrapFor(targetLang); ok {
+		lines := wrapScriptLine(joinScriptLines(result, wrap.rule), wrap)
+		if wrap.rtl {
+			for i := range lines {
+				lines[i] = addBidiMarks(lines[i])
+			}
+		}
+		return lines
+	}
+
 	return result
 }
 
@@ -272,7 +428,7 @@ func wrapCueLineByHeuristics(text string) []string {
 	}
 
 	for i, word := range words {
-		wordLen := len([]rune(word))
+		wordLen := cueTextLen(word)
 		addedLen := wordLen
 		if len(current) > 0 {
 			addedLen++
@@ -332,7 +488,7 @@ func rebalanceShortLeadLine(lines []string) []string {
 		return lines
 	}
 
-	if len([]rune(first)) >= minLeadLineChars {
+	if cueTextLen(first) >= minLeadLineChars {
 		return lines
 	}
 
@@ -346,14 +502,14 @@ func rebalanceShortLeadLine(lines []string) []string {
 		candidateWords := append(append([]string{}, firstWords...), secondWords[0])
 		candidateLine := strings.Join(candidateWords, " ")
 
-		if len([]rune(candidateLine)) > hardLineChars || len(candidateWords) > hardLineWords {
+		if cueTextLen(candidateLine) > hardLineChars || len(candidateWords) > hardLineWords {
 			break
 		}
 
 		firstWords = candidateWords
 		secondWords = secondWords[1:]
 
-		if len([]rune(candidateLine)) >= minLeadLineChars {
+		if cueTextLen(candidateLine) >= minLeadLineChars {
 			break
 		}
 	}
@@ -383,32 +539,17 @@ func isNaturalPauseConjunction(word string) bool {
 	}
 }
 
-func markLongCueBlocks(lines []string) map[int]bool {
-	blocked := make(map[int]bool)
-	start := 0
-
-	for start < len(lines) {
-		for start < len(lines) && strings.TrimSpace(RemoveFontTags(lines[start])) == "" {
-			start++
+func markLongCueBlocks(doc *Document) map[*Cue]bool {
+	blocked := make(map[*Cue]bool)
+	for _, cue := range doc.Cues {
+		lines := make([]string, 0, len(cue.Lines))
+		for _, line := range cue.Lines {
+			lines = append(lines, line.String())
 		}
-		if start >= len(lines) {
-			break
+		if isLongCueText(lines) {
+			blocked[cue] = true
 		}
-
-		end := start
-		for end < len(lines) && strings.TrimSpace(RemoveFontTags(lines[end])) != "" {
-			end++
-		}
-
-		if shouldDropVTTBlock(lines[start:end]) {
-			for i := start; i < end; i++ {
-				blocked[i] = true
-			}
-		}
-
-		start = end
 	}
-
 	return blocked
 }
 
@@ -425,11 +566,16 @@ func shouldDropVTTBlock(block []string) bool {
 		return false
 	}
 
+	return isLongCueText(block[timestampIdx+1:])
+}
+
+// isLongCueText reports whether cue text lines exceed the line or word limits for a readable cue.
+func isLongCueText(lines []string) bool {
 	textLines := 0
 	wordCount := 0
-	for i := timestampIdx + 1; i < len(block); i++ {
-		trimmed := strings.TrimSpace(RemoveFontTags(block[i]))
-		if trimmed == "" || isDigitOnly(trimmed) || isStandalonePunctuationLine(trimmed) {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(RemoveFontTags(line))
+		if trimmed == "" || isDigitOnly(trimmed) || isStandalonePunctuationLine(trimmed) || isClassSpanLine(trimmed) {
 			continue
 		}
 		textLines++
@@ -439,38 +585,6 @@ func shouldDropVTTBlock(block []string) bool {
 	return textLines > maxCueTextLines || wordCount > maxCueWords
 }
 
-func normalizeTimestampLine(line string) string {
-	match := vttTimestampRe.FindStringSubmatch(strings.TrimSpace(line))
-	if match == nil {
-		return line
-	}
-
-	s := toVTTTime(match[1])
-	e := toVTTTime(match[2])
-	rest := ""
-	if len(match) > 3 {
-		rest = match[3]
-	}
-
-	return s + " --> " + e + rest
-}
-
-func toVTTTime(t string) string {
-	t = strings.TrimSpace(strings.ReplaceAll(t, ",", "."))
-
-	// MM:SS.mmm -> 00:MM:SS.mmm
-	if regexp.MustCompile(`^\d{2}:\d{2}\.\d{3}$`).MatchString(t) {
-		t = "00:" + t
-	}
-
-	// H:MM:SS.mmm -> HH:MM:SS.mmm
-	if regexp.MustCompile(`^\d{1}:\d{2}:\d{2}\.\d{3}$`).MatchString(t) {
-		t = "0" + t
-	}
-
-	return t
-}
-
 func isDigitOnly(s string) bool {
 	if s == "" {
 		return false
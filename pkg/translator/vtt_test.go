@@ -6,8 +6,18 @@ import (
 	"testing"
 )
 
+func mustParseVTT(t *testing.T, lines ...string) *Document {
+	t.Helper()
+
+	doc, err := ParseVTT(strings.Join(lines, "\n"))
+	if err != nil {
+		t.Fatalf("ParseVTT returned error: %v", err)
+	}
+	return doc
+}
+
 func TestMarkLongCueBlocks_SkipsBlocksWithMoreThanThreeTextLines(t *testing.T) {
-	lines := []string{
+	doc := mustParseVTT(t,
 		"WEBVTT",
 		"",
 		"00:00:00.000 --> 00:00:02.480 line:20%",
@@ -20,23 +30,21 @@ func TestMarkLongCueBlocks_SkipsBlocksWithMoreThanThreeTextLines(t *testing.T) {
 		"",
 		"00:00:02.500 --> 00:00:03.000",
 		"Halo!",
-	}
+	)
 
-	blocked := markLongCueBlocks(lines)
+	blocked := markLongCueBlocks(doc)
 
-	for _, idx := range []int{2, 3, 4, 5, 6, 7, 8} {
-		if !blocked[idx] {
-			t.Fatalf("expected line %d to be blocked", idx)
-		}
+	if !blocked[doc.Cues[0]] {
+		t.Fatalf("expected long cue to be blocked")
 	}
 
-	if blocked[10] || blocked[11] {
+	if blocked[doc.Cues[1]] {
 		t.Fatalf("expected short cue to remain unblocked")
 	}
 }
 
 func TestMarkLongCueBlocks_SkipsSingleLineCueWhenWordsExceedLimit(t *testing.T) {
-	lines := []string{
+	doc := mustParseVTT(t,
 		"WEBVTT",
 		"",
 		"00:00:36.390 --> 00:00:40.390 line:20%",
@@ -44,21 +52,21 @@ func TestMarkLongCueBlocks_SkipsSingleLineCueWhenWordsExceedLimit(t *testing.T)
 		"",
 		"00:00:41.000 --> 00:00:42.000",
 		"Lanjut.",
-	}
+	)
 
-	blocked := markLongCueBlocks(lines)
+	blocked := markLongCueBlocks(doc)
 
-	if !blocked[2] || !blocked[3] {
-		t.Fatalf("expected over-word cue lines to be blocked")
+	if !blocked[doc.Cues[0]] {
+		t.Fatalf("expected over-word cue to be blocked")
 	}
 
-	if blocked[5] || blocked[6] {
+	if blocked[doc.Cues[1]] {
 		t.Fatalf("expected short cue to remain unblocked")
 	}
 }
 
 func TestCollectVTTCueBatches_GroupsByTimestamp(t *testing.T) {
-	lines := []string{
+	doc := mustParseVTT(t,
 		"WEBVTT",
 		"",
 		"00:17.976 --> 00:19.853",
@@ -68,10 +76,9 @@ func TestCollectVTTCueBatches_GroupsByTimestamp(t *testing.T) {
 		"00:20.437 --> 00:23.732",
 		"Sui! You know that Asano kid",
 		"who married into the Yozakura family?",
-	}
+	)
 
-	blocked := markLongCueBlocks(lines)
-	cues := collectVTTCueBatches(lines, blocked)
+	cues := collectVTTCueBatches(doc, markLongCueBlocks(doc), Options{}, nil)
 
 	if len(cues) != 2 {
 		t.Fatalf("expected 2 cue batches, got %d", len(cues))
@@ -85,36 +92,32 @@ func TestCollectVTTCueBatches_GroupsByTimestamp(t *testing.T) {
 		t.Fatalf("unexpected second cue text: %q", cues[1].originalText)
 	}
 
-	if lines[2] != "00:00:17.976 --> 00:00:19.853" {
-		t.Fatalf("expected normalized first timestamp, got %q", lines[2])
-	}
-
-	if lines[6] != "00:00:20.437 --> 00:00:23.732" {
-		t.Fatalf("expected normalized second timestamp, got %q", lines[6])
+	out := FormatVTT(doc)
+	if !strings.Contains(out, "00:00:17.976 --> 00:00:19.853") || !strings.Contains(out, "00:00:20.437 --> 00:00:23.732") {
+		t.Fatalf("expected normalized timestamps, got %q", out)
 	}
 }
 
 func TestApplyTranslatedCue_DistributesTranslatedLinesToCueSlots(t *testing.T) {
-	lines := []string{
+	doc := mustParseVTT(t,
 		"WEBVTT",
 		"",
 		"00:00:17.976 --> 00:00:19.853",
 		"A SPRING BREEZE BLOWS",
 		"THROUGH THE YOZAKURAS",
-		"",
-	}
+	)
 
-	cue := vttCueBatch{
-		textLineIndices: []int{3, 4},
-		originalText:    "A SPRING BREEZE BLOWS\nTHROUGH THE YOZAKURAS",
-	}
+	batch := vttCueBatch{cue: doc.Cues[0], originalText: "A SPRING BREEZE BLOWS\nTHROUGH THE YOZAKURAS"}
 
-	applyTranslatedCue(lines, cue, "ANGIN MUSIM SEMI BERHEMBUS\nMELALUI KELUARGA YOZAKURA", "id")
+	applyTranslatedCue(batch, "ANGIN MUSIM SEMI BERHEMBUS\nMELALUI KELUARGA YOZAKURA", "id")
 
-	got := []string{strings.TrimSpace(lines[3]), strings.TrimSpace(lines[4])}
-	for _, line := range got {
+	if len(doc.Cues[0].Lines) != 2 {
+		t.Fatalf("expected 2 cue lines, got %q", doc.Cues[0].Text())
+	}
+	for _, l := range doc.Cues[0].Lines {
+		line := strings.TrimSpace(l.String())
 		if line == "" {
-			t.Fatalf("unexpected empty distributed line: %#v", got)
+			t.Fatalf("unexpected empty distributed line: %q", doc.Cues[0].Text())
 		}
 		if len([]rune(line)) > hardLineChars {
 			t.Fatalf("distributed line exceeds %d chars: %q", hardLineChars, line)
@@ -149,27 +152,23 @@ func TestSplitCueTextLines_WrapsIndonesianLongSentence(t *testing.T) {
 }
 
 func TestApplyTranslatedCue_PreservesOverflowAsNewLines(t *testing.T) {
-	lines := []string{
+	doc := mustParseVTT(t,
 		"WEBVTT",
 		"",
 		"00:00:30.238 --> 00:00:32.824",
 		"It's an event the chief throws to thank her subordinates",
-		"",
-	}
+	)
 
-	cue := vttCueBatch{
-		textLineIndices: []int{3},
-		originalText:    "It's an event the chief throws to thank her subordinates",
-	}
+	batch := vttCueBatch{cue: doc.Cues[0], originalText: "It's an event the chief throws to thank her subordinates"}
 
-	applyTranslatedCue(lines, cue, "Ini adalah acara yang diadakan ketua untuk berterima kasih kepada bawahannya", "id")
+	applyTranslatedCue(batch, "Ini adalah acara yang diadakan ketua untuk berterima kasih kepada bawahannya", "id")
 
-	if !strings.Contains(lines[3], "\n") {
-		t.Fatalf("expected wrapped output to contain newline, got %q", lines[3])
+	if len(doc.Cues[0].Lines) < 2 {
+		t.Fatalf("expected wrapped output to span several lines, got %q", doc.Cues[0].Text())
 	}
 
-	if len(strings.Split(lines[3], "\n")) > maxOutputLines {
-		t.Fatalf("expected at most %d output lines, got %q", maxOutputLines, lines[3])
+	if len(doc.Cues[0].Lines) > maxOutputLines {
+		t.Fatalf("expected at most %d output lines, got %q", maxOutputLines, doc.Cues[0].Text())
 	}
 }
 
@@ -217,3 +216,71 @@ func TestSplitCueTextLines_StageDirectionSuffixGetsOwnLine(t *testing.T) {
 		t.Fatalf("unexpected stage direction split: got %#v want %#v", got, want)
 	}
 }
+
+func TestApplyBilingualCue_OriginalFirstWithClass(t *testing.T) {
+	doc := mustParseVTT(t,
+		"WEBVTT",
+		"",
+		"00:00:17.976 --> 00:00:19.853",
+		"A SPRING BREEZE BLOWS",
+		"THROUGH THE YOZAKURAS",
+	)
+
+	batch := vttCueBatch{cue: doc.Cues[0], originalText: "A SPRING BREEZE BLOWS THROUGH THE YOZAKURAS"}
+
+	applyBilingualCue(batch, "Angin musim semi berhembus", "id", Options{Output: OutputBilingual})
+
+	want := "<c.original>A SPRING BREEZE BLOWS THROUGH THE YOZAKURAS</c>\nAngin musim semi berhembus"
+	if got := doc.Cues[0].Text(); got != want {
+		t.Fatalf("unexpected bilingual lines: got %q want %q", got, want)
+	}
+}
+
+func TestApplyBilingualCue_TranslationFirstCustomClass(t *testing.T) {
+	doc := mustParseVTT(t, "00:00:01.000 --> 00:00:02.000", "Hello there")
+	batch := vttCueBatch{cue: doc.Cues[0], originalText: "Hello there"}
+
+	applyBilingualCue(batch, "Halo", "id", Options{
+		Output:         OutputBilingual,
+		BilingualOrder: BilingualTranslationFirst,
+		OriginalClass:  "src",
+	})
+
+	if got := doc.Cues[0].Text(); got != "Halo\n<c.src>Hello there</c>" {
+		t.Fatalf("unexpected bilingual cue: %q", got)
+	}
+}
+
+func TestOptionsCacheKey_EmptyForPlainTranslation(t *testing.T) {
+	if key := (Options{}).CacheKey(); key != "" {
+		t.Fatalf("expected empty cache key for default options, got %q", key)
+	}
+
+	if key := (Options{Output: "Bilingual"}).CacheKey(); key != "bilingual|original_first|original" {
+		t.Fatalf("unexpected bilingual cache key: %q", key)
+	}
+
+	if key := (Options{LongCues: "split"}).CacheKey(); key != "long_cues=split" {
+		t.Fatalf("unexpected split cache key: %q", key)
+	}
+
+	if key := (Options{MaxCPS: 17.5}).CacheKey(); key != "max_cps=17.5|max_extension_ms=1500" {
+		t.Fatalf("unexpected reading speed cache key: %q", key)
+	}
+
+	if key := (Options{SDH: "Strip"}).CacheKey(); key != "sdh=strip" {
+		t.Fatalf("unexpected sdh cache key: %q", key)
+	}
+
+	if key := (Options{Lyrics: "original"}).CacheKey(); key != "lyrics=original" {
+		t.Fatalf("unexpected lyrics cache key: %q", key)
+	}
+
+	if key := (Options{Output: "romanized", OriginalClass: "src"}).CacheKey(); key != "romanized|romanized_under=translation" {
+		t.Fatalf("unexpected romanized cache key: %q", key)
+	}
+
+	if key := (Options{Output: "romanized", RomanizedUnder: "original"}).CacheKey(); key != "romanized|romanized_under=original|original" {
+		t.Fatalf("unexpected romanized under original cache key: %q", key)
+	}
+}
@@ -0,0 +1,306 @@
+package translator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// cjkLineWidth is the line width, in columns, for scripts drawn with double-width glyphs (16 characters).
+	cjkLineWidth = 32
+
+	rlm = "\u200f"
+	lrm = "\u200e"
+)
+
+type lineBreakRule int
+
+const (
+	// breakAtSpaces only breaks between space-separated words.
+	breakAtSpaces lineBreakRule = iota
+	// breakAtCharacters may break between any two ideographs or kana, following kinsoku rules.
+	breakAtCharacters
+	// breakAtClusters prefers spaces but may break between grapheme clusters of scripts written without word spaces.
+	breakAtClusters
+)
+
+// scriptWrap describes how lines are wrapped for a target language.
+type scriptWrap struct {
+	rule     lineBreakRule
+	maxWidth int
+	rtl      bool
+}
+
+var scriptWraps = map[string]scriptWrap{
+	"ja":  {rule: breakAtCharacters, maxWidth: cjkLineWidth},
+	"zh":  {rule: breakAtCharacters, maxWidth: cjkLineWidth},
+	"yue": {rule: breakAtCharacters, maxWidth: cjkLineWidth},
+	"ko":  {rule: breakAtSpaces, maxWidth: cjkLineWidth},
+	"th":  {rule: breakAtClusters, maxWidth: hardLineChars},
+	"lo":  {rule: breakAtClusters, maxWidth: hardLineChars},
+	"km":  {rule: breakAtClusters, maxWidth: hardLineChars},
+	"my":  {rule: breakAtClusters, maxWidth: hardLineChars},
+	"ar":  {rule: breakAtSpaces, maxWidth: hardLineChars, rtl: true},
+	"fa":  {rule: breakAtSpaces, maxWidth: hardLineChars, rtl: true},
+	"ur":  {rule: breakAtSpaces, maxWidth: hardLineChars, rtl: true},
+	"he":  {rule: breakAtSpaces, maxWidth: hardLineChars, rtl: true},
+	"iw":  {rule: breakAtSpaces, maxWidth: hardLineChars, rtl: true},
+	"yi":  {rule: breakAtSpaces, maxWidth: hardLineChars, rtl: true},
+}
+
+func scriptWrapFor(targetLang string) (scriptWrap, bool) {
+	wrap, ok := scriptWraps[primaryLanguage(targetLang)]
+	return wrap, ok
+}
+
+// Kinsoku rules: these may not start a line, or may not end one.
+const (
+	noLineStart = "、。，．,.！？!?）)」』】〕〉》〙〗｝]}ーゝゞヽヾ々ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶ・：；:;…‥～ะาำๅๆະາຳໆ"
+	noLineEnd   = "（(「『【〔〈《〘〖｛[{"
+	// thaiLeadingVowels are written before the consonant they follow in speech and may not end a line.
+	thaiLeadingVowels = "เแโใไເແໂໃໄ"
+)
+
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE30, 0xFE4F}, {0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F}, {0x1F900, 0x1F9FF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// runeWidth is the number of terminal columns a rune takes: 2 for East Asian wide and fullwidth characters,
+// 0 for combining marks and format characters such as bidi marks.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, rng := range wideRanges {
+		if r >= rng[0] && r <= rng[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+func isWideRune(r rune) bool {
+	return runeWidth(r) == 2
+}
+
+// displayWidth is the column width of text.
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// wrapUnit is a piece of text that is never split across lines.
+type wrapUnit struct {
+	text  string
+	width int
+	// space is set when the unit follows a space; breaking there drops the space.
+	space bool
+}
+
+// splitWrapUnits cuts text into units at the break opportunities of the rule. Markup placeholders are never
+// separated from the text next to them.
+func splitWrapUnits(text string, rule lineBreakRule) []wrapUnit {
+	var units []wrapUnit
+	var current strings.Builder
+	space, glue := false, false
+
+	finish := func() {
+		if current.Len() == 0 {
+			return
+		}
+		units = append(units, wrapUnit{text: current.String(), width: cueTextLen(current.String()), space: space})
+		current.Reset()
+		space, glue = false, false
+	}
+	appendToPrevious := func(s string) bool {
+		if current.Len() > 0 || space || len(units) == 0 {
+			return false
+		}
+		last := &units[len(units)-1]
+		last.text += s
+		last.width = cueTextLen(last.text)
+		return true
+	}
+
+	rest := text
+	for rest != "" {
+		if loc := markupPlaceholderRe.FindStringIndex(rest); loc != nil && loc[0] == 0 {
+			if !appendToPrevious(rest[:loc[1]]) {
+				// A tag opening the unit stays with the text after it.
+				glue = glue || current.Len() == 0
+				current.WriteString(rest[:loc[1]])
+			}
+			rest = rest[loc[1]:]
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		rest = rest[size:]
+		s := string(r)
+
+		switch {
+		case unicode.IsSpace(r):
+			finish()
+			space = len(units) > 0
+		case rule == breakAtSpaces:
+			current.WriteString(s)
+		case strings.ContainsRune(noLineStart, r) || unicode.In(r, unicode.Mn, unicode.Mc) || runeWidth(r) == 0:
+			if !appendToPrevious(s) {
+				current.WriteString(s)
+			}
+		case strings.ContainsRune(noLineEnd, r) || strings.ContainsRune(thaiLeadingVowels, r):
+			if !glue {
+				finish()
+			}
+			current.WriteString(s)
+			glue = true
+		case rule == breakAtCharacters && !isWideRune(r):
+			// Latin words and numbers inside CJK text stay whole.
+			if current.Len() > 0 && !glue && isWideRune(lastRune(current.String())) {
+				finish()
+			}
+			current.WriteString(s)
+			glue = false
+		default:
+			if !glue {
+				finish()
+			}
+			current.WriteString(s)
+			glue = false
+			if rule == breakAtCharacters {
+				finish()
+			}
+		}
+	}
+	finish()
+
+	return units
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(markupPlaceholderRe.ReplaceAllString(s, ""))
+	return r
+}
+
+// wrapScriptLine wraps text into at most two lines using the break rules of the target script. Two lines are
+// balanced, preferring breaks at spaces and after punctuation.
+func wrapScriptLine(text string, wrap scriptWrap) []string {
+	units := splitWrapUnits(strings.TrimSpace(text), wrap.rule)
+	if len(units) == 0 {
+		return nil
+	}
+
+	if unitsWidth(units) <= wrap.maxWidth || len(units) == 1 {
+		return []string{joinWrapUnits(units)}
+	}
+
+	best, bestCost := 0, 0
+	for k := 1; k < len(units); k++ {
+		first, second := unitsWidth(units[:k]), unitsWidth(units[k:])
+
+		cost := abs(first - second)
+		if over := max(first, second) - wrap.maxWidth; over > 0 {
+			cost += over * 100
+		}
+		switch {
+		case units[k].space:
+		case wrap.rule == breakAtClusters:
+			// Breaking inside a word is a last resort.
+			cost += 1000
+		case wrap.rule == breakAtCharacters:
+			cost += 2
+		}
+		switch last := string(lastRune(units[k-1].text)); {
+		case strings.ContainsAny(last, "。．.！？!?"):
+			cost -= 12
+		case strings.ContainsAny(last, "、，,;；:："):
+			cost -= 6
+		}
+
+		if best == 0 || cost < bestCost {
+			best, bestCost = k, cost
+		}
+	}
+
+	return []string{joinWrapUnits(units[:best]), joinWrapUnits(units[best:])}
+}
+
+// joinScriptLines joins engine lines for rewrapping. Between two double-width characters the line break is dropped
+// instead of becoming a space.
+func joinScriptLines(lines []string, rule lineBreakRule) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(b.String())
+			next, _ := utf8.DecodeRuneInString(line)
+			if rule != breakAtCharacters || !isWideRune(prev) || !isWideRune(next) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func unitsWidth(units []wrapUnit) int {
+	width := 0
+	for i, unit := range units {
+		if i > 0 && unit.space {
+			width++
+		}
+		width += unit.width
+	}
+	return width
+}
+
+func joinWrapUnits(units []wrapUnit) string {
+	var b strings.Builder
+	for i, unit := range units {
+		if i > 0 && unit.space {
+			b.WriteByte(' ')
+		}
+		b.WriteString(unit.text)
+	}
+	return b.String()
+}
+
+// addBidiMarks makes a right-to-left line render correctly in players that pick the paragraph direction from the
+// first strong character: an RLM opens the line, and one follows punctuation that ends the line or a
+// left-to-right run, which would otherwise be drawn on the wrong side.
+func addBidiMarks(line string) string {
+	line = strings.NewReplacer(rlm, "", lrm, "").Replace(line)
+	if strings.TrimSpace(line) == "" {
+		return line
+	}
+
+	var b strings.Builder
+	b.WriteString(rlm)
+	runes := []rune(line)
+	for i, r := range runes {
+		b.WriteRune(r)
+		if !unicode.IsPunct(r) {
+			continue
+		}
+		if i == len(runes)-1 || (i > 0 && isLTRRune(runes[i-1])) {
+			b.WriteString(rlm)
+		}
+	}
+	return b.String()
+}
+
+func isLTRRune(r rune) bool {
+	return unicode.IsDigit(r) || (r < 0x0590 && unicode.IsLetter(r))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
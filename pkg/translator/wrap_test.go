@@ -0,0 +1,96 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplayWidth_CountsWideAndZeroWidthRunes(t *testing.T) {
+	cases := map[string]int{
+		"abc":     3,
+		"日本語":     6,
+		"ｶﾀｶﾅ":    4,
+		"한국어":     6,
+		"กินข้าว": 5,
+		"‏مرحبا":  5,
+	}
+	for text, want := range cases {
+		if got := displayWidth(text); got != want {
+			t.Fatalf("displayWidth(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestSplitCueTextLines_WrapsJapaneseByWidth(t *testing.T) {
+	text := "今日はとても良い天気ですね。散歩に行きませんか？"
+
+	lines := splitCueTextLines(text, "ja")
+	if len(lines) != 2 {
+		t.Fatalf("expected two lines, got %q", lines)
+	}
+	if lines[0] != "今日はとても良い天気ですね。" {
+		t.Fatalf("expected a break after the sentence end, got %q", lines)
+	}
+	for _, line := range lines {
+		if displayWidth(line) > cjkLineWidth {
+			t.Fatalf("line %q is wider than %d columns", line, cjkLineWidth)
+		}
+	}
+}
+
+func TestSplitWrapUnits_KinsokuAndEmbeddedLatin(t *testing.T) {
+	units := splitWrapUnits("「はい」とNetflixで言った。", breakAtCharacters)
+
+	var texts []string
+	for _, unit := range units {
+		texts = append(texts, unit.text)
+	}
+	want := "「は|い」|と|Netflix|で|言っ|た。"
+	if got := strings.Join(texts, "|"); got != want {
+		t.Fatalf("unexpected units:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestWrapScriptLine_ThaiPrefersSpaces(t *testing.T) {
+	wrap := scriptWrap{rule: breakAtClusters, maxWidth: 20}
+	lines := wrapScriptLine("ฉันจะไปตลาดพรุ่งนี้ เธออยากไปด้วยไหม", wrap)
+
+	want := []string{"ฉันจะไปตลาดพรุ่งนี้", "เธออยากไปด้วยไหม"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected Thai wrap: %q", lines)
+	}
+
+	lines = wrapScriptLine("ฉันจะไปตลาดพรุ่งนี้เธออยากไปด้วยไหม", wrap)
+	if len(lines) != 2 {
+		t.Fatalf("expected a cluster break without spaces, got %q", lines)
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "ั") || strings.HasPrefix(line, "้") || strings.HasSuffix(line, "เ") {
+			t.Fatalf("line broken inside a cluster: %q", lines)
+		}
+	}
+}
+
+func TestSplitCueTextLines_AddsBidiMarksForRTL(t *testing.T) {
+	lines := splitCueTextLines("هل تريد مشاهدة Netflix؟", "ar")
+
+	want := "‏هل تريد مشاهدة Netflix؟‏"
+	if len(lines) != 1 || lines[0] != want {
+		t.Fatalf("unexpected RTL line: %q", lines)
+	}
+
+	if got := addBidiMarks(lines[0]); got != want {
+		t.Fatalf("bidi marks should not be added twice: %q", got)
+	}
+}
+
+func TestWrapScriptLine_KeepsPlaceholdersWithText(t *testing.T) {
+	wrap := scriptWrap{rule: breakAtCharacters, maxWidth: 10}
+	lines := wrapScriptLine("⟦0⟧今日は⟦1⟧良い天気ですね", wrap)
+
+	for _, line := range lines {
+		if strings.HasSuffix(line, "⟦0⟧") || strings.HasPrefix(line, "⟦1⟧") {
+			t.Fatalf("placeholder separated from its text: %q", lines)
+		}
+	}
+}